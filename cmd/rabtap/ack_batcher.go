@@ -0,0 +1,115 @@
+// Copyright (C) 2026 Jan Delgado
+// Acknowledge messages in batches using multiple-acks.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// ackBatcher acknowledges (or rejects) messages with a single multiple-ack
+// of the last message, when size messages are pending or interval elapsed
+// since the first pending message was received. Since delivery tags are
+// scoped by channel, batches are kept per channel. Messages must be passed
+// in the order they were delivered.
+type ackBatcher struct {
+	reject   bool
+	requeue  bool
+	size     int
+	interval time.Duration
+	logger   *slog.Logger
+	// acked is called with the last message of each successfully
+	// acknowledged batch, if set.
+	acked func(rabtap.TapMessage)
+
+	mu      sync.Mutex
+	batches map[amqp.Acknowledger]*ackBatch
+	timer   *time.Timer
+}
+
+// ackBatch are the pending messages of a channel
+type ackBatch struct {
+	last    rabtap.TapMessage
+	pending int
+}
+
+func newAckBatcher(reject, requeue bool, size int, interval time.Duration, logger *slog.Logger) *ackBatcher {
+	return &ackBatcher{
+		reject:   reject,
+		requeue:  requeue,
+		size:     size,
+		interval: interval,
+		logger:   logger,
+		batches:  map[amqp.Acknowledger]*ackBatch{},
+	}
+}
+
+// Acknowledge is the AcknowledgeFunc of the batcher.
+func (s *ackBatcher) Acknowledge(message rabtap.TapMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel := message.AmqpMessage.Acknowledger
+	batch, ok := s.batches[channel]
+	if !ok {
+		batch = &ackBatch{}
+		s.batches[channel] = batch
+	}
+	batch.last = message
+	batch.pending++
+	if batch.pending >= s.size {
+		delete(s.batches, channel)
+		return s.acknowledge(batch)
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.interval, func() {
+			if err := s.Flush(); err != nil {
+				s.logger.Error("acknowledge failed", "error", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush acknowledges all pending messages.
+func (s *ackBatcher) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	var errs []error
+	for channel, batch := range s.batches {
+		delete(s.batches, channel)
+		errs = append(errs, s.acknowledge(batch))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ackBatcher) acknowledge(batch *ackBatch) error {
+	last := batch.last.AmqpMessage
+	s.logger.Debug("acknowledging messages", "count", batch.pending, "tag", last.DeliveryTag)
+	if s.reject {
+		if err := last.Nack(true, s.requeue); err != nil {
+			return fmt.Errorf("REJECT of %d messages failed: %w", batch.pending, err)
+		}
+		return nil
+	}
+	if err := last.Ack(true); err != nil {
+		return fmt.Errorf("ACK of %d messages failed: %w", batch.pending, err)
+	}
+	if s.acked != nil {
+		s.acked(batch.last)
+	}
+	return nil
+}
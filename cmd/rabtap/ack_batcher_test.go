@@ -0,0 +1,148 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// recordingAcknowledger records the calls to Ack and Nack
+type recordingAcknowledger struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (s *recordingAcknowledger) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *recordingAcknowledger) getCalls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+func (s *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	s.record(fmt.Sprintf("ack %d multiple=%t", tag, multiple))
+	return nil
+}
+
+func (s *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	s.record(fmt.Sprintf("nack %d multiple=%t requeue=%t", tag, multiple, requeue))
+	return nil
+}
+
+func (s *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	s.record(fmt.Sprintf("reject %d requeue=%t", tag, requeue))
+	return nil
+}
+
+// failingAcknowledger fails all calls
+type failingAcknowledger struct{}
+
+func (failingAcknowledger) Ack(uint64, bool) error        { return errors.New("ack failed") }
+func (failingAcknowledger) Nack(uint64, bool, bool) error { return errors.New("nack failed") }
+func (failingAcknowledger) Reject(uint64, bool) error     { return errors.New("reject failed") }
+
+func testDelivery(acknowledger amqp.Acknowledger, tag uint64) rabtap.TapMessage {
+	return rabtap.TapMessage{AmqpMessage: &amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: tag}}
+}
+
+func TestAckBatcherAcknowledgesFullBatchWithMultipleAck(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	batcher := newAckBatcher(false, false, 3, time.Hour, slog.New(slog.DiscardHandler))
+
+	for tag := uint64(1); tag <= 7; tag++ {
+		require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, tag)))
+	}
+	assert.Equal(t, []string{"ack 3 multiple=true", "ack 6 multiple=true"}, acknowledger.getCalls())
+
+	require.NoError(t, batcher.Flush())
+	require.NoError(t, batcher.Flush())
+	assert.Equal(t, []string{"ack 3 multiple=true", "ack 6 multiple=true", "ack 7 multiple=true"},
+		acknowledger.getCalls())
+}
+
+func TestAckBatcherRejectsWithMultipleNack(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	batcher := newAckBatcher(true, true, 2, time.Hour, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, 1)))
+	require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, 2)))
+
+	assert.Equal(t, []string{"nack 2 multiple=true requeue=true"}, acknowledger.getCalls())
+}
+
+func TestAckBatcherAcknowledgesPendingMessagesAfterInterval(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	batcher := newAckBatcher(false, false, 10, 10*time.Millisecond, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, 1)))
+	require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, 2)))
+
+	assert.Eventually(t, func() bool {
+		calls := acknowledger.getCalls()
+		return len(calls) == 1 && calls[0] == "ack 2 multiple=true"
+	}, time.Second, time.Millisecond)
+}
+
+func TestAckBatcherKeepsBatchesPerChannel(t *testing.T) {
+	channel1 := &recordingAcknowledger{}
+	channel2 := &recordingAcknowledger{}
+	batcher := newAckBatcher(false, false, 2, time.Hour, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, batcher.Acknowledge(testDelivery(channel1, 1)))
+	require.NoError(t, batcher.Acknowledge(testDelivery(channel2, 1)))
+	require.NoError(t, batcher.Acknowledge(testDelivery(channel1, 2)))
+
+	assert.Equal(t, []string{"ack 2 multiple=true"}, channel1.getCalls())
+	assert.Empty(t, channel2.getCalls())
+
+	require.NoError(t, batcher.Flush())
+	assert.Equal(t, []string{"ack 1 multiple=true"}, channel2.getCalls())
+}
+
+func TestAckBatcherCallsAckedWithLastMessageOfAcknowledgedBatch(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	batcher := newAckBatcher(false, false, 2, time.Hour, slog.New(slog.DiscardHandler))
+	var acked []uint64
+	batcher.acked = func(message rabtap.TapMessage) {
+		acked = append(acked, message.AmqpMessage.DeliveryTag)
+	}
+
+	for tag := uint64(1); tag <= 3; tag++ {
+		require.NoError(t, batcher.Acknowledge(testDelivery(acknowledger, tag)))
+	}
+	require.NoError(t, batcher.Flush())
+
+	assert.Equal(t, []uint64{2, 3}, acked)
+}
+
+func TestAckBatcherDoesNotCallAckedForRejectedOrFailedBatches(t *testing.T) {
+	for _, reject := range []bool{true, false} {
+		var acknowledger amqp.Acknowledger = &recordingAcknowledger{}
+		if !reject {
+			acknowledger = failingAcknowledger{}
+		}
+		batcher := newAckBatcher(reject, false, 1, time.Hour, slog.New(slog.DiscardHandler))
+		called := false
+		batcher.acked = func(rabtap.TapMessage) { called = true }
+
+		_ = batcher.Acknowledge(testDelivery(acknowledger, 1))
+
+		assert.False(t, called, "reject=%t", reject)
+	}
+}
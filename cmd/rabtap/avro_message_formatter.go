@@ -0,0 +1,264 @@
+// Copyright (C) 2026 Jan Delgado
+// Decode Avro messages using schemas of a directory or a schema registry.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// avroContentTypes are the content types the Avro formatter is registered
+// for
+var avroContentTypes = []string{
+	"application/avro",
+	"avro/binary",
+	"application/vnd.apache.avro+binary",
+}
+
+// avroMagicByte starts a message in Confluent wire format, followed by the
+// 4 byte big-endian schema ID and the Avro binary encoded message.
+const avroMagicByte = 0
+
+const avroRegistryTimeout = 10 * time.Second
+
+// avroSchemaSource looks up Avro schemas by their ID (Confluent wire format)
+// or their name (AMQP Type property).
+type avroSchemaSource interface {
+	schemaByID(id uint32) (string, error)
+	schemaByName(name string) (string, error)
+}
+
+// avroSchemaDir reads schemas from a directory, which contains a file
+// ID.avsc or NAME.avsc for each schema.
+type avroSchemaDir struct {
+	dir string
+}
+
+func (s avroSchemaDir) read(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name+".avsc"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s avroSchemaDir) schemaByID(id uint32) (string, error) {
+	return s.read(strconv.FormatUint(uint64(id), 10))
+}
+
+func (s avroSchemaDir) schemaByName(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid schema name %s", name)
+	}
+	return s.read(name)
+}
+
+// avroSchemaRegistry reads schemas from a Confluent compatible schema
+// registry. Names are looked up as subjects.
+type avroSchemaRegistry struct {
+	url    *url.URL
+	client *http.Client
+}
+
+func (s avroSchemaRegistry) get(path string) (string, error) {
+	resp, err := s.client.Get(s.url.JoinPath(path).String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("schema registry: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("schema registry: %w", err)
+	}
+	return result.Schema, nil
+}
+
+func (s avroSchemaRegistry) schemaByID(id uint32) (string, error) {
+	return s.get("schemas/ids/" + strconv.FormatUint(uint64(id), 10))
+}
+
+func (s avroSchemaRegistry) schemaByName(name string) (string, error) {
+	return s.get("subjects/" + url.PathEscape(name) + "/versions/latest")
+}
+
+// AvroDecoder decodes Avro messages and formats them as JSON. Messages in
+// Confluent wire format are decoded with the schema of the embedded ID. Other
+// messages, or when the wire format can not be decoded, are decoded with the
+// schema named by the AMQP Type property, if set.
+type AvroDecoder struct {
+	source avroSchemaSource
+	mu     sync.Mutex
+	codecs map[string]*goavro.Codec
+}
+
+// avroDecoder is the decoder registered with RegisterAvroDecoder, which is
+// also used in filter expressions
+var avroDecoder *AvroDecoder
+
+// RegisterAvroDecoder registers the decoder as message formatter for the Avro
+// content types and makes it available in filter expressions.
+func RegisterAvroDecoder(decoder *AvroDecoder) {
+	avroDecoder = decoder
+	for _, contentType := range avroContentTypes {
+		RegisterMessageFormatter(contentType, decoder)
+	}
+}
+
+// NewAvroDecoder returns an AvroDecoder reading schemas from the given
+// location, which is either a directory or the URL of a schema registry.
+func NewAvroDecoder(location string, tlsConfig *tls.Config) (*AvroDecoder, error) {
+	var source avroSchemaSource
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema registry URL: %w", err)
+		}
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   avroRegistryTimeout,
+		}
+		source = avroSchemaRegistry{url: u, client: client}
+	} else {
+		info, err := os.Stat(location)
+		if err != nil {
+			return nil, fmt.Errorf("schema directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("schema directory: %s is not a directory", location)
+		}
+		source = avroSchemaDir{dir: location}
+	}
+	return &AvroDecoder{source: source, codecs: map[string]*goavro.Codec{}}, nil
+}
+
+// codec returns the (cached) codec of the given schema. Failed lookups are
+// not cached, so they are retried with the next message.
+func (s *AvroDecoder) codec(key string, lookup func() (string, error)) (*goavro.Codec, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if codec, ok := s.codecs[key]; ok {
+		return codec, nil
+	}
+	schema, err := lookup()
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", key, err)
+	}
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", key, err)
+	}
+	s.codecs[key] = codec
+	return codec, nil
+}
+
+// decodeWireFormat decodes a message in Confluent wire format
+func (s *AvroDecoder) decodeWireFormat(body []byte) (*goavro.Codec, interface{}, error) {
+	if len(body) < 5 || body[0] != avroMagicByte {
+		return nil, nil, errors.New("message is not in Confluent wire format")
+	}
+	id := binary.BigEndian.Uint32(body[1:5])
+	codec, err := s.codec("id "+strconv.FormatUint(uint64(id), 10),
+		func() (string, error) { return s.source.schemaByID(id) })
+	if err != nil {
+		return nil, nil, err
+	}
+	native, _, err := codec.NativeFromBinary(body[5:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return codec, native, nil
+}
+
+// decode decodes the body of the message and returns its codec and the
+// decoded message. The Confluent wire format is tried first, then the
+// schema named by the Type property.
+func (s *AvroDecoder) decode(m *amqp.Delivery, body []byte) (*goavro.Codec, interface{}, error) {
+	codec, native, err := s.decodeWireFormat(body)
+	if err == nil || m.Type == "" {
+		return codec, native, err
+	}
+	codec, err = s.codec(m.Type, func() (string, error) { return s.source.schemaByName(m.Type) })
+	if err != nil {
+		return nil, nil, err
+	}
+	native, _, err = codec.NativeFromBinary(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return codec, native, nil
+}
+
+// toJSON decodes the message and returns it in Avro JSON encoding
+func (s *AvroDecoder) toJSON(m *amqp.Delivery, body []byte) ([]byte, error) {
+	codec, native, err := s.decode(m, body)
+	if err != nil {
+		return nil, err
+	}
+	return codec.TextualFromNative(nil, native)
+}
+
+// Format returns the body as-is, since the schema is not known.
+func (s *AvroDecoder) Format(body []byte) string {
+	return string(body)
+}
+
+// FormatMessage pretty prints the given body of the message as JSON. If the
+// message can not be decoded, it will be returned unformatted as-is.
+func (s *AvroDecoder) FormatMessage(m *amqp.Delivery, body []byte) string {
+	data, err := s.toJSON(m, body)
+	if err != nil {
+		return string(body)
+	}
+	// goavro encodes record fields in random order, so the JSON is decoded
+	// and encoded again with sorted keys for a stable output
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return string(body)
+	}
+	formatted, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return string(body)
+	}
+	return string(formatted)
+}
+
+// Unmarshal decodes the body of the message into a generic structure, as used
+// in filter expressions.
+func (s *AvroDecoder) Unmarshal(m *amqp.Delivery) (map[string]interface{}, error) {
+	body, err := Body(m)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.toJSON(m, body)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
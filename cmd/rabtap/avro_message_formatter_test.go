@@ -0,0 +1,171 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+const testAvroSchema = `{"type":"record","name":"Order","namespace":"shop","fields":[
+	{"name":"id","type":"string"},
+	{"name":"amount","type":"int"}]}`
+
+// testAvroOrder returns a shop.Order record in Avro binary encoding,
+// optionally in Confluent wire format with the given schema ID
+func testAvroOrder(t *testing.T, id string, amount int, schemaID *uint32) []byte {
+	t.Helper()
+	codec, err := goavro.NewCodec(testAvroSchema)
+	require.NoError(t, err)
+	var buf []byte
+	if schemaID != nil {
+		buf = append([]byte{avroMagicByte}, binary.BigEndian.AppendUint32(nil, *schemaID)...)
+	}
+	buf, err = codec.BinaryFromNative(buf, map[string]interface{}{"id": id, "amount": amount})
+	require.NoError(t, err)
+	return buf
+}
+
+func newTestAvroSchemaDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "42.avsc"), []byte(testAvroSchema), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shop.Order.avsc"), []byte(testAvroSchema), 0o644))
+	return dir
+}
+
+func TestAvroDecoderDecodesConfluentWireFormatWithSchemaDir(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	schemaID := uint32(42)
+	body := testAvroOrder(t, "o-1", 42, &schemaID)
+
+	formatted := decoder.FormatMessage(&amqp.Delivery{}, body)
+
+	assert.Equal(t, "{\n  \"amount\": 42,\n  \"id\": \"o-1\"\n}", formatted)
+}
+
+func TestAvroDecoderDecodesMessageWithSchemaNameFromType(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	message := &amqp.Delivery{Type: "shop.Order", Body: testAvroOrder(t, "o-2", 7, nil)}
+
+	decoded, err := decoder.Unmarshal(message)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "o-2", "amount": float64(7)}, decoded)
+}
+
+func TestAvroDecoderReturnsBodyAsIsWhenSchemaIsUnknown(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	schemaID := uint32(99)
+	body := testAvroOrder(t, "o-1", 42, &schemaID)
+
+	assert.Equal(t, string(body), decoder.FormatMessage(&amqp.Delivery{}, body))
+	assert.Equal(t, string(body), decoder.FormatMessage(&amqp.Delivery{Type: "../shop.Order"}, body))
+	assert.Equal(t, "plain", decoder.FormatMessage(&amqp.Delivery{}, []byte("plain")))
+}
+
+func TestAvroDecoderLooksUpSchemasInRegistryOnce(t *testing.T) {
+	var requests atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/schemas/ids/42", "/subjects/shop.Order/versions/latest":
+			_ = json.NewEncoder(w).Encode(map[string]string{"schema": testAvroSchema})
+		default:
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	decoder, err := NewAvroDecoder(registry.URL, nil)
+	require.NoError(t, err)
+	schemaID := uint32(42)
+
+	for range 2 {
+		decoded, err := decoder.Unmarshal(&amqp.Delivery{Body: testAvroOrder(t, "o-3", 3, &schemaID)})
+		require.NoError(t, err)
+		assert.Equal(t, "o-3", decoded["id"])
+	}
+	_, err = decoder.Unmarshal(&amqp.Delivery{Type: "shop.Order", Body: testAvroOrder(t, "o-4", 4, nil)})
+	require.NoError(t, err)
+	// failed lookups are not cached
+	schemaID = 7
+	for range 2 {
+		_, err = decoder.Unmarshal(&amqp.Delivery{Body: testAvroOrder(t, "o-5", 5, &schemaID)})
+		assert.ErrorContains(t, err, "404 Not Found")
+	}
+
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestAvroDecoderPrefersWireFormatOverType(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	schemaID := uint32(42)
+	message := &amqp.Delivery{Type: "unknown.Type", Body: testAvroOrder(t, "o-6", 6, &schemaID)}
+
+	decoded, err := decoder.Unmarshal(message)
+
+	require.NoError(t, err)
+	assert.Equal(t, "o-6", decoded["id"])
+}
+
+func TestAvroDecoderFallsBackToTypeWhenWireFormatFails(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	// a plain record starting with a 0 byte (empty id), which looks like
+	// the wire format with an unknown schema ID
+	message := &amqp.Delivery{Type: "shop.Order", Body: testAvroOrder(t, "", 1<<30, nil)}
+	require.Len(t, message.Body, 6)
+	require.Equal(t, avroMagicByte, int(message.Body[0]))
+
+	decoded, err := decoder.Unmarshal(message)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "", "amount": float64(1 << 30)}, decoded)
+}
+
+func TestNewAvroDecoderFailsOnMissingSchemaDir(t *testing.T) {
+	_, err := NewAvroDecoder(filepath.Join(t.TempDir(), "missing"), nil)
+	assert.ErrorContains(t, err, "schema directory")
+}
+
+func TestRegisteredAvroDecoderIsUsedInFilter(t *testing.T) {
+	decoder, err := NewAvroDecoder(newTestAvroSchemaDir(t), nil)
+	require.NoError(t, err)
+	RegisterAvroDecoder(decoder)
+	t.Cleanup(func() {
+		avroDecoder = nil
+		for _, contentType := range avroContentTypes {
+			delete(messageFormatters, contentType)
+		}
+	})
+	schemaID := uint32(42)
+	message := rabtap.NewTapMessage(&amqp.Delivery{
+		ContentType: "application/avro",
+		Body:        testAvroOrder(t, "o-6", 500, &schemaID),
+	}, time.Now())
+
+	pred, err := NewExprPredicate(`r.avro(r.msg).amount > 100`)
+	require.NoError(t, err)
+	res, err := pred.Eval(createMessagePredEnv(message, 1))
+
+	require.NoError(t, err)
+	assert.True(t, res)
+}
@@ -3,19 +3,89 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"log/slog"
+	"mime"
+	"strings"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/text/encoding/ianaindex"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
 )
 
-// Body returns the message Body, uncompressing if necessary
+// charset returns the lower case charset parameter of the content type or an
+// empty string, if not set.
+func charset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(params["charset"])
+}
+
+func isUTF8Charset(name string) bool {
+	return name == "" || name == "utf-8" || name == "utf8" || name == "us-ascii"
+}
+
+// toUTF8 converts the body from the charset of the content type to UTF-8.
+// Bodies without charset or in UTF-8 are returned as-is. Charsets are looked
+// up by their IANA names and aliases.
+func toUTF8(contentType string, body []byte) ([]byte, error) {
+	name := charset(contentType)
+	if isUTF8Charset(name) {
+		return body, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("charset: unsupported charset %s", name)
+	}
+	return enc.NewDecoder().Bytes(body)
+}
+
+// Body returns the message Body, uncompressing if necessary and converted to
+// UTF-8 if the ContentType has a charset parameter. If the charset is not
+// supported, the body is returned unconverted.
 func Body(m *amqp.Delivery) ([]byte, error) {
+	body := m.Body
 	// currently we only expect a single encoding in the header
 	if enc := m.ContentEncoding; enc != "" {
 		dec, err := NewDecompressor(enc)
 		if err != nil {
 			return nil, fmt.Errorf("decompress: %w", err)
 		}
-		return dec(bytes.NewReader(m.Body))
+		if body, err = dec(bytes.NewReader(m.Body)); err != nil {
+			return nil, err
+		}
+	}
+	converted, err := toUTF8(m.ContentType, body)
+	if err != nil {
+		slog.Debug("not converting body to UTF-8", "error", err)
+		return body, nil
+	}
+	return converted, nil
+}
+
+// withUTF8Body returns the message with its body converted to UTF-8 and the
+// charset of the ContentType set accordingly, e.g. before exporting it. The
+// message is returned unchanged, if it is compressed, already in UTF-8 or the
+// body can not be converted.
+func withUTF8Body(message rabtap.TapMessage) rabtap.TapMessage {
+	m := message.AmqpMessage
+	if m == nil || m.ContentEncoding != "" {
+		return message
+	}
+	if isUTF8Charset(charset(m.ContentType)) {
+		return message
+	}
+	body, err := toUTF8(m.ContentType, m.Body)
+	if err != nil {
+		return message
 	}
-	return m.Body, nil
+	mediaType, params, _ := mime.ParseMediaType(m.ContentType)
+	params["charset"] = "utf-8"
+	converted := *m
+	converted.Body = body
+	converted.ContentType = mime.FormatMediaType(mediaType, params)
+	message.AmqpMessage = &converted
+	return message
 }
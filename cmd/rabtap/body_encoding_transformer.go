@@ -0,0 +1,122 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// bodyEncoder encodes a value decoded from JSON in a binary format
+type bodyEncoder struct {
+	encode func(value interface{}) ([]byte, error)
+	// isEncoded returns true if the body is already encoded in the format
+	isEncoded func(body []byte) bool
+}
+
+func encodeMsgpack(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isMsgpack returns true if body is a single msgpack encoded value
+func isMsgpack(body []byte) bool {
+	r := bytes.NewReader(body)
+	dec := msgpack.NewDecoder(r)
+	return dec.Skip() == nil && r.Len() == 0
+}
+
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+func encodeCBOR(value interface{}) ([]byte, error) {
+	return cborEncMode.Marshal(value)
+}
+
+// isCBOR returns true if body is a single CBOR data item
+func isCBOR(body []byte) bool {
+	return cbor.Wellformed(body) == nil
+}
+
+// bodyEncoders are the encoders of JSON bodies. Key is contentType
+var bodyEncoders = func() map[string]bodyEncoder {
+	encoders := map[string]bodyEncoder{}
+	for _, contentType := range msgpackContentTypes {
+		encoders[contentType] = bodyEncoder{encodeMsgpack, isMsgpack}
+	}
+	for _, contentType := range cborContentTypes {
+		encoders[contentType] = bodyEncoder{encodeCBOR, isCBOR}
+	}
+	return encoders
+}()
+
+// decodeJSONBody decodes a JSON document, keeping integers as int64.
+func decodeJSONBody(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+	return fromJSONNumbers(value), nil
+}
+
+// fromJSONNumbers replaces json.Number values by int64 or float64 values
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = fromJSONNumbers(elem)
+		}
+		return v
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = fromJSONNumbers(elem)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// BodyEncodingTransformer encodes JSON bodies as msgpack or CBOR, when the
+// ContentType of the message asks for it. Bodies which are already encoded
+// or compressed are left untouched, even if they are also valid JSON (e.g.
+// the msgpack encoded number 0x31). Other bodies are rejected, since they
+// would be published with a wrong ContentType.
+func BodyEncodingTransformer(m RabtapPersistentMessage) (RabtapPersistentMessage, error) {
+	encoder, ok := bodyEncoders[mediaType(m.ContentType)]
+	if !ok || m.ContentEncoding != "" || encoder.isEncoded(m.Body) {
+		return m, nil
+	}
+	value, err := decodeJSONBody(m.Body)
+	if err != nil {
+		return m, fmt.Errorf("encode body as %s: body is neither JSON nor %s: %w",
+			m.ContentType, m.ContentType, err)
+	}
+	body, err := encoder.encode(value)
+	if err != nil {
+		return m, fmt.Errorf("encode body as %s: %w", m.ContentType, err)
+	}
+	m.Body = body
+	return m, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestBodyEncodingTransformerEncodesJSONAsMsgpack(t *testing.T) {
+	m := RabtapPersistentMessage{
+		ContentType: "application/msgpack",
+		Body:        []byte(`{"id":1,"temp":21.5,"tags":["a"]}`),
+	}
+
+	m, err := BodyEncodingTransformer(m)
+
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(m.Body, &decoded))
+	assert.Equal(t, map[string]interface{}{
+		"id": int8(1), "temp": 21.5, "tags": []interface{}{"a"},
+	}, decoded)
+}
+
+func TestBodyEncodingTransformerEncodesJSONAsCBOR(t *testing.T) {
+	m := RabtapPersistentMessage{
+		ContentType: "application/cbor",
+		Body:        []byte(`{"id":-1,"name":"sensor"}`),
+	}
+
+	m, err := BodyEncodingTransformer(m)
+
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, cbor.Unmarshal(m.Body, &decoded))
+	assert.Equal(t, map[string]interface{}{"id": int64(-1), "name": "sensor"}, decoded)
+}
+
+func TestBodyEncodingTransformerKeepsOtherBodies(t *testing.T) {
+	encoded, err := msgpack.Marshal(map[string]interface{}{"id": 1})
+	require.NoError(t, err)
+
+	testcases := []RabtapPersistentMessage{
+		// already encoded
+		{ContentType: "application/msgpack", Body: encoded},
+		// already encoded and valid JSON
+		{ContentType: "application/msgpack", Body: []byte{0x31}},
+		{ContentType: "application/cbor", Body: []byte{0x31}},
+		// no binary content type
+		{ContentType: "application/json", Body: []byte(`{"id":1}`)},
+		// compressed
+		{ContentType: "application/cbor", ContentEncoding: "gzip", Body: []byte(`{"id":1}`)},
+	}
+	for _, tc := range testcases {
+		m, err := BodyEncodingTransformer(tc)
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.Body, m.Body)
+	}
+}
+
+func TestBodyEncodingTransformerFailsOnBodiesNeitherJSONNorEncoded(t *testing.T) {
+	testcases := []RabtapPersistentMessage{
+		{ContentType: "application/msgpack", Body: []byte(`{"id":1`)},
+		// more than a JSON document
+		{ContentType: "application/cbor", Body: []byte(`{"id":1} {"id":2}`)},
+	}
+	for _, tc := range testcases {
+		_, err := BodyEncodingTransformer(tc)
+
+		assert.ErrorContains(t, err, "body is neither JSON nor "+tc.ContentType)
+	}
+}
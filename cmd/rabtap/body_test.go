@@ -3,10 +3,13 @@ package main
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
 )
 
 func TestBodyDecompressesACompressedBody(t *testing.T) {
@@ -45,3 +48,66 @@ func TestBodyReturnsTheBodyAsIsWithoutAnEncoding(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "JAN", string(buf))
 }
+
+func TestBodyConvertsCharsetToUTF8(t *testing.T) {
+	testcases := []struct {
+		contentType string
+		body        []byte
+		expected    string
+	}{
+		// in ISO-8859-1, 0x80 is a control character and not the euro sign
+		{"text/plain; charset=ISO-8859-1", []byte("M\xfcnchen \x80"), "München \u0080"},
+		{"text/plain; charset=windows-1252", []byte("M\xfcnchen \x80"), "München €"},
+		{"text/plain; charset=utf-16", []byte("\xff\xfeM\x00\xfc\x00n\x00 \x00\xac\x20"), "Mün €"},
+		{"text/plain; charset=utf-16be", []byte("\x00M\x00\xfc\x00n\x00 \x20\xac"), "Mün €"},
+		{"text/plain; charset=utf-8", []byte("Mün"), "Mün"},
+		{"text/plain; charset=Shift_JIS", []byte("\x93\x8c\x8b\x9e"), "東京"},
+		{"text/plain; charset=IBM037", []byte("\xd1\xc1\xd5"), "JAN"},
+	}
+	for _, tc := range testcases {
+		// given
+		d := amqp.Delivery{ContentType: tc.contentType, Body: tc.body}
+
+		// when
+		buf, err := Body(&d)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, string(buf), tc.contentType)
+	}
+}
+
+func TestBodyReturnsUnconvertedBodyWithUnknownCharset(t *testing.T) {
+	// given
+	d := amqp.Delivery{ContentType: "text/plain; charset=ebcdic", Body: []byte("JAN")}
+
+	// when
+	buf, err := Body(&d)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "JAN", string(buf))
+}
+
+func TestToUTF8FailsWithUnknownCharset(t *testing.T) {
+	_, err := toUTF8("text/plain; charset=ebcdic", []byte("JAN"))
+
+	assert.ErrorContains(t, err, "charset: unsupported charset ebcdic")
+}
+
+func TestWithUTF8BodyConvertsBodyAndContentType(t *testing.T) {
+	// given
+	d := amqp.Delivery{ContentType: "text/plain; charset=latin1", Body: []byte("M\xfcnchen")}
+	message := rabtap.NewTapMessage(&d, time.Now())
+
+	// when
+	converted := withUTF8Body(message)
+
+	// then
+	assert.Equal(t, "München", string(converted.AmqpMessage.Body))
+	assert.Equal(t, "text/plain; charset=utf-8", converted.AmqpMessage.ContentType)
+	assert.Equal(t, "M\xfcnchen", string(d.Body))
+
+	d.ContentEncoding = "gzip"
+	assert.Same(t, &d, withUTF8Body(message).AmqpMessage)
+}
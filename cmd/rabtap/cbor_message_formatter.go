@@ -0,0 +1,38 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborContentTypes are the content types of CBOR encoded messages
+var cborContentTypes = []string{
+	"application/cbor",
+}
+
+// CBORMessageFormatter pretty prints CBOR encoded messages as JSON.
+type CBORMessageFormatter struct{}
+
+var (
+	_ = func() struct{} {
+		for _, contentType := range cborContentTypes {
+			RegisterMessageFormatter(contentType, CBORMessageFormatter{})
+		}
+		return struct{}{}
+	}()
+)
+
+// Format tries to decode a CBOR encoded message and to format it as JSON.
+// If the message is not valid CBOR, it will be returned unformatted as-is.
+func (s CBORMessageFormatter) Format(body []byte) string {
+	var value interface{}
+	if err := cbor.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	formatted, err := formatAsJSON(value)
+	if err != nil {
+		return string(body)
+	}
+	return formatted
+}
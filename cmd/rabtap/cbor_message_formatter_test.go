@@ -0,0 +1,24 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCBORFormatterFormatsBodyAsJSON(t *testing.T) {
+	body, err := cbor.Marshal(map[string]interface{}{"id": 1, "temp": 21.5, "ok": true})
+	require.NoError(t, err)
+
+	formatted := CBORMessageFormatter{}.Format(body)
+
+	assert.JSONEq(t, `{"id":1,"temp":21.5,"ok":true}`, formatted)
+}
+
+func TestCBORFormatterReturnsInvalidBodyAsIs(t *testing.T) {
+	assert.Equal(t, "\xff", CBORMessageFormatter{}.Format([]byte("\xff")))
+}
@@ -28,8 +28,26 @@ type CmdPublishArg struct {
 	source     MessageSource
 	speed      float64
 	fixedDelay *time.Duration
-	confirms   bool
-	mandatory  bool
+	// rate is the maximum number of messages published per second, if set.
+	// The recorded delays are ignored then.
+	rate float64
+	// parallel is the number of connections messages are published on
+	// concurrently
+	parallel int
+	confirms bool
+	// confirmWindow is the maximum number of messages waiting for their
+	// confirmation
+	confirmWindow int
+	// retries is the number of times a failed message is published again,
+	// after a delay of retryBackoff, which doubles with every retry.
+	retries      int
+	retryBackoff time.Duration
+	// deadLetterFile is the optional file failed messages are written to
+	deadLetterFile *string
+	mandatory      bool
+	// out receives the final summary, which is only printed when confirms
+	// are enabled
+	out io.Writer
 }
 
 type DelayFunc func(first, second *RabtapPersistentMessage)
@@ -57,14 +75,16 @@ func durationBetweenMessages(first, second *RabtapPersistentMessage,
 }
 
 // publishMessage publishes a single message on the given exchange with the
-// provided routingkey
+// provided routingkey. position is the position of the message in the source.
 func publishMessage(publishChannel rabtap.PublishChannel,
 	routing rabtap.Routing,
 	amqpPublishing amqp.Publishing,
+	position int64,
 ) {
 	publishChannel <- &rabtap.PublishMessage{
 		Routing:    routing,
 		Publishing: &amqpPublishing,
+		Position:   position,
 	}
 }
 
@@ -100,7 +120,7 @@ func publishMessageStream(publishCh rabtap.PublishChannel,
 	}()
 
 	var lastMsg *RabtapPersistentMessage
-	for {
+	for position := int64(1); ; position++ {
 		msg, err := source()
 		switch err {
 		case io.EOF: //  if errors.Is(err, io.EOF)
@@ -115,7 +135,7 @@ func publishMessageStream(publishCh rabtap.PublishChannel,
 			// during publishing, header information in msg.Header will be overriden
 			// by header information in the routing object (if present). The
 			// latter are set on the command line using --header K=V options.
-			publishMessage(publishCh, routing, msg.ToAmqpPublishing())
+			publishMessage(publishCh, routing, msg.ToAmqpPublishing(), position)
 			lastMsg = &msg
 		default:
 			return err
@@ -123,6 +143,13 @@ func publishMessageStream(publishCh rabtap.PublishChannel,
 	}
 }
 
+// printPublishStats prints the summary of the publish command with confirms
+// enabled.
+func printPublishStats(out io.Writer, stats rabtap.PublishStats) {
+	fmt.Fprintf(out, "published %d messages, %d confirmed, %d re-sent, %d failed\n",
+		stats.Published, stats.Confirmed, stats.Resent, stats.Failed)
+}
+
 // cmdPublish reads messages with the provied readNextMessageFunc and
 // publishes the messages to the given exchange.
 // Termination is a little bit tricky here, since we can not use "select"
@@ -132,12 +159,35 @@ func publishMessageStream(publishCh rabtap.PublishChannel,
 // * by ctx.Context() signaling cancellation (e.g. ctrl+c)
 // * by an initial connection failure to the broker
 func cmdPublish(ctx context.Context, cmd CmdPublishArg, logger *slog.Logger) error {
+	var deadLetters *deadLetterFile
+	if cmd.deadLetterFile != nil {
+		var err error
+		if deadLetters, err = newDeadLetterFile(*cmd.deadLetterFile); err != nil {
+			return err
+		}
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	resultCh := make(chan error, 1)
-	publisher := rabtap.NewAmqpPublish(cmd.amqpURL,
-		cmd.tlsConfig, cmd.mandatory, cmd.confirms, logger)
-	publishCh := make(rabtap.PublishChannel)
+	config := rabtap.AmqpPublishConfig{
+		Mandatory:     cmd.mandatory,
+		Confirms:      cmd.confirms,
+		ConfirmWindow: cmd.confirmWindow,
+		Retries:       cmd.retries,
+		RetryBackoff:  cmd.retryBackoff,
+	}
+	publishers := make([]*rabtap.AmqpPublish, max(cmd.parallel, 1))
+	publisherChs := make([]rabtap.PublishChannel, len(publishers))
+	for i := range publishers {
+		publishers[i] = rabtap.NewAmqpPublish(config, cmd.amqpURL, cmd.tlsConfig, logger)
+		publisherChs[i] = make(rabtap.PublishChannel)
+	}
+	publishCh := publisherChs[0]
+	if len(publishers) > 1 {
+		publishCh = make(rabtap.PublishChannel)
+		go dispatchByRoutingKey(ctx, publishCh, publisherChs)
+	}
 	errorCh := make(rabtap.PublishErrorChannel)
 
 	delayFunc := func(first, second *RabtapPersistentMessage) {
@@ -151,6 +201,12 @@ func cmdPublish(ctx context.Context, cmd CmdPublishArg, logger *slog.Logger) err
 		case <-ctx.Done():
 		}
 	}
+	if cmd.rate > 0 {
+		limiter := newTokenBucket(cmd.rate)
+		delayFunc = func(_, _ *RabtapPersistentMessage) {
+			_ = limiter.Wait(ctx)
+		}
+	}
 
 	go func() {
 		// runs as long as source returns messages. Unfortunately, we
@@ -164,10 +220,29 @@ func cmdPublish(ctx context.Context, cmd CmdPublishArg, logger *slog.Logger) err
 
 	g.Go(func() error {
 		numPublishErrors := 0
-		// log all publishing errors
+		// log all publishing errors and keep the failed messages. Channel
+		// errors are followed by a reconnect and are no failed messages.
 		for err := range errorCh {
+			if !err.IsMessageError() {
+				logger.Warn("publishing channel closed", "error", err)
+				continue
+			}
 			numPublishErrors++
 			logger.Error("publishing error", "error", err)
+			if deadLetters != nil {
+				if err := deadLetters.Write(err); err != nil {
+					logger.Error("dead-letter file", "error", err)
+				}
+			}
+		}
+		if deadLetters != nil {
+			if err := deadLetters.Close(); err != nil {
+				logger.Error("dead-letter file", "error", err)
+			}
+			if deadLetters.count > 0 {
+				return fmt.Errorf("published with errors, %d failed messages written to %s",
+					deadLetters.count, *cmd.deadLetterFile)
+			}
 		}
 		if numPublishErrors > 0 {
 			return fmt.Errorf("published with errors")
@@ -176,13 +251,27 @@ func cmdPublish(ctx context.Context, cmd CmdPublishArg, logger *slog.Logger) err
 	})
 
 	g.Go(func() error {
-		err := publisher.EstablishConnection(ctx, publishCh, errorCh)
+		pg, ctx := errgroup.WithContext(ctx)
+		for i, publisher := range publishers {
+			pg.Go(func() error {
+				return publisher.EstablishConnection(ctx, publisherChs[i], errorCh)
+			})
+		}
+		err := pg.Wait()
 		logger.Info("publisher ending")
 		close(errorCh)
 		return err
 	})
 
-	if err := g.Wait(); err != nil {
+	err := g.Wait()
+	var stats rabtap.PublishStats
+	for _, publisher := range publishers {
+		stats = stats.Add(publisher.Stats())
+	}
+	if cmd.confirms {
+		printPublishStats(cmd.out, stats)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"os"
@@ -112,6 +113,7 @@ func TestPublishMessageStreamPublishesNextMessage(t *testing.T) {
 		assert.Equal(t, "exchange", message.Routing.Exchange())
 		assert.Equal(t, "key", message.Routing.Key())
 		assert.Equal(t, "hello", string(message.Publishing.Body))
+		assert.Equal(t, int64(1), message.Position)
 	case <-time.After(time.Second * 2):
 		assert.Fail(t, "did not receive message within expected time")
 	}
@@ -137,6 +139,14 @@ func TestPublishMessageStreamPropagatesMessageReadError(t *testing.T) {
 	assert.Equal(t, errors.New("error"), err)
 }
 
+func TestPrintPublishStatsIncludesConfirmations(t *testing.T) {
+	stats := rabtap.PublishStats{Published: 10, Confirmed: 9, Resent: 3, Failed: 1}
+
+	var out bytes.Buffer
+	printPublishStats(&out, stats)
+	assert.Equal(t, "published 10 messages, 9 confirmed, 3 re-sent, 1 failed\n", out.String())
+}
+
 func TestCmdPublishARawFileWithExchangeAndRoutingKey(t *testing.T) {
 	// integrative test publishing a raw file
 
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"log/slog"
 	"net/url"
@@ -31,6 +32,15 @@ type CmdQueueBindArg struct {
 	tlsConfig  *tls.Config
 }
 
+// CmdQueuePeekArg contains the arguments for cmdQueuePeek
+type CmdQueuePeekArg struct {
+	amqpURL     *url.URL
+	queue       string
+	limit       int64
+	tlsConfig   *tls.Config
+	messageSink MessageSink
+}
+
 func amqpHeaderRoutingMode(mode HeaderMode) string {
 	modes := map[HeaderMode]string{
 		HeaderMatchAny: "any",
@@ -77,6 +87,22 @@ func cmdQueuePurge(amqpURL *url.URL, queueName string, tlsConfig *tls.Config, lo
 		})
 }
 
+// cmdQueuePeek shows messages of a queue without removing them. Messages are
+// not acknowledged and are returned to the queue when the connection is
+// closed, so other consumers are not disturbed.
+func cmdQueuePeek(ctx context.Context, cmd CmdQueuePeekArg, logger *slog.Logger) error {
+	return rabtap.SimpleAmqpConnector(cmd.amqpURL,
+		cmd.tlsConfig,
+		func(session rabtap.Session) error {
+			logger.Debug("peeking queue", "queue", cmd.queue, "limit", cmd.limit)
+			num, err := rabtap.PeekQueue(ctx, session, cmd.queue, cmd.limit, cmd.messageSink)
+			if err == nil {
+				logger.Info("peeked queue", "num_messages", num, "queue", cmd.queue)
+			}
+			return err
+		})
+}
+
 // cmdQueueBindToExchange binds a queue to an exchange
 func cmdQueueBindToExchange(cmd CmdQueueBindArg, logger *slog.Logger) error {
 	return rabtap.SimpleAmqpConnector(cmd.amqpURL, cmd.tlsConfig,
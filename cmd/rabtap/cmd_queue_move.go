@@ -0,0 +1,273 @@
+// rabtap queue move command
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// defaultMoveIdleTimeout is the time queue move waits for further messages,
+// e.g. when other consumers took messages from the queue.
+const defaultMoveIdleTimeout = 5 * time.Second
+
+// moveConfirmWindow is the maximum number of moved messages waiting for their
+// confirmation.
+const moveConfirmWindow = 100
+
+// CmdQueueMoveArg contains the arguments for cmdQueueMove
+type CmdQueueMoveArg struct {
+	amqpURL   *url.URL
+	tlsConfig *tls.Config
+	queue     string
+	// exchange and routingKey are the target of the messages. If routingKey
+	// is nil, the routing key of the message is kept.
+	exchange     string
+	routingKey   *string
+	filterPred   Predicate
+	limit        int64
+	transformers []MessageTransformer
+	idleTimeout  time.Duration
+	out          io.Writer
+}
+
+// moveStats counts the messages processed by queue move
+type moveStats struct {
+	moved   int64
+	skipped int64 // did not match the filter
+	failed  int64
+}
+
+// cmdQueueMove moves the messages present in a queue to an exchange. Each
+// message is acknowledged after the broker confirmed the republished message.
+// Messages not matching the filter or failed to be republished are kept
+// unacknowledged and requeued once when the move ends, so they are returned
+// to the queue in their original position.
+func cmdQueueMove(ctx context.Context, cmd CmdQueueMoveArg, logger *slog.Logger) error {
+	var numMessages int
+	err := rabtap.SimpleAmqpConnector(cmd.amqpURL, cmd.tlsConfig,
+		func(session rabtap.Session) error {
+			var err error
+			numMessages, err = rabtap.QueueMessageCount(session, cmd.queue)
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("queue %s: %w", cmd.queue, err)
+	}
+	logger.Debug("moving messages", "queue", cmd.queue, "num_messages", numMessages,
+		"exchange", cmd.exchange, "routingkey", cmd.routingKey)
+
+	// skipped and failed messages stay unacknowledged until the move ends,
+	// so the number of unacknowledged messages can not be limited.
+	config := rabtap.AmqpSubscriberConfig{PrefetchCount: -1}
+	subscriber := rabtap.NewAmqpSubscriber(config, cmd.amqpURL, cmd.tlsConfig, logger)
+	publishConfig := rabtap.AmqpPublishConfig{Mandatory: true, Confirms: true, ConfirmWindow: moveConfirmWindow}
+	publisher := rabtap.NewAmqpPublish(publishConfig, cmd.amqpURL, cmd.tlsConfig, logger)
+
+	ctx, cancel := context.WithCancel(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+
+	messageCh := make(rabtap.TapChannel)
+	subscribeErrorCh := make(rabtap.SubscribeErrorChannel)
+	publishCh := make(rabtap.PublishChannel)
+	publishErrorCh := make(rabtap.PublishErrorChannel)
+
+	g.Go(func() error {
+		return subscriber.EstablishSubscription(ctx, cmd.queue, messageCh, subscribeErrorCh)
+	})
+	g.Go(func() error {
+		err := publisher.EstablishConnection(ctx, publishCh, publishErrorCh)
+		close(publishErrorCh)
+		return err
+	})
+	g.Go(func() error {
+		for err := range publishErrorCh {
+			logger.Error("publishing error", "error", err)
+		}
+		return nil
+	})
+
+	var stats moveStats
+	g.Go(func() error {
+		var err error
+		stats, err = moveMessages(ctx, cmd, numMessages, messageCh, subscribeErrorCh, publishCh, logger)
+		close(publishCh)
+		cancel()
+		return err
+	})
+
+	err = g.Wait()
+	fmt.Fprintf(cmd.out, "moved %d messages from queue %s, %d skipped, %d failed\n",
+		stats.moved, cmd.queue, stats.skipped, stats.failed)
+	if err != nil {
+		return fmt.Errorf("move failed: %w", err)
+	}
+	if stats.failed > 0 {
+		return fmt.Errorf("%d messages could not be moved", stats.failed)
+	}
+	return nil
+}
+
+// moveResult is the outcome of the republishing of a message
+type moveResult struct {
+	message rabtap.TapMessage
+	err     error
+}
+
+// heldMessages are the skipped and failed messages, which are kept
+// unacknowledged until the move ends. Requeuing them right away would make
+// the broker deliver them again immediately.
+type heldMessages struct {
+	last *rabtap.TapMessage // message with the highest delivery tag
+}
+
+func (s *heldMessages) hold(message rabtap.TapMessage) {
+	if s.last == nil || message.AmqpMessage.DeliveryTag > s.last.AmqpMessage.DeliveryTag {
+		s.last = &message
+	}
+}
+
+// requeue returns all held messages to the queue at once, keeping their
+// original position. Must only be called when no other messages are
+// unacknowledged.
+func (s *heldMessages) requeue() error {
+	if s.last == nil {
+		return nil
+	}
+	if err := s.last.AmqpMessage.Nack(true, true); err != nil {
+		return fmt.Errorf("NACK failed: %w", err)
+	}
+	s.last = nil
+	return nil
+}
+
+// moveMessages moves the first numMessages messages received from messageCh,
+// or up to cmd.limit messages, to the target exchange. Up to
+// moveConfirmWindow messages are published before their confirmations are
+// awaited.
+func moveMessages(ctx context.Context, cmd CmdQueueMoveArg, numMessages int,
+	messageCh rabtap.TapChannel, errorCh rabtap.SubscribeErrorChannel,
+	publishCh rabtap.PublishChannel, logger *slog.Logger,
+) (moveStats, error) {
+	var stats moveStats
+	// results receives the outcome of all messages in flight without
+	// blocking the publisher, since at most moveConfirmWindow messages are
+	// in flight.
+	results := make(chan moveResult, moveConfirmWindow)
+	inFlight := 0
+	var held heldMessages
+	idle := time.NewTimer(cmd.idleTimeout)
+	defer idle.Stop()
+
+	received := 0
+	receiving := true
+	for receiving || inFlight > 0 {
+		if cmd.limit != InfiniteMessages && stats.moved+int64(inFlight) >= cmd.limit {
+			receiving = false
+		}
+		if received >= numMessages {
+			receiving = false
+		}
+		var in rabtap.TapChannel
+		var idleTimeout <-chan time.Time
+		if receiving && inFlight < moveConfirmWindow {
+			in, idleTimeout = messageCh, idle.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return stats, ctx.Err()
+
+		case err, more := <-errorCh:
+			if more {
+				logger.Error("subscriber error", "error", err)
+			}
+
+		case <-idleTimeout:
+			logger.Warn("no more messages received", "queue", cmd.queue,
+				"received", received, "expected", numMessages)
+			receiving = false
+
+		case result := <-results:
+			inFlight--
+			if result.err != nil {
+				// the error was already logged by the publisher
+				stats.failed++
+				held.hold(result.message)
+				continue
+			}
+			if err := result.message.AmqpMessage.Ack(false); err != nil {
+				return stats, fmt.Errorf("ACK failed: %w", err)
+			}
+			stats.moved++
+
+		case message, more := <-in:
+			if !more {
+				return stats, nil
+			}
+			received++
+			idle.Reset(cmd.idleTimeout)
+			pending, err := moveMessage(ctx, cmd, message, stats.moved, publishCh, results, logger)
+			if err != nil {
+				return stats, err
+			}
+			if pending {
+				inFlight++
+				continue
+			}
+			stats.skipped++
+			held.hold(message)
+		}
+	}
+	return stats, held.requeue()
+}
+
+// moveMessage republishes a single message, unless it is filtered out. The
+// outcome is sent to results when the broker confirmed the message, or when
+// the message could not be transformed. Returns false if the message was
+// filtered out.
+func moveMessage(ctx context.Context, cmd CmdQueueMoveArg, message rabtap.TapMessage,
+	count int64, publishCh rabtap.PublishChannel, results chan<- moveResult, logger *slog.Logger,
+) (bool, error) {
+	env := createMessagePredEnv(message, count)
+	passed, err := cmd.filterPred.Eval(env)
+	if err != nil {
+		logger.Error("filter expression evaluation failed", "error", err)
+	}
+	if !passed {
+		logger.Debug("message was filtered out", "message_id", message.AmqpMessage.MessageId)
+		return false, nil
+	}
+
+	m := NewRabtapPersistentMessage(message)
+	for _, transform := range cmd.transformers {
+		if m, err = transform(m); err != nil {
+			logger.Error("transform message failed", "error", err,
+				"message_id", message.AmqpMessage.MessageId)
+			results <- moveResult{message, err}
+			return true, nil
+		}
+	}
+	publishing := m.ToAmqpPublishing()
+	toPublish := &rabtap.PublishMessage{
+		Routing:    routingFromMessage(&cmd.exchange, cmd.routingKey, nil, m),
+		Publishing: &publishing,
+		Done:       func(err error) { results <- moveResult{message, err} },
+	}
+	select {
+	case publishCh <- toPublish:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
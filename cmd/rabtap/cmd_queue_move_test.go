@@ -0,0 +1,256 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// fakePublisher completes publishing of the messages received on publishCh
+// with the result returned by the result func.
+func fakePublisher(publishCh rabtap.PublishChannel, result func(*rabtap.PublishMessage) error) <-chan []*rabtap.PublishMessage {
+	done := make(chan []*rabtap.PublishMessage, 1)
+	go func() {
+		var published []*rabtap.PublishMessage
+		for message := range publishCh {
+			published = append(published, message)
+			message.Done(result(message))
+		}
+		done <- published
+	}()
+	return done
+}
+
+func sendTestDeliveries(messageCh rabtap.TapChannel, deliveries ...rabtap.TapMessage) {
+	go func() {
+		for _, d := range deliveries {
+			messageCh <- d
+		}
+	}()
+}
+
+func testMoveArg() CmdQueueMoveArg {
+	routingKey := "target"
+	return CmdQueueMoveArg{
+		queue:       "dlq",
+		exchange:    "",
+		routingKey:  &routingKey,
+		filterPred:  constantPred{true},
+		idleTimeout: time.Second,
+	}
+}
+
+func TestMoveMessagesAcksMessagesAfterConfirmation(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(m *rabtap.PublishMessage) error {
+		if string(m.Publishing.Body) == "2" {
+			return errors.New("nack")
+		}
+		return nil
+	})
+	deliveries := make([]rabtap.TapMessage, 3)
+	for i := range deliveries {
+		deliveries[i] = testDelivery(acknowledger, uint64(i+1))
+		deliveries[i].AmqpMessage.Body = []byte{byte('1' + i)}
+	}
+	sendTestDeliveries(messageCh, deliveries...)
+
+	stats, err := moveMessages(context.Background(), testMoveArg(), 3, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 2, failed: 1}, stats)
+	// the failed message is requeued when the move ends
+	assert.Equal(t, []string{"ack 1 multiple=false", "ack 3 multiple=false", "nack 2 multiple=true requeue=true"},
+		acknowledger.getCalls())
+	messages := <-published
+	require.Len(t, messages, 3)
+	assert.Equal(t, "", messages[0].Routing.Exchange())
+	assert.Equal(t, "target", messages[0].Routing.Key())
+}
+
+func TestMoveMessagesKeepsRoutingKeyOfMessageWhenNotSet(t *testing.T) {
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	delivery := testDelivery(&recordingAcknowledger{}, 1)
+	delivery.AmqpMessage.RoutingKey = "orders.eu"
+	sendTestDeliveries(messageCh, delivery)
+
+	cmd := testMoveArg()
+	cmd.exchange, cmd.routingKey = "amq.topic", nil
+	_, err := moveMessages(context.Background(), cmd, 1, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	messages := <-published
+	require.Len(t, messages, 1)
+	assert.Equal(t, "amq.topic", messages[0].Routing.Exchange())
+	assert.Equal(t, "orders.eu", messages[0].Routing.Key())
+}
+
+func TestMoveMessagesSkipsMessagesNotMatchingFilter(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	skipped := testDelivery(acknowledger, 1)
+	skipped.AmqpMessage.RoutingKey = "skip"
+	sendTestDeliveries(messageCh, skipped, testDelivery(acknowledger, 2))
+
+	cmd := testMoveArg()
+	cmd.filterPred, _ = NewExprPredicate("r.msg.RoutingKey != 'skip'")
+	stats, err := moveMessages(context.Background(), cmd, 2, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 1, skipped: 1}, stats)
+	assert.Equal(t, []string{"ack 2 multiple=false", "nack 1 multiple=true requeue=true"}, acknowledger.getCalls())
+	assert.Len(t, <-published, 1)
+}
+
+func TestMoveMessagesRequeuesSkippedMessagesOnceWhenMoveEnds(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	// identical messages are skipped and requeued each
+	first := testDelivery(acknowledger, 1)
+	first.AmqpMessage.RoutingKey = "skip"
+	second := testDelivery(acknowledger, 2)
+	second.AmqpMessage.RoutingKey = "skip"
+	sendTestDeliveries(messageCh, first, second, testDelivery(acknowledger, 3))
+
+	cmd := testMoveArg()
+	cmd.filterPred, _ = NewExprPredicate("r.msg.RoutingKey != 'skip'")
+	stats, err := moveMessages(context.Background(), cmd, 3, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 1, skipped: 2}, stats)
+	assert.Equal(t, []string{"ack 3 multiple=false", "nack 2 multiple=true requeue=true"},
+		acknowledger.getCalls())
+	assert.Len(t, <-published, 1)
+}
+
+func TestMoveMessagesPublishesMessagesBeforeTheyAreConfirmed(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	sendTestDeliveries(messageCh, testDelivery(acknowledger, 1), testDelivery(acknowledger, 2))
+
+	// confirm both messages only after both were published
+	go func() {
+		first, second := <-publishCh, <-publishCh
+		first.Done(nil)
+		second.Done(nil)
+	}()
+	stats, err := moveMessages(context.Background(), testMoveArg(), 2, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 2}, stats)
+	assert.Equal(t, []string{"ack 1 multiple=false", "ack 2 multiple=false"}, acknowledger.getCalls())
+}
+
+func TestMoveMessagesRequeuesMessagesFailingToTransform(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	sendTestDeliveries(messageCh, testDelivery(acknowledger, 1))
+
+	cmd := testMoveArg()
+	cmd.transformers = []MessageTransformer{func(m RabtapPersistentMessage) (RabtapPersistentMessage, error) {
+		return m, errors.New("transform failed")
+	}}
+	stats, err := moveMessages(context.Background(), cmd, 1, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{failed: 1}, stats)
+	assert.Equal(t, []string{"nack 1 multiple=true requeue=true"}, acknowledger.getCalls())
+	assert.Empty(t, <-published)
+}
+
+func TestMoveMessagesAppliesTransformers(t *testing.T) {
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	delivery := testDelivery(&recordingAcknowledger{}, 1)
+	delivery.AmqpMessage.Headers = amqp.Table{"x-death": []interface{}{}, "h": "v"}
+	sendTestDeliveries(messageCh, delivery)
+
+	cmd := testMoveArg()
+	cmd.transformers = []MessageTransformer{DeathHeadersTransformer}
+	_, err := moveMessages(context.Background(), cmd, 1, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	messages := <-published
+	require.Len(t, messages, 1)
+	assert.Equal(t, amqp.Table{"h": "v"}, messages[0].Routing.Headers())
+}
+
+func TestMoveMessagesStopsAtLimit(t *testing.T) {
+	acknowledger := &recordingAcknowledger{}
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	sendTestDeliveries(messageCh, testDelivery(acknowledger, 1), testDelivery(acknowledger, 2))
+
+	cmd := testMoveArg()
+	cmd.limit = 1
+	stats, err := moveMessages(context.Background(), cmd, 5, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 1}, stats)
+	assert.Len(t, <-published, 1)
+}
+
+func TestMoveMessagesStopsWhenNoMoreMessagesAreReceived(t *testing.T) {
+	messageCh := make(rabtap.TapChannel)
+	publishCh := make(rabtap.PublishChannel)
+	published := fakePublisher(publishCh, func(*rabtap.PublishMessage) error { return nil })
+	sendTestDeliveries(messageCh, testDelivery(&recordingAcknowledger{}, 1))
+
+	cmd := testMoveArg()
+	cmd.idleTimeout = 50 * time.Millisecond
+	stats, err := moveMessages(context.Background(), cmd, 10, messageCh,
+		make(rabtap.SubscribeErrorChannel), publishCh, slog.New(slog.DiscardHandler))
+	close(publishCh)
+
+	require.NoError(t, err)
+	assert.Equal(t, moveStats{moved: 1}, stats)
+	assert.Len(t, <-published, 1)
+}
+
+func TestMoveMessagesReturnsErrorWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := moveMessages(ctx, testMoveArg(), 1, make(rabtap.TapChannel),
+		make(rabtap.SubscribeErrorChannel), make(rabtap.PublishChannel), slog.New(slog.DiscardHandler))
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -4,14 +4,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"os"
 	"testing"
 	"time"
 
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -92,3 +95,43 @@ func TestIntegrationCmdQueueCreatePurgeiBindUnbindQueue(t *testing.T) {
 
 	// TODO check that queue is removed
 }
+
+func TestIntegrationCmdQueueMoveMovesAllMessages(t *testing.T) {
+	const srcQueue = "move-src-queue-test"
+	const dstQueue = "move-dst-queue-test"
+	logger := slog.New(slog.DiscardHandler)
+	tlsConfig := &tls.Config{}
+	amqpURL := testcommon.IntegrationURIFromEnv()
+
+	for _, queue := range []string{srcQueue, dstQueue} {
+		require.NoError(t, cmdQueueCreate(CmdQueueCreateArg{amqpURL: amqpURL, queue: queue, tlsConfig: tlsConfig}, logger))
+		defer cmdQueueRemove(amqpURL, queue, tlsConfig, logger)
+	}
+	conn, ch := testcommon.IntegrationTestConnection(t, "", "", 0, false)
+	defer conn.Close()
+	testcommon.PublishTestMessages(t, ch, 3, "", srcQueue, amqp.Table{"x-death": []interface{}{}})
+	time.Sleep(time.Second)
+
+	var out bytes.Buffer
+	routingKey := dstQueue
+	err := cmdQueueMove(context.Background(), CmdQueueMoveArg{
+		amqpURL:      amqpURL,
+		tlsConfig:    tlsConfig,
+		queue:        srcQueue,
+		routingKey:   &routingKey,
+		filterPred:   constantPred{true},
+		transformers: []MessageTransformer{DeathHeadersTransformer},
+		idleTimeout:  time.Second,
+		out:          &out,
+	}, logger)
+
+	require.NoError(t, err)
+	assert.Equal(t, "moved 3 messages from queue move-src-queue-test, 0 skipped, 0 failed\n", out.String())
+	session := rabtap.Session{Connection: conn, Channel: ch}
+	num, err := rabtap.QueueMessageCount(session, srcQueue)
+	require.NoError(t, err)
+	assert.Equal(t, 0, num)
+	num, err = rabtap.QueueMessageCount(session, dstQueue)
+	require.NoError(t, err)
+	assert.Equal(t, 3, num)
+}
@@ -18,34 +18,128 @@ import (
 
 // CmdSubscribeArg contains arguments for the subscribe command
 type CmdSubscribeArg struct {
-	amqpURL     *url.URL
-	queue       string
-	tlsConfig   *tls.Config
-	messageSink MessageSink
-	termPred    Predicate
-	filterPred  Predicate
-	reject      bool
-	requeue     bool
-	args        rabtap.KeyValueMap
-	timeout     time.Duration
+	// subscriptions are the queues to subscribe to. Messages of all queues
+	// are processed together.
+	subscriptions []subscription
+	tlsConfig     *tls.Config
+	messageSink   MessageSink
+	termPred      Predicate
+	filterPred    Predicate
+	reject        bool
+	requeue       bool
+	args          rabtap.KeyValueMap
+	timeout       time.Duration
+	// streamConfig is set when the stream protocol is used
+	streamConfig *rabtap.StreamSubscriberConfig
+	// consumerName is used as consumer tag. When reading a stream with AMQP,
+	// the last processed offset is saved under this name in offsetStateDir.
+	consumerName   string
+	resume         bool
+	offsetStateDir string
+	// prefetch is the AMQP prefetch count. If greater than 1, messages are
+	// acknowledged in batches of prefetch messages or after ackInterval.
+	prefetch    int
+	ackInterval time.Duration
 }
 
-// cmdSub subscribes to messages from the given queue
-func cmdSubscribe(ctx context.Context, cmd CmdSubscribeArg, logger *slog.Logger) error {
-	ctx, cancel := context.WithCancel(ctx)
-	g, ctx := errgroup.WithContext(ctx)
+// subscription is a queue on a broker to subscribe to
+type subscription struct {
+	amqpURL *url.URL
+	queue   string
+}
+
+// subscriber is implemented by the AMQP and the stream protocol subscriber
+type subscriber interface {
+	EstablishSubscription(ctx context.Context, queue string,
+		tapCh rabtap.TapChannel, errCh rabtap.SubscribeErrorChannel) error
+}
 
+// newSubscriber returns the subscriber for the given subscription. When
+// resuming a stream consumed with AMQP, the stream offset is read from the
+// offset state file.
+func newSubscriber(cmd CmdSubscribeArg, sub subscription, state offsetStateFile, logger *slog.Logger) (subscriber, error) {
+	if cmd.streamConfig != nil {
+		return rabtap.NewStreamSubscriber(*cmd.streamConfig, sub.amqpURL, cmd.tlsConfig, logger), nil
+	}
+	args := rabtap.ToAMQPTable(cmd.args)
+	if cmd.consumerName != "" && cmd.resume {
+		key, err := offsetStateKey(sub)
+		if err != nil {
+			return nil, err
+		}
+		offset, ok, err := state.Load(key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			logger.Info("resuming stream", "queue", sub.queue, "offset", offset+1)
+			args[rabtap.StreamOffsetHeader] = offset + 1
+		}
+	}
 	config := rabtap.AmqpSubscriberConfig{
-		Exclusive: false,
-		Args:      rabtap.ToAMQPTable(cmd.args),
+		Exclusive:     false,
+		Args:          args,
+		ConsumerTag:   cmd.consumerName,
+		PrefetchCount: cmd.prefetch,
+	}
+	return rabtap.NewAmqpSubscriber(config, sub.amqpURL, cmd.tlsConfig, logger), nil
+}
+
+// cmdSub subscribes to messages from the given queues
+func cmdSubscribe(ctx context.Context, cmd CmdSubscribeArg, logger *slog.Logger) error {
+	acknowledger := CreateAcknowledgeFunc(cmd.reject, cmd.requeue)
+	var batcher *ackBatcher
+	if cmd.prefetch > 1 {
+		batcher = newAckBatcher(cmd.reject, cmd.requeue, cmd.prefetch, cmd.ackInterval, logger)
+		acknowledger = batcher.Acknowledge
+	}
+	// only offsets of acknowledged messages are tracked. With batched acks,
+	// the offset is recorded when the batch was acknowledged.
+	trackOffsets := cmd.streamConfig == nil && cmd.consumerName != "" && !cmd.reject
+	state := newOffsetStateFile(cmd.offsetStateDir, cmd.consumerName)
+	processed := newProcessedOffsets()
+	if trackOffsets {
+		if batcher != nil {
+			batcher.acked = processed.record
+		} else {
+			acknowledger = newOffsetTrackingAcknowledgeFunc(acknowledger, processed)
+		}
+	}
+
+	subscribers := make([]subscriber, len(cmd.subscriptions))
+	keys := make([]string, len(cmd.subscriptions))
+	for i, sub := range cmd.subscriptions {
+		var err error
+		if subscribers[i], err = newSubscriber(cmd, sub, state, logger); err != nil {
+			return fmt.Errorf("subscribe failed: %w", err)
+		}
+		if trackOffsets {
+			if keys[i], err = offsetStateKey(sub); err != nil {
+				return fmt.Errorf("subscribe failed: %w", err)
+			}
+		}
 	}
-	subscriber := rabtap.NewAmqpSubscriber(config, cmd.amqpURL, cmd.tlsConfig, logger)
+
+	ctx, cancel := context.WithCancel(ctx)
+	g, ctx := errgroup.WithContext(ctx)
 
 	messageChannel := make(rabtap.TapChannel)
 	errorChannel := make(rabtap.SubscribeErrorChannel)
-	g.Go(func() error { return subscriber.EstablishSubscription(ctx, cmd.queue, messageChannel, errorChannel) })
+	for i, sub := range cmd.subscriptions {
+		subscriber := subscribers[i]
+		tapCh := messageChannel
+		if trackOffsets {
+			// offsets are recorded per subscription
+			tapCh = processed.newBindingTapChannel(ctx, keys[i], messageChannel)
+		}
+		g.Go(func() error {
+			if err := subscriber.EstablishSubscription(ctx, sub.queue, tapCh, errorChannel); err != nil {
+				return fmt.Errorf("queue %s: %w", sub.queue, err)
+			}
+			return nil
+		})
+	}
 	g.Go(func() error {
-		acknowledger := CreateAcknowledgeFunc(cmd.reject, cmd.requeue)
 		err := MessageReceiveLoop(ctx,
 			messageChannel,
 			errorChannel,
@@ -55,11 +149,22 @@ func cmdSubscribe(ctx context.Context, cmd CmdSubscribeArg, logger *slog.Logger)
 			acknowledger,
 			cmd.timeout,
 			logger)
+		if batcher != nil {
+			if err := batcher.Flush(); err != nil {
+				logger.Error("acknowledge failed", "error", err)
+			}
+		}
 		cancel()
 		return err
 	})
 
-	if err := g.Wait(); err != nil && err != ErrIdleTimeout {
+	err := g.Wait()
+	if trackOffsets {
+		if saveErr := saveProcessedOffsets(state, processed, logger); saveErr != nil {
+			logger.Error("saving stream offset failed", "error", saveErr)
+		}
+	}
+	if err != nil && err != ErrIdleTimeout {
 		return fmt.Errorf("subscribe failed: %w", err)
 	}
 	return nil
@@ -32,12 +32,11 @@ func TestCmdSubFailsEarlyWhenBrokerIsNotAvailable(t *testing.T) {
 	go func() {
 		// we expect cmdSubscribe to return
 		err := cmdSubscribe(ctx, CmdSubscribeArg{
-			amqpURL:     amqpURL,
-			queue:       "queue",
-			tlsConfig:   &tls.Config{},
-			messageSink: func(rabtap.TapMessage) error { return nil },
-			termPred:    &constantPred{false},
-			timeout:     time.Second * 10,
+			subscriptions: []subscription{{amqpURL: amqpURL, queue: "queue"}},
+			tlsConfig:     &tls.Config{},
+			messageSink:   func(rabtap.TapMessage) error { return nil },
+			termPred:      &constantPred{false},
+			timeout:       time.Second * 10,
 		}, logger)
 		require.Error(t, err)
 		done <- true
@@ -83,13 +82,12 @@ func TestCmdSub(t *testing.T) {
 	// subscribe to testQueue
 	go func() {
 		err := cmdSubscribe(ctx, CmdSubscribeArg{
-			amqpURL:     amqpURL,
-			queue:       testQueue,
-			tlsConfig:   tlsConfig,
-			messageSink: receiveFunc,
-			filterPred:  constantPred{true},
-			termPred:    constantPred{false},
-			timeout:     time.Second * 10,
+			subscriptions: []subscription{{amqpURL: amqpURL, queue: testQueue}},
+			tlsConfig:     tlsConfig,
+			messageSink:   receiveFunc,
+			filterPred:    constantPred{true},
+			termPred:      constantPred{false},
+			timeout:       time.Second * 10,
 		}, logger)
 		require.ErrorIs(t, err, context.Canceled)
 	}()
@@ -6,8 +6,10 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -16,39 +18,151 @@ import (
 )
 
 type CmdTapArg struct {
-	tapConfig   []rabtap.TapConfiguration
-	tlsConfig   *tls.Config
-	messageSink MessageSink
-	termPred    Predicate
-	filterPred  Predicate
-	timeout     time.Duration
+	tapConfig         []rabtap.TapConfiguration
+	tlsConfig         *tls.Config
+	messageSink       MessageSink
+	termPred          Predicate
+	filterPred        Predicate
+	timeout           time.Duration
+	apiClient         *rabtap.RabbitHTTPClient // optional, used for discovery and tracing
+	discoveryInterval time.Duration
+	fireHose          bool // tapping the FireHose, transform received messages
+}
+
+// enableFireHoseTracing enables the FireHose tracer on the given vhost, if it
+// is not already enabled. Returns a function that restores the original state.
+func enableFireHoseTracing(ctx context.Context,
+	client *rabtap.RabbitHTTPClient,
+	vhost string,
+	logger *slog.Logger,
+) (func(), error) {
+	vhosts, err := client.Vhosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(vhosts, func(v rabtap.RabbitVhost) bool { return v.Name == vhost })
+	if idx == -1 {
+		return nil, fmt.Errorf("vhost %s not found", vhost)
+	}
+	if vhosts[idx].Tracing {
+		return func() {}, nil
+	}
+
+	logger.Info("enabling FireHose tracing", "vhost", vhost)
+	if err := client.SetVhostTracing(ctx, vhost, true); err != nil {
+		return nil, err
+	}
+	return func() {
+		logger.Info("disabling FireHose tracing", "vhost", vhost)
+		// ctx is typically already cancelled at this point
+		if err := client.SetVhostTracing(context.Background(), vhost, false); err != nil {
+			logger.Error("disabling FireHose tracing failed", "vhost", vhost, "error", err)
+		}
+	}, nil
+}
+
+// transformTapChannel returns a channel which receives all messages from in,
+// transformed by the given transformer. If the transformation fails, the
+// original message is passed, so it is still acknowledged.
+func transformTapChannel(ctx context.Context,
+	in rabtap.TapChannel,
+	transform func(rabtap.TapMessage) (rabtap.TapMessage, error),
+	logger *slog.Logger,
+) rabtap.TapChannel {
+	out := make(rabtap.TapChannel)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-in:
+				transformed, err := transform(message)
+				if err != nil {
+					logger.Warn("message transformation failed", "error", err)
+					transformed = message
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- transformed:
+				}
+			}
+		}
+	}()
+	return out
 }
 
 // cmdTap taps to the given exchanges and displays or saves the received
-// messages.
-// TODO feature: discover bindings when no binding keys are given (-> discovery.go)
+// messages. Bindings of exchanges given without binding key are discovered
+// using the management API.
 func cmdTap(
 	ctx context.Context,
 	cmd CmdTapArg,
 	logger *slog.Logger,
 ) error {
+	if cmd.fireHose && len(cmd.tapConfig) > 1 {
+		// tracing is enabled through the management API of a single broker only
+		return errors.New("tap failed with: FireHose mode is not supported when tapping multiple brokers")
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	g, ctx := errgroup.WithContext(ctx)
 
 	tapMessageChannel := make(rabtap.TapChannel)
 	errorChannel := make(rabtap.SubscribeErrorChannel)
 
+	receiveChannel := tapMessageChannel
+	if cmd.fireHose {
+		receiveChannel = transformTapChannel(ctx, tapMessageChannel, FromFireHoseTapMessage, logger)
+		if cmd.apiClient != nil {
+			for _, config := range cmd.tapConfig {
+				vhost, err := rabtap.VhostFromURL(config.AMQPURL)
+				if err != nil {
+					cancel()
+					return fmt.Errorf("tap failed with: %w", err)
+				}
+				restore, err := enableFireHoseTracing(ctx, cmd.apiClient, vhost, logger)
+				if err != nil {
+					cancel()
+					return fmt.Errorf("tap failed with: enable tracing: %w", err)
+				}
+				defer restore()
+			}
+		} else {
+			logger.Warn("no management API given (--api), FireHose tracing must already be " +
+				"enabled with 'rabbitmqctl trace_on', otherwise no messages are received")
+		}
+	}
+
 	for _, config := range cmd.tapConfig {
 		config := config
 		tap := rabtap.NewAmqpTap(config.AMQPURL, cmd.tlsConfig, logger)
+		if !config.NeedsDiscovery() {
+			g.Go(func() error {
+				return tap.EstablishTap(ctx, config.Exchanges, tapMessageChannel, errorChannel)
+			})
+			continue
+		}
 		g.Go(func() error {
-			return tap.EstablishTap(ctx, config.Exchanges, tapMessageChannel, errorChannel)
+			if cmd.apiClient == nil {
+				return fmt.Errorf("management API needed to discover bindings")
+			}
+			vhost, err := rabtap.VhostFromURL(config.AMQPURL)
+			if err != nil {
+				return err
+			}
+			discover := func(ctx context.Context) ([]rabtap.ExchangeConfiguration, error) {
+				return rabtap.DiscoverExchangeConfigurations(ctx, cmd.apiClient, vhost, config.Exchanges)
+			}
+			establish := func(ctx context.Context, exchanges []rabtap.ExchangeConfiguration) error {
+				return tap.EstablishTap(ctx, exchanges, tapMessageChannel, errorChannel)
+			}
+			return establishTapWithDiscovery(ctx, discover, establish, cmd.discoveryInterval, logger)
 		})
 	}
 	g.Go(func() error {
 		acknowledger := CreateAcknowledgeFunc(false, false) // ACK
 		err := MessageReceiveLoop(ctx,
-			tapMessageChannel,
+			receiveChannel,
 			errorChannel,
 			cmd.messageSink,
 			cmd.filterPred,
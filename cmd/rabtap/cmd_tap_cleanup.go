@@ -0,0 +1,54 @@
+// rabtap tap --cleanup command
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// tapCleanupMinIdle is the time a tap-queue must be idle before it is
+// considered orphaned.
+const tapCleanupMinIdle = 5 * time.Minute
+
+// cmdTapCleanup removes tap-exchanges and tap-queues which were left over by
+// rabtap instances that did not shut down properly. With dryRun set, the
+// orphans are only logged.
+func cmdTapCleanup(ctx context.Context, client *rabtap.RabbitHTTPClient, dryRun bool, logger *slog.Logger) error {
+	brokerInfo, err := client.BrokerInfo(ctx)
+	if err != nil {
+		return err
+	}
+	orphans := rabtap.FindOrphanedTaps(brokerInfo, tapCleanupMinIdle, time.Now())
+
+	var errs []error
+	for _, queue := range orphans.Queues {
+		if dryRun {
+			logger.Info("orphaned tap queue", "vhost", queue.Vhost, "queue", queue.Name)
+			continue
+		}
+		if err := client.DeleteQueue(ctx, queue.Vhost, queue.Name); err != nil {
+			errs = append(errs, fmt.Errorf("remove queue %s: %w", queue.Name, err))
+			continue
+		}
+		logger.Info("removed orphaned tap queue", "vhost", queue.Vhost, "queue", queue.Name)
+	}
+	for _, exchange := range orphans.Exchanges {
+		if dryRun {
+			logger.Info("orphaned tap exchange", "vhost", exchange.Vhost, "exchange", exchange.Name)
+			continue
+		}
+		if err := client.DeleteExchange(ctx, exchange.Vhost, exchange.Name); err != nil {
+			errs = append(errs, fmt.Errorf("remove exchange %s: %w", exchange.Name, err))
+			continue
+		}
+		logger.Info("removed orphaned tap exchange", "vhost", exchange.Vhost, "exchange", exchange.Name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// newTapCleanupAPIMock returns a management API mock with an orphaned
+// tap-exchange and tap-queue and a tap in use. DELETE requests are recorded.
+func newTapCleanupAPIMock(deleted *[]string) *httptest.Server {
+	var mu sync.Mutex
+	resources := map[string]string{
+		"/overview": `{}`,
+		"/exchanges": `[{"name":"amq.topic","vhost":"/"},
+			{"name":"__tap-exchange-for-amq.topic-used","vhost":"/"},
+			{"name":"__tap-exchange-for-amq.topic-orphan","vhost":"/"}]`,
+		"/queues": `[{"name":"__tap-queue-for-amq.topic-used","vhost":"/","consumers":1},
+			{"name":"__tap-queue-for-amq.topic-orphan","vhost":"/","consumers":0,
+			 "idle_since":"2017-05-25 19:14:32"}]`,
+		"/bindings": `[{"source":"__tap-exchange-for-amq.topic-used","vhost":"/",
+			"destination":"__tap-queue-for-amq.topic-used","destination_type":"queue"}]`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			mu.Lock()
+			*deleted = append(*deleted, r.URL.RequestURI())
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		res, ok := resources[r.URL.Path]
+		if !ok {
+			res = "[]"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, res)
+	}))
+}
+
+func TestCmdTapCleanupRemovesOrphanedTaps(t *testing.T) {
+	var deleted []string
+	mock := newTapCleanupAPIMock(&deleted)
+	defer mock.Close()
+	apiURL, _ := url.Parse(mock.URL)
+	client := rabtap.NewRabbitHTTPClient(apiURL, &tls.Config{})
+
+	err := cmdTapCleanup(context.TODO(), client, false, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/queues/%2F/__tap-queue-for-amq.topic-orphan",
+		"/exchanges/%2F/__tap-exchange-for-amq.topic-orphan",
+	}, deleted)
+}
+
+func TestCmdTapCleanupRemovesNothingInDryRunMode(t *testing.T) {
+	var deleted []string
+	mock := newTapCleanupAPIMock(&deleted)
+	defer mock.Close()
+	apiURL, _ := url.Parse(mock.URL)
+	client := rabtap.NewRabbitHTTPClient(apiURL, &tls.Config{})
+
+	err := cmdTapCleanup(context.TODO(), client, true, slog.New(slog.DiscardHandler))
+
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
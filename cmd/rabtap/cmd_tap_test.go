@@ -89,6 +89,21 @@ func TestCmdTap(t *testing.T) {
 	<-done
 }
 
+func TestCmdTapWithFireHoseFailsWithMultipleBrokers(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	tapConfig := []rabtap.TapConfiguration{
+		{AMQPURL: testcommon.IntegrationURIFromEnv()},
+		{AMQPURL: testcommon.IntegrationURIFromEnv()},
+	}
+
+	err := cmdTap(context.Background(), CmdTapArg{
+		tapConfig: tapConfig,
+		fireHose:  true,
+	}, logger)
+
+	assert.ErrorContains(t, err, "not supported when tapping multiple brokers")
+}
+
 func TestCmdTapIntegration(t *testing.T) {
 	const testMessage = "TapHello"
 	const testQueue = "tap-queue-test"
@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -41,16 +42,37 @@ const (
 Usage:
   rabtap info [--api=APIURI] [--consumers] [--stats] [--filter=EXPR] [--omit-empty]
               [--show-default] [--mode=MODE] [--format=FORMAT] [TLSOPTIONS] [COMMON OPTIONS]
-  rabtap tap EXCHANGES [--uri=URI] [--saveto=DIR] [--format=FORMAT|--json]  [--limit=NUM]
-              [--idle-timeout=DURATION] [--filter=EXPR] [--silent] [TLSOPTIONS] [COMMON OPTIONS]
-  rabtap (tap --uri=URI EXCHANGES)... [--saveto=DIR] [--format=FORMAT|--json]  [--limit=NUM]
-              [--idle-timeout=DURATION] [--filter=EXPR] [--silent] [TLSOPTIONS] [COMMON OPTIONS]
-  rabtap sub QUEUE [--uri URI] [--saveto=DIR] [--format=FORMAT|--json] [--limit=NUM]
-              [--offset=OFFSET] [--args=KV]... [(--reject [--requeue])] [--silent]
-              [--filter=EXPR] [--idle-timeout=DURATION] [TLSOPTIONS] [COMMON OPTIONS]
-  rabtap pub  [--uri=URI] [SOURCE] [--exchange=EXCHANGE] [--format=FORMAT|--json]
-              [--routingkey=KEY | (--header=KV)...] [ (--property=KV)... ] [--confirms]
-              [--mandatory] [--delay=DURATION | --speed=FACTOR] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap tap EXCHANGES [--uri=URI] [--api=APIURI] [--saveto=DIR [--keep-charset]]
+              [--format=FORMAT|--json] [--limit=NUM] [--idle-timeout=DURATION] [--filter=EXPR]
+              [--silent] [--template=TEMPLATE] [DECODEOPTIONS] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap (tap --uri=URI EXCHANGES)... [--saveto=DIR [--keep-charset]] [--format=FORMAT|--json]
+              [--limit=NUM] [--idle-timeout=DURATION] [--filter=EXPR] [--silent]
+              [--template=TEMPLATE] [DECODEOPTIONS] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap tap --firehose [--exchanges=LIST] [--queues=LIST] [--uri=URI] [--api=APIURI]
+              [--saveto=DIR [--keep-charset]] [--format=FORMAT|--json] [--limit=NUM]
+              [--idle-timeout=DURATION] [--filter=EXPR] [--silent] [--template=TEMPLATE]
+              [DECODEOPTIONS] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap tap --cleanup [--api=APIURI] [--dry-run] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap sub QUEUES [--uri URI] [--api=APIURI] [--saveto=DIR [--keep-charset]]
+              [--format=FORMAT|--json]
+              [--limit=NUM] [--offset=OFFSET] [--args=KV]... [(--reject [--requeue])]
+              [--silent] [--stream [--stream-filter=LIST]] [(--consumer-name=NAME [--resume])]
+              [--prefetch=N [--ack-interval=DURATION]] [--filter=EXPR]
+              [--idle-timeout=DURATION] [--template=TEMPLATE] [DECODEOPTIONS] [TLSOPTIONS]
+              [COMMON OPTIONS]
+  rabtap (sub --uri=URI QUEUES)... [--api=APIURI] [--saveto=DIR [--keep-charset]]
+              [--format=FORMAT|--json]
+              [--limit=NUM] [--offset=OFFSET] [--args=KV]... [(--reject [--requeue])]
+              [--silent] [--stream [--stream-filter=LIST]] [(--consumer-name=NAME [--resume])]
+              [--prefetch=N [--ack-interval=DURATION]] [--filter=EXPR]
+              [--idle-timeout=DURATION] [--template=TEMPLATE] [DECODEOPTIONS] [TLSOPTIONS]
+              [COMMON OPTIONS]
+  rabtap pub  [--uri=URI] [SOURCE | (--generate=TEMPLATE [--count=N])] [--exchange=EXCHANGE]
+              [--format=FORMAT|--json] [--map=MAPPING | --split=MODE]
+              [--routingkey=KEY | (--header=KV)...] [ (--property=KV)... ]
+              [(--confirms [--confirm-window=N] [--retry=N [--retry-backoff=DURATION]])]
+              [--dead-letter-file=FILE] [--encode-body] [--mandatory] [--parallel=N]
+              [--delay=DURATION | --speed=FACTOR | --rate=RATE] [TLSOPTIONS] [COMMON OPTIONS]
   rabtap exchange create EXCHANGE [--uri=URI] [--type=TYPE] [--args=KV]...
               [--autodelete] [--durable] [TLSOPTIONS] [COMMON OPTIONS]
   rabtap exchange bind EXCHANGE to DESTEXCHANGE [--uri=URI]
@@ -64,6 +86,11 @@ Usage:
               (--bindingkey=KEY | (--header=KV)... (--all|--any)) [TLSOPTIONS] [COMMON OPTIONS]
   rabtap queue rm QUEUE [--uri=URI] [TLSOPTIONS] [COMMON OPTIONS]
   rabtap queue purge QUEUE [--uri=URI] [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap queue move QUEUE (to DESTQUEUE | --exchange=EXCHANGE [--routingkey=KEY]) [--uri=URI]
+              [--filter=EXPR] [--limit=NUM] [(--property=KV)...] [--strip-x-death]
+              [--transform=NAME]... [TLSOPTIONS] [COMMON OPTIONS]
+  rabtap queue peek QUEUE [--uri=URI] [--limit=NUM] [--saveto=DIR [--keep-charset]]
+              [--format=FORMAT] [--silent] [--body=MODE] [TLSOPTIONS] [COMMON OPTIONS]
   rabtap conn close CONNECTION [--api=APIURI] [--reason=REASON] [TLSOPTIONS] [COMMON OPTIONS]
   rabtap --version
   rabtap (-h | --help | help) [properties]
@@ -71,64 +98,138 @@ Usage:
 	options = `
 Arguments and options:
  EXCHANGES            comma-separated list of exchanges and optional binding keys,
-                      e.g. 'amq.topic:#' or 'exchange1:key1,exchange2:key2'
+                      e.g. 'amq.topic:#' or 'exchange1:key1,exchange2:key2'. Headers
+                      exchanges are bound with headers, e.g. 'ex:x-match=any,h1=v1,h2=v2'.
+                      If the binding key is omitted (e.g. 'exchange1'), the bindings
+                      are discovered using the management API (see --api). Exchanges
+                      can be selected with a glob pattern or a regular expression
+                      prefixed with 're:', e.g. 'orders.*:#' or 're:^billing\..*$:#'
  EXCHANGE             name of an exchange, e.g. 'amq.direct'
  DESTEXCHANGE         name of a a destination exchange in an exchange-to-exchange binding
+ DESTQUEUE            name of a destination queue
  SOURCE               file or directory to publish in pub mode. If omitted, stdin will be read
  QUEUE                name of a queue
+ QUEUES               comma-separated list of queues, e.g. 'q1,q2'. Glob patterns like
+                      'orders.*.dlq' or regular expressions like 're:^orders\..*$' select
+                      the queues using the management API
  CONNECTION           name of a connection
  DIR                  directory to read messages from
  DURATION             a numerical duration with a unit suffix like "ms", "s", "m", "h"
  -a, --autodelete     create auto delete exchange/queue
+ --ack-interval=DURATION maximum time to wait before batched acknowledgements are
+                      sent (see --prefetch) [default: 200ms]
  --all                set x-match=all option in header based routing
  --any                set x-match=any option in header based routing
  --api=APIURI         connect to given API server. If APIURL is omitted, the environment
-                      variable RABTAP_APIURI will be used
+                      variable RABTAP_APIURI will be used. Used by the tap and sub
+                      commands only to discover exchanges, bindings and queues, to enable
+                      tracing and to clean up
  --args=KV            A key value pair in the form of "key=value" passed as additional
                       arguments. e.g. '--args=x-queue-type=quorum'
  -b, --bindingkey=KEY binding key to use in bind queue command
  --by-connection      output of info command starts with connections
+ --cleanup            remove tap-exchanges and tap-queues left over by rabtap instances
+                      which did not shut down properly. Uses the management API
  --confirms           enable publisher confirms and wait for confirmations
+ --confirm-window=N   maximum number of published messages waiting for their
+                      confirmation [default: 1]
  --consumers          include consumers and connections in output of info command
+ --consumer-name=NAME name of the sub consumer. The offset of the last message read from
+                      a stream is stored under this name on the broker (with --stream)
+                      or in a local state file
+ --dead-letter-file=FILE write messages which could not be published in JSON format to
+                      FILE, which can be published again with 'pub --format=json FILE'
+ --count=N            number of messages to generate, 0 for an endless stream [default: 1]
  --delay=DURATION     Time to wait between sending messages during publish. If not set,
                       then messages will be delayed as recorded.
  -d, --durable        create a durable exchange/queue
+ --dry-run            only list the tap-exchanges and tap-queues to remove with --cleanup
+ --encode-body        encode JSON bodies as msgpack or CBOR, when the ContentType of the
+                      message is 'application/msgpack' or 'application/cbor'
  --exchange=EXCHANGE  optional exchange to publish to. If omitted, exchange will be taken
                       from message being published (see JSON message format)
+ --exchanges=LIST     comma-separated list of exchanges to tap published messages of in
+                      FireHose mode. Use 'amq.default' for the default exchange
+ --firehose           tap messages using the RabbitMQ FireHose tracer. If the management
+                      API is available (see --api), tracing is enabled during the tap
  --filter=EXPR        Predicate for sub, tap, info command to filter the output [default: true]
  --format=FORMAT      for tap, pub, sub command: format to write/read messages to console
                         and optionally to file (when --saveto DIR is given).
                         Valid options are: 'raw', 'json', 'json-nopp'. Default: 'raw'
+                      for pub command additionally: 'ndjson-body' (each line is a message
+                        body) and 'csv' (each row is a message, see --map)
                       for info command: controls generated output format. Valid options
                         are: 'text', 'dot'. Default: 'text'
+ --generate=TEMPLATE  publish messages generated from a Go template, or from the template in
+                      FILE when given as '@FILE'. Routing key and headers are templates then
  -h, --help           prints this help
  --header=KV          A key value pair in the form of "key=value" used as a routing- or
                       binding-key. Can occur multiple times
  --idle-timeout=DURATION end reading messages when no new message was received for the
                       given duration
  -j, --json           deprecated. Use "--format=json" instead
+ --keep-charset       save message bodies with their original charset. By default, bodies
+                      are converted to UTF-8, if the charset of the ContentType differs
  --lazy               create a lazy queue
  --limit=NUM          Stop afer NUM messages were received. When set to 0, will run until
-                      terminated [default: 0]
+                      terminated or, in queue peek/move, until all messages were read [default: 0]
+ --map=MAPPING        comma-separated mapping of CSV columns to message fields, like
+                      'routingkey=col2,header.tenant=col3,body=col4'. Fields are 'body',
+                      'routingkey', 'exchange', 'header.NAME' and 'property.NAME'. Columns
+                      are given as colN or by their name in the header row
  --mandatory          enable mandatory publishing (messages must be delivered to queue)
  --mode=MODE          mode for info command. One of 'byConnection', 'byExchange' [default: byExchange]
  --omit-empty         don't show echanges without bindings in info command
  --offset=OFFSET      Offset when reading from a stream. Can be 'first', 'last', 'next',
                       a DURATION like '10m', a RFC3339-Timestamp or an integer index value.
                       Basically it is an alias for '--args=x-stream-offset=OFFSET'
+ --parallel=N         number of connections to publish messages on concurrently. Messages
+                      with the same exchange and routing key keep their order [default: 1]
+ --prefetch=N         number of messages the broker delivers in advance in sub command.
+                      When greater than 1, messages are acknowledged in batches of N
+                      messages [default: 1]
  --property=KV        A key value pair in the form of "key=value" to specify message properties
                       like e.g. the content-type.
  --queue-type=TYPE    type of queue [default: classic]
+ --queues=LIST        comma-separated list of queues to tap delivered messages of in
+                      FireHose mode
+ --rate=RATE          publish messages at a constant rate, e.g. '100/s', '500/m' or '10/h',
+                      instead of using the recorded delays
  --reason=REASON      reason why the connection was closed [default: closed by rabtap]
  --reject             Reject messages. Default behaviour is to acknowledge messages
  --requeue            Instruct broker to requeue rejected message
+ --retry=N            number of times a message is published again, when it was nacked,
+                      not confirmed in time or returned by the broker [default: 0]
+ --retry-backoff=DURATION delay before a failed message is published again, which is
+                      doubled with every retry up to 5m [default: 1s]
+ --resume             continue reading a stream after the last message read by the
+                      consumer given with --consumer-name. Falls back to --offset
  -r, --routingkey=KEY routing key to use in publish mode. If omitted, routing key
                       will be taken from message being published (see JSON message format)
  --saveto=DIR         also save messages and metadata to DIR
  --show-default       include default exchange in output info command
  -s, --silent         suppress message output to stdout
+ --split=MODE         split raw input into multiple messages. MODE is one of 'newline', 'nul',
+                      're:REGEX' (split at matches of REGEX), 'length-prefixed' (4 byte
+                      big-endian length before each message) or 'length-prefixed:varint'
  --speed=FACTOR       Speed factor to use during publish [default: 1.0]
  --stats              include statistics in output of info command
+ --strip-x-death      remove the x-death headers added by the broker when messages are
+                      dead-lettered
+ --stream             read a stream with the native RabbitMQ stream protocol instead of
+                      AMQP, which is much faster. The stream port 5552 (5551 with amqps)
+                      is used with an AMQP URI. Alternatively use a 'rabbitmq-stream://'
+                      or 'rabbitmq-stream+tls://' URI. Messages can not be rejected
+                      in stream mode
+ --stream-filter=LIST comma-separated list of filter values used by the broker to filter
+                      the stream in stream mode (RabbitMQ 3.13+)
+ --template=TEMPLATE  Go template used by the tap and sub command to print messages in raw
+                      format. Either one of the presets 'default', 'oneline' and 'markdown',
+                      the template in FILE when given as '@FILE' or the template itself
+ --transform=NAME     transform messages moved by queue move. NAME is one of 'firehose'
+                      (restore messages recorded from the FireHose tracer) or 'encode-body'
+                      (encode JSON bodies as msgpack or CBOR according to the ContentType).
+                      Can occur multiple times
  -t, --type=TYPE      type of exchange [default: fanout]
  --uri=URI            connect to given AQMP broker. If omitted, the environment variable
                       RABTAP_AMQPURI will be used
@@ -139,6 +240,21 @@ Common options:
  -n, --no-color       don't colorize output (see also environment variable NO_COLOR)
  -v, --verbose        enable verbose mode
 
+Decoding options:
+ --body=MODE              print message bodies in raw format as 'text', as 'hex' dump, 'base64'
+                          encoded or 'auto', which prints binary bodies as hex dump
+                          [default: auto]
+ --avro-schemas=LOCATION  decode Avro messages in tap and sub command using the schemas in
+                          the directory LOCATION (files ID.avsc or NAME.avsc) or of the
+                          schema registry with the URL LOCATION
+ --proto-descriptor=FILE  decode protobuf messages in tap and sub command using the message
+                          types of the FileDescriptorSet FILE, which is created with
+                          'protoc --include_imports --descriptor_set_out=FILE'
+ --proto-type=SOURCE      source of the message type of a protobuf message, either 'type'
+                          (the AMQP type property) or 'header:NAME' [default: type]
+ --proto-map=KV           maps a message type to the full name of a protobuf message, e.g.
+                          'order.created=shop.v1.OrderCreated'. Can occur multiple times
+
 TLS options:
  --tls-cert-file=CERTFILE A Cert file to use for client authentication
  --tls-key-file=KEYFILE   A Key file to use for client authentication
@@ -160,6 +276,11 @@ Examples:
 
   # print only messages that have ".Name == 'JAN'" in their JSON payload
   rabtap sub JDQ --filter="let b=fromJSON(r.toStr(r.body(r.msg))); b.Name == 'JAN'"
+  # show the first 10 messages of JDQ without removing them. The messages are returned to
+  # the queue and marked as redelivered. On quorum queues, their x-delivery-count is raised,
+  # which counts against a delivery-limit and can get them dead-lettered.
+  rabtap queue peek JDQ --limit=10
+  rabtap queue move JDQ --exchange=amq.topic --routingkey=key --strip-x-death
   rabtap queue rm JDQ
 
   # use RABTAP_APIURI environment variable to specify mgmt api uri instead of --api
@@ -192,6 +313,7 @@ UserId          - user id, validated if set
 `
 	tlsOptions    = "[(--tls-cert-file=CERTFILE --tls-key-file=KEYFILE)] [--tls-ca-file=CAFILE] [--insecure]"
 	commonOptions = "[--verbose] [--no-color|--color]"
+	decodeOptions = "[--body=MODE] [--proto-descriptor=FILE [--proto-type=SOURCE] [--proto-map=KV]...] [--avro-schemas=LOCATION]"
 )
 
 // ProgramCmd represents the mode of operation
@@ -200,6 +322,8 @@ type ProgramCmd int
 const (
 	// TapCmd sets mode to tapping mode
 	TapCmd ProgramCmd = iota
+	// TapCleanupCmd removes orphaned tap-exchanges and tap-queues
+	TapCleanupCmd
 	HelpCmd
 	// PubCmd sets mode to message-publish
 	PubCmd
@@ -223,6 +347,10 @@ const (
 	QueueUnbindCmd
 	// QueuePurgeCmd purges a queue
 	QueuePurgeCmd
+	// QueuePeekCmd shows messages of a queue without removing them
+	QueuePeekCmd
+	// QueueMoveCmd moves messages of a queue to another queue or exchange
+	QueueMoveCmd
 	// ConnCloseCmd closes a connection
 	ConnCloseCmd
 	// VersionCmd prints version information
@@ -291,18 +419,39 @@ type CommandLineArgs struct {
 	Cmd ProgramCmd
 	commonArgs
 
-	TapConfig []rabtap.TapConfiguration // configuration in tap mode
-	APIURL    *url.URL
-
-	PubExchange         *string        // pub: exchange to publish to
-	PubRoutingKey       *string        // pub: routing key, defaults to ""
+	TapConfig    []rabtap.TapConfiguration       // configuration in tap mode
+	FireHose     bool                            // tap: tap the FireHose exchange
+	SubConfig    []rabtap.SubscribeConfiguration // sub: queues to subscribe to
+	StreamConfig *rabtap.StreamSubscriberConfig  // sub: use stream protocol if set
+	ConsumerName string                          // sub: optional name of consumer
+	Resume       bool                            // sub: resume after stored offset
+	Prefetch     int                             // sub: AMQP prefetch count
+	AckInterval  time.Duration                   // sub: max delay of batched acks
+	DryRun       bool                            // tap --cleanup: do not remove anything
+	APIURL       *url.URL                        // info, conn: API to use. tap: optional, for discovery
+
+	PubExchange         *string        // pub, queue move: exchange to publish to
+	PubRoutingKey       *string        // pub, queue move: routing key, defaults to ""
 	Source              *string        // pub: file to send
+	Generate            *string        // pub: template of generated messages
+	Count               int64          // pub: number of generated messages
+	CSVMapping          CSVMapping     // pub: mapping of CSV columns
+	Split               *string        // pub: split raw input into messages
 	Speed               float64        // pub: speed factor
 	Delay               *time.Duration // pub: fixed delay in ms
+	Rate                float64        // pub: messages per second, 0 if not set
+	Parallel            int            // pub: number of concurrent publishers
 	Confirms            bool           // pub: wait for confirmations
+	ConfirmWindow       int            // pub: max number of unconfirmed messages
+	Retries             int            // pub: number of retries of failed messages
+	RetryBackoff        time.Duration  // pub: delay before first retry
+	DeadLetterFile      *string        // pub: file to write failed messages to
 	Mandatory           bool           // pub: set mandatory flag
+	EncodeBody          bool           // pub: encode JSON bodies as msgpack or CBOR
 	Properties          PropertiesOverride
-	Limit               int64             // sub: optional limit
+	StripDeathHeaders   bool              // queue move: remove x-death headers
+	Transformations     []string          // queue move: names of message transformers
+	Limit               int64             // sub, queue peek: optional limit
 	Reject              bool              // sub: reject messages
 	Requeue             bool              // sub: requeue rejectied messages
 	IdleTimeout         time.Duration     // sub: idle timeout
@@ -322,7 +471,12 @@ type CommandLineArgs struct {
 	Autodelete          bool              // queue create, exchange create
 	Args                map[string]string // optional additional arguments for pub, tap, queue
 	SaveDir             *string           // save: optional directory to stores files to
+	KeepCharset         bool              // save: keep the original charset of bodies
 	Silent              bool              // suppress message printing
+	Template            *string           // sub/tap: optional template to print messages
+	Protobuf            *ProtobufConfig   // sub/tap: optional protobuf decoding
+	AvroSchemas         *string           // sub/tap: optional location of Avro schemas
+	BodyMode            BodyMode          // sub/tap/peek: how to print message bodies
 	ConnName            string            // conn: name of connection
 	CloseReason         string            // conn: reason of close
 	HeaderMode          HeaderMode        // queue ceate, header based routing
@@ -444,7 +598,8 @@ func parseConnCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 }
 
 // parsePubSubFormatArg parse --format=FORMAT option for pub, sub, tap command.
-func parsePubSubFormatArg(args map[string]interface{}) (string, error) {
+// extraFormats are valid in addition to the formats supported by all commands.
+func parsePubSubFormatArg(args map[string]interface{}, extraFormats ...string) (string, error) {
 	format := "raw"
 
 	if args["--format"] != nil {
@@ -457,19 +612,60 @@ func parsePubSubFormatArg(args map[string]interface{}) (string, error) {
 		format = "json"
 	}
 
-	if format != "json" && format != "json-nopp" && format != "raw" {
-		return "", errors.New("--format=FORMAT must be one of {raw,json,json-nopp}")
+	formats := append([]string{"raw", "json", "json-nopp"}, extraFormats...)
+	if !slices.Contains(formats, format) {
+		return "", fmt.Errorf("--format=FORMAT must be one of {%s}", strings.Join(formats, ","))
 	}
 	return format, nil
 }
 
+// parseProtobufArgs parses the --proto-* options of the sub and tap command
+func parseProtobufArgs(args map[string]interface{}) (*ProtobufConfig, error) {
+	file, ok := args["--proto-descriptor"].(string)
+	if !ok {
+		return nil, nil
+	}
+	source := args["--proto-type"].(string)
+	if source != "type" && (!strings.HasPrefix(source, "header:") || source == "header:") {
+		return nil, fmt.Errorf("invalid --proto-type %q, expected 'type' or 'header:NAME'", source)
+	}
+	typeMap, err := parseKVListOption("--proto-map", args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --proto-map: %w", err)
+	}
+	return &ProtobufConfig{DescriptorFile: file, TypeSource: source, TypeMap: typeMap}, nil
+}
+
+// parseBodyModeArg parses the --body=MODE option of the sub, tap and queue
+// peek command
+func parseBodyModeArg(args map[string]interface{}) (BodyMode, error) {
+	mode := BodyMode(args["--body"].(string))
+	switch mode {
+	case BodyModeAuto, BodyModeText, BodyModeHex, BodyModeBase64:
+		return mode, nil
+	}
+	return mode, errors.New("--body=MODE must be one of {auto,text,hex,base64}")
+}
+
+// parseTemplateArg parses the --template=TEMPLATE option of the sub and tap
+// command, which is only valid with the raw format.
+func parseTemplateArg(args map[string]interface{}, format string) (*string, error) {
+	tpl, ok := args["--template"].(string)
+	if !ok {
+		return nil, nil
+	}
+	if format != "raw" {
+		return nil, errors.New("--template can only be used with --format=raw")
+	}
+	return &tpl, nil
+}
+
 func parseSubCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 	result := CommandLineArgs{
 		Cmd:         SubCmd,
 		commonArgs:  parseCommonArgs(args),
 		Reject:      args["--reject"].(bool),
 		Requeue:     args["--requeue"].(bool),
-		QueueName:   args["QUEUE"].(string),
 		Filter:      args["--filter"].(string),
 		Silent:      args["--silent"].(bool),
 		IdleTimeout: time.Duration(math.MaxInt64),
@@ -480,6 +676,18 @@ func parseSubCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 		return result, err
 	}
 	result.Format = format
+	if result.Template, err = parseTemplateArg(args, format); err != nil {
+		return result, err
+	}
+	if result.Protobuf, err = parseProtobufArgs(args); err != nil {
+		return result, err
+	}
+	if result.BodyMode, err = parseBodyModeArg(args); err != nil {
+		return result, err
+	}
+	if location, ok := args["--avro-schemas"].(string); ok {
+		result.AvroSchemas = &location
+	}
 
 	if timeout := args["--idle-timeout"]; timeout != nil {
 		duration, err := time.ParseDuration(timeout.(string))
@@ -503,16 +711,77 @@ func parseSubCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 	if args["--saveto"] != nil {
 		saveDir := args["--saveto"].(string)
 		result.SaveDir = &saveDir
+		result.KeepCharset = args["--keep-charset"].(bool)
 	}
-	if result.AMQPURL, err = parseAMQPURL(args); err != nil {
-		return result, fmt.Errorf("failed to parse AMQP URL: %w", err)
+	amqpURLs := args["--uri"].([]string)
+	queueArgs := args["QUEUES"].([]string)
+	for i, queues := range queueArgs {
+		amqpURL, err := getAMQPURL(amqpURLs, i)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse AMQP URL: %w", err)
+		}
+		subConfig, err := rabtap.NewSubscribeConfiguration(amqpURL, queues)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse queues: %w", err)
+		}
+		result.SubConfig = append(result.SubConfig, *subConfig)
+		if subConfig.NeedsDiscovery() && len(queueArgs) > 1 {
+			// --api names the management API of a single broker only
+			return result, errors.New("discovery of queues is not supported when subscribing to multiple brokers")
+		}
+		if subConfig.NeedsDiscovery() && result.APIURL == nil {
+			if result.APIURL, err = parseAPIURI(args); err != nil {
+				return result, fmt.Errorf("discovery of queues needs management API: %w", err)
+			}
+		}
 	}
 	if offset := args["--offset"]; offset != nil {
 		result.Args["x-stream-offset"] = offset.(string)
 	}
+	prefetch, err := strconv.Atoi(args["--prefetch"].(string))
+	if err != nil || prefetch < 1 {
+		return result, fmt.Errorf("invalid --prefetch value: %s", args["--prefetch"])
+	}
+	result.Prefetch = prefetch
+	if result.AckInterval, err = time.ParseDuration(args["--ack-interval"].(string)); err != nil {
+		return result, fmt.Errorf("failed to parse --ack-interval: %w", err)
+	}
+	if name, ok := args["--consumer-name"].(string); ok {
+		result.ConsumerName = name
+		result.Resume = args["--resume"].(bool)
+	}
+	if args["--stream"].(bool) {
+		// the stream protocol has no way to reject messages
+		if result.Reject {
+			return result, errors.New("--reject can not be used with --stream")
+		}
+		if result.StreamConfig, err = parseStreamConfig(args); err != nil {
+			return result, err
+		}
+		result.StreamConfig.Reference = result.ConsumerName
+		result.StreamConfig.Resume = result.Resume
+	}
 	return result, nil
 }
 
+// parseStreamConfig parses the options of the sub command in stream mode.
+// Like with AMQP, reading starts with the next message by default.
+func parseStreamConfig(args map[string]interface{}) (*rabtap.StreamSubscriberConfig, error) {
+	spec := "next"
+	if offset := args["--offset"]; offset != nil {
+		spec = offset.(string)
+	}
+	offset, err := rabtap.ParseStreamOffset(spec, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --offset: %w", err)
+	}
+	config := &rabtap.StreamSubscriberConfig{Offset: offset}
+	if filters, ok := args["--stream-filter"].(string); ok {
+		config.Filters = strings.Split(filters, ",")
+	}
+	return config, nil
+}
+
 func parseBindingKey(args map[string]interface{}) string {
 	if key, ok := args["--bindingkey"].(string); ok {
 		return key
@@ -587,6 +856,54 @@ func parseQueueCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 		result.ExchangeName = args["EXCHANGE"].(string)
 	case args["purge"].(bool):
 		result.Cmd = QueuePurgeCmd
+	case args["move"].(bool):
+		result.Cmd = QueueMoveCmd
+		result.Filter = args["--filter"].(string)
+		result.StripDeathHeaders = args["--strip-x-death"].(bool)
+		result.Transformations = args["--transform"].([]string)
+		for _, name := range result.Transformations {
+			if _, err := NewNamedMessageTransformer(name); err != nil {
+				return result, fmt.Errorf("invalid --transform: %w", err)
+			}
+		}
+		if args["DESTQUEUE"] != nil {
+			exchange, routingKey := "", args["DESTQUEUE"].(string)
+			result.PubExchange, result.PubRoutingKey = &exchange, &routingKey
+		} else {
+			exchange := args["--exchange"].(string)
+			result.PubExchange = &exchange
+			if args["--routingkey"] != nil {
+				routingKey := args["--routingkey"].(string)
+				result.PubRoutingKey = &routingKey
+			}
+		}
+		if result.Limit, err = strconv.ParseInt(args["--limit"].(string), 10, 64); err != nil {
+			return result, fmt.Errorf("failed to parse --limit: %w", err)
+		}
+		propsKV, err := parseKVListOption("--property", args)
+		if err != nil {
+			return result, fmt.Errorf("parse properties: %w", err)
+		}
+		if result.Properties, err = parseMessageProperties(propsKV); err != nil {
+			return result, fmt.Errorf("parse properties: %w", err)
+		}
+	case args["peek"].(bool):
+		result.Cmd = QueuePeekCmd
+		result.Silent = args["--silent"].(bool)
+		if result.Format, err = parsePubSubFormatArg(args); err != nil {
+			return result, err
+		}
+		if result.BodyMode, err = parseBodyModeArg(args); err != nil {
+			return result, err
+		}
+		if result.Limit, err = strconv.ParseInt(args["--limit"].(string), 10, 64); err != nil {
+			return result, fmt.Errorf("failed to parse --limit: %w", err)
+		}
+		if args["--saveto"] != nil {
+			saveDir := args["--saveto"].(string)
+			result.SaveDir = &saveDir
+			result.KeepCharset = args["--keep-charset"].(bool)
+		}
 	}
 	return result, nil
 }
@@ -634,14 +951,31 @@ func parsePublishCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 		Cmd:        PubCmd,
 		Confirms:   args["--confirms"].(bool),
 		Mandatory:  args["--mandatory"].(bool),
+		EncodeBody: args["--encode-body"].(bool),
 		commonArgs: parseCommonArgs(args),
 	}
 
-	format, err := parsePubSubFormatArg(args)
+	format, err := parsePubSubFormatArg(args, "ndjson-body", "csv")
 	if err != nil {
 		return result, err
 	}
 	result.Format = format
+	if mapping, ok := args["--map"].(string); ok {
+		if format != "csv" {
+			return result, errors.New("--map can only be used with --format=csv")
+		}
+		if result.CSVMapping, err = parseCSVMapping(mapping); err != nil {
+			return result, fmt.Errorf("failed to parse --map: %w", err)
+		}
+	} else if format == "csv" {
+		return result, errors.New("--format=csv requires --map")
+	}
+	if split, ok := args["--split"].(string); ok {
+		if format != "raw" {
+			return result, errors.New("--split can only be used with --format=raw")
+		}
+		result.Split = &split
+	}
 
 	if result.AMQPURL, err = parseAMQPURL(args); err != nil {
 		return result, err
@@ -662,6 +996,16 @@ func parsePublishCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 		file := args["SOURCE"].(string)
 		result.Source = &file
 	}
+	if args["--generate"] != nil {
+		tpl := args["--generate"].(string)
+		result.Generate = &tpl
+	}
+	if result.Count, err = strconv.ParseInt(args["--count"].(string), 10, 64); err != nil {
+		return result, fmt.Errorf("failed to parse --count: %w", err)
+	}
+	if result.Count < 0 {
+		return result, errors.New("--count must not be negative")
+	}
 	if args["--delay"] != nil {
 		delay, err := time.ParseDuration(args["--delay"].(string))
 		if err != nil {
@@ -675,6 +1019,17 @@ func parsePublishCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 			return result, fmt.Errorf("failed to parse --speed: %w", err)
 		}
 	}
+	if args["--rate"] != nil {
+		if result.Rate, err = parseRate(args["--rate"].(string)); err != nil {
+			return result, fmt.Errorf("failed to parse --rate: %w", err)
+		}
+	}
+	if result.Parallel, err = strconv.Atoi(args["--parallel"].(string)); err != nil {
+		return result, fmt.Errorf("failed to parse --parallel: %w", err)
+	}
+	if result.Parallel < 1 {
+		return result, errors.New("--parallel must be at least 1")
+	}
 	// multiple --property K=V allow to override message properties
 	propsKV, err := parseKVListOption("--property", args)
 	if err != nil {
@@ -686,10 +1041,33 @@ func parsePublishCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 	}
 	result.Properties = props
 
+	if result.ConfirmWindow, err = strconv.Atoi(args["--confirm-window"].(string)); err != nil {
+		return result, fmt.Errorf("failed to parse --confirm-window: %w", err)
+	}
+	if result.ConfirmWindow < 1 {
+		return result, errors.New("--confirm-window must be at least 1")
+	}
+	if result.Retries, err = strconv.Atoi(args["--retry"].(string)); err != nil {
+		return result, fmt.Errorf("failed to parse --retry: %w", err)
+	}
+	if result.Retries < 0 {
+		return result, errors.New("--retry must not be negative")
+	}
+	if result.RetryBackoff, err = time.ParseDuration(args["--retry-backoff"].(string)); err != nil {
+		return result, fmt.Errorf("failed to parse --retry-backoff: %w", err)
+	}
+	if args["--dead-letter-file"] != nil {
+		file := args["--dead-letter-file"].(string)
+		result.DeadLetterFile = &file
+	}
+
 	return result, nil
 }
 
 func parseTapCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
+	if args["--cleanup"].(bool) {
+		return parseTapCleanupCmdArgs(args)
+	}
 	result := CommandLineArgs{
 		Cmd:         TapCmd,
 		commonArgs:  parseCommonArgs(args),
@@ -704,6 +1082,18 @@ func parseTapCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 		return result, err
 	}
 	result.Format = format
+	if result.Template, err = parseTemplateArg(args, format); err != nil {
+		return result, err
+	}
+	if result.Protobuf, err = parseProtobufArgs(args); err != nil {
+		return result, err
+	}
+	if result.BodyMode, err = parseBodyModeArg(args); err != nil {
+		return result, err
+	}
+	if location, ok := args["--avro-schemas"].(string); ok {
+		result.AvroSchemas = &location
+	}
 
 	if timeout := args["--idle-timeout"]; timeout != nil {
 		duration, err := time.ParseDuration(timeout.(string))
@@ -723,7 +1113,12 @@ func parseTapCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 	if args["--saveto"] != nil {
 		saveDir := args["--saveto"].(string)
 		result.SaveDir = &saveDir
+		result.KeepCharset = args["--keep-charset"].(bool)
+	}
+	if args["--firehose"].(bool) {
+		return parseFireHoseTapCmdArgs(args, result)
 	}
+
 	amqpURLs := args["--uri"].([]string)
 	exchanges := args["EXCHANGES"].([]string)
 	for i, exchange := range exchanges {
@@ -738,6 +1133,61 @@ func parseTapCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
 			return result, fmt.Errorf("failed to parse tap configuration: %w", err)
 		}
 		result.TapConfig = append(result.TapConfig, *tapConfig)
+		if tapConfig.NeedsDiscovery() && len(exchanges) > 1 {
+			// --api names the management API of a single broker only
+			return result, errors.New("discovery of exchanges or bindings is not supported when tapping multiple brokers")
+		}
+		if tapConfig.NeedsDiscovery() && result.APIURL == nil {
+			if result.APIURL, err = parseAPIURI(args); err != nil {
+				return result, fmt.Errorf("discovery of exchanges or bindings needs management API: %w", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseFireHoseTapCmdArgs parses the arguments of the tap --firehose command,
+// which taps the FireHose exchange using bindings derived from the given
+// exchanges and queues.
+func parseFireHoseTapCmdArgs(args map[string]interface{}, result CommandLineArgs) (CommandLineArgs, error) {
+	var exchanges, queues []string
+	if list, ok := args["--exchanges"].(string); ok {
+		exchanges = strings.Split(list, ",")
+	}
+	if list, ok := args["--queues"].(string); ok {
+		queues = strings.Split(list, ",")
+	}
+
+	amqpURL, err := parseAMQPURL(args)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse AMQP URL: %w", err)
+	}
+	result.FireHose = true
+	result.TapConfig = []rabtap.TapConfiguration{{
+		AMQPURL:   amqpURL,
+		Exchanges: rabtap.NewFireHoseExchangeConfigurations(exchanges, queues),
+	}}
+
+	// the management API is optional and used to enable tracing
+	if args["--api"] != nil || os.Getenv("RABTAP_APIURI") != "" {
+		if result.APIURL, err = parseAPIURI(args); err != nil {
+			return result, fmt.Errorf("failed to parse API URL: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// parseTapCleanupCmdArgs parses the arguments of the tap --cleanup command,
+// which removes orphaned tap-exchanges and tap-queues.
+func parseTapCleanupCmdArgs(args map[string]interface{}) (CommandLineArgs, error) {
+	result := CommandLineArgs{
+		Cmd:        TapCleanupCmd,
+		commonArgs: parseCommonArgs(args),
+		DryRun:     args["--dry-run"].(bool),
+	}
+	var err error
+	if result.APIURL, err = parseAPIURI(args); err != nil {
+		return result, fmt.Errorf("failed to parse API URL: %w", err)
 	}
 	return result, nil
 }
@@ -774,7 +1224,7 @@ func parseCommandLineArgsWithSpec(spec string, cliArgs []string) (CommandLineArg
 		return parseInfoCmdArgs(args)
 	case args["pub"].(bool):
 		return parsePublishCmdArgs(args)
-	case args["sub"].(bool):
+	case args["sub"].(int) > 0:
 		return parseSubCmdArgs(args)
 	case args["queue"].(bool):
 		return parseQueueCmdArgs(args)
@@ -826,6 +1276,7 @@ func ParseCommandLineArgs(args []string) (CommandLineArgs, error) {
 func toDocoptDSL(usage string) string {
 	replacer := strings.NewReplacer(
 		"[TLSOPTIONS]", tlsOptions,
+		"[DECODEOPTIONS]", decodeOptions,
 		"[COMMON OPTIONS]", commonOptions,
 	)
 	return replacer.Replace(usage)
@@ -13,6 +13,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
 )
 
 func TestMain(m *testing.M) {
@@ -205,6 +207,144 @@ func TestCliTapFailsWhenNoURIisSpecified(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestCliTapWithoutBindingKeyParsesAPIURL(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=uri", "--api=APIURI", "exchange1,exchange2:binding2"})
+
+	assert.Nil(t, err)
+	assertEqualURL(t, "APIURI", args.APIURL)
+	assert.Equal(t, 2, len(args.TapConfig[0].Exchanges))
+	assert.Equal(t, "exchange1", args.TapConfig[0].Exchanges[0].Exchange)
+	assert.True(t, args.TapConfig[0].Exchanges[0].DiscoverBindings)
+	assert.False(t, args.TapConfig[0].Exchanges[1].DiscoverBindings)
+}
+
+func TestCliTapWithoutBindingKeyFailsWhenNoAPIURLisSpecified(t *testing.T) {
+	_, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=uri", "exchange1"})
+
+	assert.NotNil(t, err)
+}
+
+func TestCliTapWithExchangePatternFailsWhenNoAPIURLisSpecified(t *testing.T) {
+	_, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=uri", "orders.*:#"})
+
+	assert.NotNil(t, err)
+}
+
+func TestCliTapWithExchangeNameContainingEqualSign(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"tap", "--uri=uri", "a=b:#"})
+
+	require.NoError(t, err)
+	require.Len(t, args.TapConfig, 1)
+	assert.Equal(t, "a=b", args.TapConfig[0].Exchanges[0].Exchange)
+	assert.Equal(t, "#", args.TapConfig[0].Exchanges[0].BindingKey)
+}
+
+func TestCliTapWithDiscoveryFailsWithMultipleBrokers(t *testing.T) {
+	t.Setenv("RABTAP_APIURI", "http://api")
+	_, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=broker1", "exchange1:#", "tap", "--uri=broker2", "exchange2"})
+
+	assert.ErrorContains(t, err, "not supported when tapping multiple brokers")
+}
+
+func TestCliTapFireHoseWithoutFilter(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--firehose", "--uri=uri"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, TapCmd, args.Cmd)
+	assert.True(t, args.FireHose)
+	assert.Nil(t, args.APIURL)
+	assert.Equal(t, 1, len(args.TapConfig))
+	assertEqualURL(t, "uri", args.TapConfig[0].AMQPURL)
+	assert.Equal(t, []rabtap.ExchangeConfiguration{
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish.#"},
+	}, args.TapConfig[0].Exchanges)
+}
+
+func TestCliTapFireHoseWithExchangesAndQueues(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--firehose", "--exchanges=amq.default,ex", "--queues=q1",
+			"--uri=uri", "--api=APIURI", "--limit=1"})
+
+	assert.Nil(t, err)
+	assert.True(t, args.FireHose)
+	assertEqualURL(t, "APIURI", args.APIURL)
+	assert.Equal(t, int64(1), args.Limit)
+	assert.Equal(t, []rabtap.ExchangeConfiguration{
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish."},
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish.ex"},
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "deliver.q1"},
+	}, args.TapConfig[0].Exchanges)
+}
+
+func TestCliTapParsesTemplate(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=uri", "exchange:#", "--template=oneline"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "oneline", *args.Template)
+
+	args, err = ParseCommandLineArgs([]string{"tap", "--uri=uri", "exchange:#"})
+	require.NoError(t, err)
+	assert.Nil(t, args.Template)
+}
+
+func TestCliTapParsesBodyMode(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"tap", "--uri=uri", "exchange:#"})
+	require.NoError(t, err)
+	assert.Equal(t, BodyModeAuto, args.BodyMode)
+
+	args, err = ParseCommandLineArgs([]string{"tap", "--uri=uri", "exchange:#", "--body=hex"})
+	require.NoError(t, err)
+	assert.Equal(t, BodyModeHex, args.BodyMode)
+
+	args, err = ParseCommandLineArgs([]string{"queue", "peek", "q", "--uri=uri", "--body=base64"})
+	require.NoError(t, err)
+	assert.Equal(t, BodyModeBase64, args.BodyMode)
+
+	_, err = ParseCommandLineArgs([]string{"sub", "q", "--uri=uri", "--body=octal"})
+	assert.ErrorContains(t, err, "--body=MODE must be one of")
+}
+
+func TestCliParsesKeepCharset(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "q", "--uri=uri", "--saveto=dir"})
+	require.NoError(t, err)
+	assert.False(t, args.KeepCharset)
+
+	args, err = ParseCommandLineArgs([]string{"sub", "q", "--uri=uri", "--saveto=dir", "--keep-charset"})
+	require.NoError(t, err)
+	assert.True(t, args.KeepCharset)
+
+	args, err = ParseCommandLineArgs([]string{"tap", "--uri=uri", "exchange:#", "--saveto=dir", "--keep-charset"})
+	require.NoError(t, err)
+	assert.True(t, args.KeepCharset)
+
+	args, err = ParseCommandLineArgs([]string{"queue", "peek", "q", "--uri=uri", "--saveto=dir", "--keep-charset"})
+	require.NoError(t, err)
+	assert.True(t, args.KeepCharset)
+}
+
+func TestCliTapCleanup(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--cleanup", "--api=APIURI", "--dry-run"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, TapCleanupCmd, args.Cmd)
+	assert.True(t, args.DryRun)
+	assertEqualURL(t, "APIURI", args.APIURL)
+}
+
+func TestCliTapCleanupWithoutAPIReturnsError(t *testing.T) {
+	t.Setenv("RABTAP_APIURI", "")
+	_, err := ParseCommandLineArgs([]string{"tap", "--cleanup"})
+
+	assert.NotNil(t, err)
+}
+
 func TestCliTapCmdInvalidNumReturnsError(t *testing.T) {
 	_, err := ParseCommandLineArgs([]string{"tap", "exchange:binding", "--uri=uri", "--limit=invalid"})
 	assert.NotNil(t, err)
@@ -376,7 +516,15 @@ func TestCliPubCmdFromFileMinimalOptsSet(t *testing.T) {
 	assert.Equal(t, "raw", args.Format)
 	assert.Nil(t, args.Delay)
 	assert.Equal(t, 1., args.Speed)
+	assert.Equal(t, 0., args.Rate)
+	assert.Equal(t, 1, args.Parallel)
+	assert.Nil(t, args.Generate)
+	assert.Equal(t, int64(1), args.Count)
 	assert.False(t, args.Confirms)
+	assert.Equal(t, 1, args.ConfirmWindow)
+	assert.Equal(t, 0, args.Retries)
+	assert.Equal(t, time.Second, args.RetryBackoff)
+	assert.Nil(t, args.DeadLetterFile)
 	assert.False(t, args.Mandatory)
 	assert.False(t, args.Verbose)
 	assert.False(t, args.InsecureTLS)
@@ -389,6 +537,7 @@ func TestCliPubCmdFromFileAllOptsSet(t *testing.T) {
 			"pub", "--uri=uri", "--exchange=exchange", "file",
 			"--routingkey=key", "--delay=5s", "--format=json",
 			"--confirms", "--mandatory", "--property=ContentEncoding=gzip",
+			"--encode-body",
 		})
 
 	require.Nil(t, err)
@@ -402,11 +551,179 @@ func TestCliPubCmdFromFileAllOptsSet(t *testing.T) {
 	assert.Equal(t, 1., args.Speed)
 	assert.True(t, args.Confirms)
 	assert.True(t, args.Mandatory)
+	assert.True(t, args.EncodeBody)
 	assert.False(t, args.Verbose)
 	assert.False(t, args.InsecureTLS)
 	assert.Equal(t, "gzip", *args.Properties.ContentEncoding)
 }
 
+func TestCliPubCmdParsesConfirmWindow(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"pub", "--uri=uri", "--confirms", "--confirm-window=100"})
+
+	require.NoError(t, err)
+	assert.True(t, args.Confirms)
+	assert.Equal(t, 100, args.ConfirmWindow)
+}
+
+func TestCliPubCmdConfirmWindowRequiresConfirms(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--confirm-window=100"})
+	assert.Error(t, err)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--confirms", "--confirm-window=0"})
+	assert.ErrorContains(t, err, "--confirm-window must be at least 1")
+}
+
+func TestCliPubCmdParsesRetryOptions(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"pub", "--uri=uri", "--confirms", "--retry=3", "--retry-backoff=500ms",
+			"--dead-letter-file=failed.json"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Retries)
+	assert.Equal(t, 500*time.Millisecond, args.RetryBackoff)
+	assert.Equal(t, "failed.json", *args.DeadLetterFile)
+}
+
+func TestCliPubCmdRetryRequiresConfirms(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--retry=3"})
+	assert.Error(t, err)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--confirms", "--retry=-1"})
+	assert.ErrorContains(t, err, "--retry must not be negative")
+}
+
+func TestCliPubCmdParsesRateAndParallel(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"pub", "--uri=uri", "--rate=600/m", "--parallel=4"})
+
+	require.NoError(t, err)
+	assert.InDelta(t, 10., args.Rate, 1e-9)
+	assert.Equal(t, 4, args.Parallel)
+}
+
+func TestCliPubCmdRateExcludesDelay(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--rate=10/s", "--delay=1s"})
+	assert.Error(t, err)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--rate=10/d"})
+	assert.ErrorContains(t, err, "failed to parse --rate")
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--parallel=0"})
+	assert.ErrorContains(t, err, "--parallel must be at least 1")
+}
+
+func TestCliPubCmdParsesGenerateOptions(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"pub", "--uri=uri", "--generate={{ Seq }}", "--count=100",
+			"--routingkey=key.{{ Seq }}"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "{{ Seq }}", *args.Generate)
+	assert.Equal(t, int64(100), args.Count)
+	assert.Equal(t, "key.{{ Seq }}", *args.PubRoutingKey)
+	assert.Nil(t, args.Source)
+}
+
+func TestCliPubCmdGenerateExcludesSource(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "file", "--generate=x"})
+	assert.Error(t, err)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--count=10"})
+	assert.Error(t, err)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--generate=x", "--count=-1"})
+	assert.ErrorContains(t, err, "--count must not be negative")
+}
+
+func TestCliPubCmdParsesCSVFormatWithMapping(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"pub", "--uri=uri", "--format=csv", "--map=routingkey=col2,body=col4", "file.csv"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "csv", args.Format)
+	assert.Equal(t, CSVMapping{
+		{target: "routingkey", column: "col2", index: 1},
+		{target: "body", column: "col4", index: 3},
+	}, args.CSVMapping)
+}
+
+func TestCliPubCmdValidatesCSVMapping(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--format=csv"})
+	assert.ErrorContains(t, err, "--format=csv requires --map")
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--format=json", "--map=body=col1"})
+	assert.ErrorContains(t, err, "--map can only be used with --format=csv")
+
+	args, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--format=ndjson-body"})
+	require.NoError(t, err)
+	assert.Equal(t, "ndjson-body", args.Format)
+}
+
+func TestCliSubCmdDoesNotAcceptPubFormats(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"sub", "--uri=uri", "queue", "--format=csv"})
+	assert.Error(t, err)
+}
+
+func TestCliSubCmdParsesTemplate(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"sub", "--uri=uri", "queue", "--template={{ call .Body }}"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "{{ call .Body }}", *args.Template)
+
+	_, err = ParseCommandLineArgs(
+		[]string{"sub", "--uri=uri", "queue", "--template=oneline", "--format=json"})
+	assert.ErrorContains(t, err, "--template can only be used with --format=raw")
+}
+
+func TestCliSubCmdParsesProtobufOptions(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"sub", "--uri=uri", "queue", "--proto-descriptor=shop.pb",
+			"--proto-type=header:event", "--proto-map=order.created=shop.v1.Order"})
+
+	require.NoError(t, err)
+	assert.Equal(t, &ProtobufConfig{
+		DescriptorFile: "shop.pb",
+		TypeSource:     "header:event",
+		TypeMap:        map[string]string{"order.created": "shop.v1.Order"},
+	}, args.Protobuf)
+
+	args, err = ParseCommandLineArgs([]string{"tap", "--uri=uri", "exchange:#", "--proto-descriptor=shop.pb"})
+	require.NoError(t, err)
+	assert.Equal(t, "type", args.Protobuf.TypeSource)
+
+	args, err = ParseCommandLineArgs([]string{"sub", "--uri=uri", "queue"})
+	require.NoError(t, err)
+	assert.Nil(t, args.Protobuf)
+
+	_, err = ParseCommandLineArgs(
+		[]string{"sub", "--uri=uri", "queue", "--proto-descriptor=shop.pb", "--proto-type=invalid"})
+	assert.ErrorContains(t, err, "invalid --proto-type")
+}
+
+func TestCliTapCmdParsesAvroSchemas(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"tap", "--uri=uri", "exchange:#", "--avro-schemas=http://localhost:8081"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8081", *args.AvroSchemas)
+
+	args, err = ParseCommandLineArgs([]string{"sub", "--uri=uri", "queue", "--avro-schemas=schemas/"})
+	require.NoError(t, err)
+	assert.Equal(t, "schemas/", *args.AvroSchemas)
+}
+
+func TestCliPubCmdParsesSplit(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"pub", "--uri=uri", "--split=newline"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "newline", *args.Split)
+
+	_, err = ParseCommandLineArgs([]string{"pub", "--uri=uri", "--split=newline", "--format=json"})
+	assert.ErrorContains(t, err, "--split can only be used with --format=raw")
+}
+
 func TestCliPubCmdURLFromEnv(t *testing.T) {
 	const key = "RABTAP_AMQPURI"
 	t.Setenv(key, "uri")
@@ -476,6 +793,124 @@ func TestCliSubCmdOffsetSetsStreamOffsetArg(t *testing.T) {
 	assert.Equal(t, args.Args["x-stream-offset"], "123")
 }
 
+func TestCliSubCmdStreamSetsStreamConfig(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri",
+		"--stream", "--offset=first", "--stream-filter=a,b"})
+	assert.NoError(t, err)
+	require.NotNil(t, args.StreamConfig)
+	assert.Equal(t, rabtap.StreamOffset{Type: rabtap.StreamOffsetFirst}, args.StreamConfig.Offset)
+	assert.Equal(t, []string{"a", "b"}, args.StreamConfig.Filters)
+}
+
+func TestCliSubCmdStreamStartsWithNextOffsetByDefault(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--stream"})
+	assert.NoError(t, err)
+	require.NotNil(t, args.StreamConfig)
+	assert.Equal(t, rabtap.StreamOffsetNext, args.StreamConfig.Offset.Type)
+	assert.Nil(t, args.StreamConfig.Filters)
+}
+
+func TestCliSubCmdStreamWithInvalidOffsetReturnsError(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--stream", "--offset=invalid"})
+	assert.Error(t, err)
+}
+
+func TestCliSubCmdStreamWithRejectReturnsError(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--stream", "--reject"})
+	assert.EqualError(t, err, "--reject can not be used with --stream")
+}
+
+func TestCliSubCmdWithoutStreamHasNoStreamConfig(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri"})
+	assert.NoError(t, err)
+	assert.Nil(t, args.StreamConfig)
+}
+
+func TestCliSubCmdConsumerNameAndResume(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri",
+		"--consumer-name=export", "--resume"})
+	assert.NoError(t, err)
+	assert.Equal(t, "export", args.ConsumerName)
+	assert.True(t, args.Resume)
+	assert.Nil(t, args.StreamConfig)
+}
+
+func TestCliSubCmdStreamWithConsumerNameSetsReference(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri",
+		"--stream", "--consumer-name=export", "--resume"})
+	assert.NoError(t, err)
+	require.NotNil(t, args.StreamConfig)
+	assert.Equal(t, "export", args.StreamConfig.Reference)
+	assert.True(t, args.StreamConfig.Resume)
+}
+
+func TestCliSubCmdResumeRequiresConsumerName(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--resume"})
+	assert.Error(t, err)
+}
+
+func TestCliSubCmdPrefetchDefaultsToOne(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, args.Prefetch)
+	assert.Equal(t, 200*time.Millisecond, args.AckInterval)
+}
+
+func TestCliSubCmdPrefetchAndAckInterval(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri",
+		"--prefetch=500", "--ack-interval=1s"})
+	assert.NoError(t, err)
+	assert.Equal(t, 500, args.Prefetch)
+	assert.Equal(t, time.Second, args.AckInterval)
+}
+
+func TestCliSubCmdInvalidPrefetchReturnsError(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--prefetch=0"})
+	assert.Error(t, err)
+	_, err = ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri", "--prefetch=x"})
+	assert.Error(t, err)
+}
+
+func TestCliSubCmdParsesListOfQueues(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{"sub", "q1,q2", "--uri=uri"})
+
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(args.SubConfig))
+	assert.Equal(t, []rabtap.QueueSelector{{Queue: "q1"}, {Queue: "q2"}}, args.SubConfig[0].Queues)
+	assert.Nil(t, args.APIURL)
+}
+
+func TestCliSubCmdParsesMultipleURIs(t *testing.T) {
+	args, err := ParseCommandLineArgs([]string{
+		"sub", "--uri=uri1", "q1,q2", "sub", "--uri=uri2", "q3"})
+
+	assert.NoError(t, err)
+	require.Equal(t, 2, len(args.SubConfig))
+	assertEqualURL(t, "uri1", args.SubConfig[0].AMQPURL)
+	assert.Equal(t, []rabtap.QueueSelector{{Queue: "q1"}, {Queue: "q2"}}, args.SubConfig[0].Queues)
+	assertEqualURL(t, "uri2", args.SubConfig[1].AMQPURL)
+	assert.Equal(t, []rabtap.QueueSelector{{Queue: "q3"}}, args.SubConfig[1].Queues)
+}
+
+func TestCliSubCmdWithQueuePatternRequiresAPI(t *testing.T) {
+	t.Setenv("RABTAP_APIURI", "")
+	_, err := ParseCommandLineArgs([]string{"sub", "dlq.*", "--uri=uri"})
+	assert.ErrorContains(t, err, "discovery of queues needs management API")
+
+	args, err := ParseCommandLineArgs([]string{"sub", "dlq.*", "--uri=uri", "--api=api"})
+	assert.NoError(t, err)
+	assertEqualURL(t, "api", args.APIURL)
+	assert.True(t, args.SubConfig[0].NeedsDiscovery())
+}
+
+func TestCliSubCmdWithQueuePatternFailsWithMultipleBrokers(t *testing.T) {
+	t.Setenv("RABTAP_APIURI", "http://api")
+	_, err := ParseCommandLineArgs([]string{
+		"sub", "--uri=uri1", "q1", "sub", "--uri=uri2", "dlq.*"})
+
+	assert.ErrorContains(t, err, "not supported when subscribing to multiple brokers")
+}
+
 func TestCliSubSetsInfiniteTimeoutWhenNotSpecified(t *testing.T) {
 	args, err := ParseCommandLineArgs([]string{"sub", "queue", "--uri=uri"})
 	assert.NoError(t, err)
@@ -501,8 +936,9 @@ func TestCliSubCmdAllOptsSet(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, SubCmd, args.Cmd)
-	assert.Equal(t, "queuename", args.QueueName)
-	assertEqualURL(t, "uri", args.AMQPURL)
+	require.Equal(t, 1, len(args.SubConfig))
+	assert.Equal(t, []rabtap.QueueSelector{{Queue: "queuename"}}, args.SubConfig[0].Queues)
+	assertEqualURL(t, "uri", args.SubConfig[0].AMQPURL)
 	assert.Equal(t, "dir", *args.SaveDir)
 	assert.Equal(t, int64(99), args.Limit)
 	assert.Equal(t, time.Duration(time.Second*10), args.IdleTimeout)
@@ -560,6 +996,81 @@ func TestCliPurgeQueue(t *testing.T) {
 	assertEqualURL(t, "uri", args.AMQPURL)
 }
 
+func TestCliPeekQueue(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"queue", "peek", "name", "--uri", "uri", "--limit=5",
+			"--format=json", "--saveto=dir", "--silent"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, QueuePeekCmd, args.Cmd)
+	assert.Equal(t, "name", args.QueueName)
+	assertEqualURL(t, "uri", args.AMQPURL)
+	assert.Equal(t, int64(5), args.Limit)
+	assert.Equal(t, "json", args.Format)
+	assert.Equal(t, "dir", *args.SaveDir)
+	assert.True(t, args.Silent)
+}
+
+func TestCliPeekQueueReadsAllMessagesByDefault(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"queue", "peek", "name", "--uri", "uri"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, InfiniteMessages, args.Limit)
+	assert.Equal(t, "raw", args.Format)
+	assert.Nil(t, args.SaveDir)
+}
+
+func TestCliMoveQueueToQueue(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"queue", "move", "dlq", "to", "orders", "--uri", "uri", "--limit=5",
+			"--filter=r.msg.RoutingKey == 'a'", "--strip-x-death", "--property=AppID=app"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, QueueMoveCmd, args.Cmd)
+	assert.Equal(t, "dlq", args.QueueName)
+	assertEqualURL(t, "uri", args.AMQPURL)
+	assert.Equal(t, "", *args.PubExchange)
+	assert.Equal(t, "orders", *args.PubRoutingKey)
+	assert.Equal(t, int64(5), args.Limit)
+	assert.Equal(t, "r.msg.RoutingKey == 'a'", args.Filter)
+	assert.True(t, args.StripDeathHeaders)
+	assert.Equal(t, "app", *args.Properties.AppID)
+}
+
+func TestCliMoveQueueToExchange(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"queue", "move", "dlq", "--exchange=amq.topic", "--uri", "uri"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, QueueMoveCmd, args.Cmd)
+	assert.Equal(t, "amq.topic", *args.PubExchange)
+	assert.Nil(t, args.PubRoutingKey)
+	assert.Equal(t, InfiniteMessages, args.Limit)
+	assert.Equal(t, "true", args.Filter)
+	assert.False(t, args.StripDeathHeaders)
+}
+
+func TestCliMoveQueueWithTransformations(t *testing.T) {
+	args, err := ParseCommandLineArgs(
+		[]string{"queue", "move", "dlq", "to", "orders", "--uri", "uri",
+			"--transform=firehose", "--transform=encode-body"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"firehose", "encode-body"}, args.Transformations)
+}
+
+func TestCliMoveQueueWithUnknownTransformationFails(t *testing.T) {
+	_, err := ParseCommandLineArgs(
+		[]string{"queue", "move", "dlq", "to", "orders", "--uri", "uri", "--transform=strip-x-death"})
+	assert.ErrorContains(t, err, "unknown transformation")
+}
+
+func TestCliMoveQueueRequiresTarget(t *testing.T) {
+	_, err := ParseCommandLineArgs([]string{"queue", "move", "dlq", "--uri", "uri"})
+	assert.Error(t, err)
+}
+
 func TestCliUnbindQueue(t *testing.T) {
 	args, err := ParseCommandLineArgs(
 		[]string{
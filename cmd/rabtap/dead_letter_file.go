@@ -0,0 +1,88 @@
+// Copyright (C) 2026 Jan Delgado
+// Dead-letter file for messages which could not be published.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// deadLetterFile writes messages which could not be published as a stream of
+// JSON messages, which can be re-published with 'rabtap pub --format=json'.
+type deadLetterFile struct {
+	file  *os.File
+	count int
+}
+
+// newDeadLetterFile creates the dead-letter file. An existing file is
+// truncated.
+func newDeadLetterFile(filename string) (*deadLetterFile, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("create dead-letter file: %w", err)
+	}
+	return &deadLetterFile{file: file}, nil
+}
+
+// failedMessage returns the message which could not be published, if known.
+// Without publisher confirms, only returned messages are known.
+func failedMessage(publishErr *rabtap.PublishError) (RabtapPersistentMessage, bool) {
+	switch {
+	case publishErr.Message != nil:
+		return NewRabtapPersistentMessageFromPublishing(publishErr.Message.Routing,
+			*publishErr.Message.Publishing), true
+	case publishErr.ReturnedMessage != nil:
+		returned := publishErr.ReturnedMessage
+		routing := rabtap.NewRouting(returned.Exchange, returned.RoutingKey, returned.Headers)
+		return NewRabtapPersistentMessageFromPublishing(routing, amqp.Publishing{
+			ContentType:     returned.ContentType,
+			ContentEncoding: returned.ContentEncoding,
+			DeliveryMode:    returned.DeliveryMode,
+			Priority:        returned.Priority,
+			CorrelationId:   returned.CorrelationId,
+			ReplyTo:         returned.ReplyTo,
+			Expiration:      returned.Expiration,
+			MessageId:       returned.MessageId,
+			Timestamp:       returned.Timestamp,
+			Type:            returned.Type,
+			UserId:          returned.UserId,
+			AppId:           returned.AppId,
+			Body:            returned.Body,
+		}), true
+	}
+	return RabtapPersistentMessage{}, false
+}
+
+// Write appends the message of the failed publishing as a single line of
+// JSON to the file. Errors without a message are ignored.
+func (s *deadLetterFile) Write(publishErr *rabtap.PublishError) error {
+	m, ok := failedMessage(publishErr)
+	if !ok {
+		return nil
+	}
+	data, err := JSONMarshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write dead-letter file: %w", err)
+	}
+	s.count++
+	return nil
+}
+
+// Close closes the file. The file is removed when no message was written.
+func (s *deadLetterFile) Close() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.count == 0 {
+		return os.Remove(s.file.Name())
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+func TestDeadLetterFileWritesMessagesWhichCanBePublishedAgain(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "failed.json")
+	deadLetters, err := newDeadLetterFile(filename)
+	require.NoError(t, err)
+
+	nacked := &rabtap.PublishMessage{
+		Routing:    rabtap.NewRouting("exchange", "key", amqp.Table{"h": "v"}),
+		Publishing: &amqp.Publishing{ContentType: "text/plain", Body: []byte("nacked")},
+	}
+	require.NoError(t, deadLetters.Write(&rabtap.PublishError{Reason: rabtap.PublishErrorNack, Message: nacked}))
+	returned := &amqp.Return{Exchange: "", RoutingKey: "nowhere", MessageId: "42", Body: []byte("returned")}
+	require.NoError(t, deadLetters.Write(&rabtap.PublishError{Reason: rabtap.PublishErrorReturned, ReturnedMessage: returned}))
+	// errors without a message are not written
+	require.NoError(t, deadLetters.Write(&rabtap.PublishError{Reason: rabtap.PublishErrorChannelError}))
+	require.NoError(t, deadLetters.Close())
+
+	file, err := os.Open(filename)
+	require.NoError(t, err)
+	source, err := NewReaderMessageSource("json", file)
+	require.NoError(t, err)
+
+	m, err := source()
+	require.NoError(t, err)
+	assert.Equal(t, "exchange", m.Exchange)
+	assert.Equal(t, "key", m.RoutingKey)
+	assert.Equal(t, map[string]interface{}{"h": "v"}, m.Headers)
+	assert.Equal(t, "text/plain", m.ContentType)
+	assert.Equal(t, []byte("nacked"), m.Body)
+
+	m, err = source()
+	require.NoError(t, err)
+	assert.Equal(t, "nowhere", m.RoutingKey)
+	assert.Equal(t, "42", m.MessageID)
+	assert.Equal(t, []byte("returned"), m.Body)
+
+	_, err = source()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDeadLetterFileIsRemovedWhenNoMessageWasWritten(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "failed.json")
+	deadLetters, err := newDeadLetterFile(filename)
+	require.NoError(t, err)
+
+	require.NoError(t, deadLetters.Close())
+
+	assert.NoFileExists(t, filename)
+}
@@ -0,0 +1,28 @@
+// remove the dead-lettering headers set by the broker
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import "strings"
+
+// isDeathHeader returns true if the header was added by the broker when the
+// message was dead-lettered (see https://www.rabbitmq.com/dlx.html)
+func isDeathHeader(key string) bool {
+	return key == "x-death" ||
+		strings.HasPrefix(key, "x-first-death-") ||
+		strings.HasPrefix(key, "x-last-death-")
+}
+
+// DeathHeadersTransformer removes the x-death, x-first-death-* and
+// x-last-death-* headers of a message, so a dead-lettered message is
+// republished like the original message.
+func DeathHeadersTransformer(m RabtapPersistentMessage) (RabtapPersistentMessage, error) {
+	headers := make(map[string]interface{}, len(m.Headers))
+	for k, v := range m.Headers {
+		if !isDeathHeader(k) {
+			headers[k] = v
+		}
+	}
+	m.Headers = headers
+	return m, nil
+}
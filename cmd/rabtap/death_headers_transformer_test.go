@@ -0,0 +1,30 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeathHeadersTransformerRemovesDeathHeaders(t *testing.T) {
+	headers := map[string]interface{}{
+		"x-death":                []interface{}{},
+		"x-first-death-queue":    "q",
+		"x-first-death-reason":   "rejected",
+		"x-first-death-exchange": "",
+		"x-last-death-queue":     "q",
+		"x-custom":               "keep",
+	}
+	m := RabtapPersistentMessage{Headers: headers, Body: []byte("body")}
+
+	transformed, err := DeathHeadersTransformer(m)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"x-custom": "keep"}, transformed.Headers)
+	assert.Equal(t, []byte("body"), transformed.Body)
+	// original headers are not modified
+	assert.Len(t, headers, 6)
+}
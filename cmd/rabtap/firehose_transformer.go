@@ -8,6 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
 )
 
 // FireHoseTransformer checks if a messages was recorded from the firehose
@@ -23,7 +27,7 @@ func FireHoseTransformer(m RabtapPersistentMessage) (RabtapPersistentMessage, er
 // IsFromIsFromFireHoseExchange returns true if the given message was
 // sent to the amq.rabbitmq.trace exchange, the FireHose exchange.
 func IsFromFireHoseExchange(m RabtapPersistentMessage) bool {
-	return m.Exchange == "amq.rabbitmq.trace"
+	return m.Exchange == rabtap.FireHoseExchange
 }
 
 // prop accesses a property in the given map m or return the provided default,
@@ -35,11 +39,50 @@ func prop[T any](m map[string]interface{}, key string, def T) T {
 	return def
 }
 
+// propMap accesses a map property in the given map m, which is either a
+// plain map (as read from JSON) or an amqp.Table (as received from the
+// broker). Returns the provided default, if not found
+func propMap(m map[string]interface{}, key string, def map[string]interface{}) map[string]interface{} {
+	switch val := m[key].(type) {
+	case map[string]interface{}:
+		return val
+	case amqp.Table:
+		return val
+	default:
+		return def
+	}
+}
+
 // propInt accesses a int64 property in the given map m or return the provided default,
-// if not found
+// if not found. The property is either a json.Number (as read from JSON) or
+// an integer or timestamp (as received from the broker)
 func propInt(m map[string]interface{}, key string, def int64) (int64, error) {
-	num := prop(m, key, json.Number(fmt.Sprintf("%d", def)))
-	return num.Int64()
+	switch val := m[key].(type) {
+	case nil:
+		return def, nil
+	case json.Number:
+		return val.Int64()
+	case int8:
+		return int64(val), nil
+	case uint8:
+		return int64(val), nil
+	case int16:
+		return int64(val), nil
+	case uint16:
+		return int64(val), nil
+	case int32:
+		return int64(val), nil
+	case uint32:
+		return int64(val), nil
+	case int:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	case time.Time:
+		return val.Unix(), nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", val)
+	}
 }
 
 func routingKeyFromHeader(header map[string]interface{}) string {
@@ -62,10 +105,9 @@ func FromFireHoseMessage(m RabtapPersistentMessage) (RabtapPersistentMessage, er
 		return RabtapPersistentMessage{}, fmt.Errorf("headers not set")
 	}
 
-	if props, found := m.Headers["properties"]; !found {
+	if props := propMap(m.Headers, "properties", nil); props == nil {
 		return RabtapPersistentMessage{}, fmt.Errorf("headers.properties attribute missing")
 	} else {
-		props := props.(map[string]interface{})
 
 		var err error
 		var priority int64
@@ -81,7 +123,7 @@ func FromFireHoseMessage(m RabtapPersistentMessage) (RabtapPersistentMessage, er
 			return RabtapPersistentMessage{}, fmt.Errorf("timestamp: %w", err)
 		}
 		return RabtapPersistentMessage{
-			Headers:                  prop(props, "headers", map[string]interface{}{}),
+			Headers:                  propMap(props, "headers", map[string]interface{}{}),
 			ContentType:              prop(props, "content_type", ""),
 			ContentEncoding:          prop(props, "content_encoding", ""),
 			DeliveryMode:             uint8(delivery_mode),
@@ -102,3 +144,36 @@ func FromFireHoseMessage(m RabtapPersistentMessage) (RabtapPersistentMessage, er
 		}, nil
 	}
 }
+
+// FromFireHoseTapMessage transforms a message received live from the FireHose
+// exchange into a message looking like the originally published or delivered
+// message (see FromFireHoseMessage). Since the returned message is still
+// acknowledged on the channel it was received on, the delivery tag and
+// acknowledger of the received message are kept. Messages which are no
+// FireHose events are returned unchanged.
+func FromFireHoseTapMessage(m rabtap.TapMessage) (rabtap.TapMessage, error) {
+	if !rabtap.IsFireHoseEvent(m.AmqpMessage.Exchange, m.AmqpMessage.RoutingKey) {
+		return m, nil
+	}
+	orig, err := FromFireHoseMessage(NewRabtapPersistentMessage(m))
+	if err != nil {
+		return m, err
+	}
+	delivery := *m.AmqpMessage
+	delivery.Headers = amqp.Table(orig.Headers)
+	delivery.ContentType = orig.ContentType
+	delivery.ContentEncoding = orig.ContentEncoding
+	delivery.DeliveryMode = orig.DeliveryMode
+	delivery.Priority = orig.Priority
+	delivery.CorrelationId = orig.CorrelationID
+	delivery.ReplyTo = orig.ReplyTo
+	delivery.Expiration = orig.Expiration
+	delivery.MessageId = orig.MessageID
+	delivery.Timestamp = orig.Timestamp
+	delivery.Type = orig.Type
+	delivery.UserId = orig.UserID
+	delivery.AppId = orig.AppID
+	delivery.Exchange = orig.Exchange
+	delivery.RoutingKey = orig.RoutingKey
+	return rabtap.NewTapMessage(&delivery, m.ReceivedTimestamp), nil
+}
@@ -8,8 +8,11 @@ import (
 	"testing"
 	"time"
 
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
 )
 
 func TestRoutingKeyFromHeaderReturnsRoutingKeyIfSet(t *testing.T) {
@@ -203,3 +206,83 @@ func TestMessageFromFireHoseIsTransformed(t *testing.T) {
 	assert.Equal(t, map[string]interface{}{"header1": "test0"}, transformed.Headers)
 	assert.Equal(t, msg.Body, transformed.Body)
 }
+
+func TestPropIntAcceptsJSONNumbersAndIntegers(t *testing.T) {
+	m := map[string]interface{}{
+		"json": json.Number("1"),
+		"int8": int8(2),
+		"int":  3,
+		"ts":   time.Unix(4, 0),
+		"str":  "5",
+	}
+
+	for key, expected := range map[string]int64{"json": 1, "int8": 2, "int": 3, "ts": 4, "missing": 99} {
+		val, err := propInt(m, key, 99)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, val, key)
+	}
+	_, err := propInt(m, "str", 0)
+	assert.Error(t, err)
+}
+
+type mockAcknowledger struct{}
+
+func (s mockAcknowledger) Ack(tag uint64, multiple bool) error                 { return nil }
+func (s mockAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error { return nil }
+func (s mockAcknowledger) Reject(tag uint64, requeue bool) error              { return nil }
+
+func TestFromFireHoseTapMessageTransformsMessageReceivedFromBroker(t *testing.T) {
+	// given
+	acknowledger := mockAcknowledger{}
+	ts := time.Date(2022, time.July, 10, 17, 49, 5, 0, time.UTC)
+	delivery := amqp.Delivery{
+		Acknowledger: acknowledger,
+		DeliveryTag:  42,
+		Exchange:     "amq.rabbitmq.trace",
+		RoutingKey:   "deliver.q1",
+		Headers: amqp.Table{
+			"exchange_name": "",
+			"routing_keys":  []interface{}{"q1"},
+			"redelivered":   int8(0),
+			"properties": amqp.Table{
+				"content_type":  "text/plain",
+				"delivery_mode": int8(2),
+				"priority":      uint8(3),
+				"timestamp":     ts,
+				"headers":       amqp.Table{"header1": "test0"},
+			},
+		},
+		Body: []byte("body"),
+	}
+	received := time.Now()
+
+	// when
+	transformed, err := FromFireHoseTapMessage(rabtap.NewTapMessage(&delivery, received))
+
+	// then
+	require.NoError(t, err)
+	m := transformed.AmqpMessage
+	assert.Equal(t, acknowledger, m.Acknowledger)
+	assert.Equal(t, uint64(42), m.DeliveryTag)
+	assert.Equal(t, "", m.Exchange)
+	assert.Equal(t, "q1", m.RoutingKey)
+	assert.Equal(t, "text/plain", m.ContentType)
+	assert.Equal(t, uint8(2), m.DeliveryMode)
+	assert.Equal(t, uint8(3), m.Priority)
+	assert.Equal(t, ts.Unix(), m.Timestamp.Unix())
+	assert.Equal(t, amqp.Table{"header1": "test0"}, m.Headers)
+	assert.Equal(t, []byte("body"), m.Body)
+	assert.Equal(t, received, transformed.ReceivedTimestamp)
+	// original delivery is unchanged
+	assert.Equal(t, "amq.rabbitmq.trace", delivery.Exchange)
+}
+
+func TestFromFireHoseTapMessageReturnsOtherMessagesUnchanged(t *testing.T) {
+	delivery := amqp.Delivery{Exchange: "amq.topic", RoutingKey: "publish.key"}
+	msg := rabtap.NewTapMessage(&delivery, time.Now())
+
+	transformed, err := FromFireHoseTapMessage(msg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, msg, transformed)
+}
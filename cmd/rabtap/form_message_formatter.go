@@ -0,0 +1,53 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FormMessageFormatter prints form-encoded messages as a table of decoded
+// keys and values.
+type FormMessageFormatter struct{}
+
+var (
+	_ = func() struct{} {
+		RegisterMessageFormatter("application/x-www-form-urlencoded", FormMessageFormatter{})
+		return struct{}{}
+	}()
+)
+
+// Format tries to decode a form-encoded message and prints each key/value
+// pair on a line, in the order of the message. If the message can not be
+// decoded, it will be returned unformatted as-is.
+func (s FormMessageFormatter) Format(body []byte) string {
+	query := strings.TrimSpace(string(body))
+	if query == "" {
+		return string(body)
+	}
+	var keys, values []string
+	width := 0
+	for pair := range strings.SplitSeq(query, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return string(body)
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return string(body)
+		}
+		keys, values = append(keys, key), append(values, value)
+		width = max(width, len(key))
+	}
+	lines := make([]string, len(keys))
+	for i := range keys {
+		lines[i] = strings.TrimRight(fmt.Sprintf("%-*s  %s", width, keys[i], values[i]), " ")
+	}
+	return strings.Join(lines, "\n")
+}
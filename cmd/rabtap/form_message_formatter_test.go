@@ -0,0 +1,22 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormFormatterPrintsKeysAndValues(t *testing.T) {
+	body := "name=Jane+Doe&city=M%C3%BCnchen&tag=a&tag=b&empty="
+
+	formatted := FormMessageFormatter{}.Format([]byte(body))
+
+	assert.Equal(t, "name   Jane Doe\ncity   München\ntag    a\ntag    b\nempty", formatted)
+}
+
+func TestFormFormatterReturnsInvalidMessageAsIs(t *testing.T) {
+	assert.Equal(t, "a=%zz", FormMessageFormatter{}.Format([]byte("a=%zz")))
+	assert.Equal(t, "", FormMessageFormatter{}.Format([]byte("")))
+}
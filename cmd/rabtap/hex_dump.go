@@ -0,0 +1,65 @@
+// Copyright (C) 2026 Jan Delgado
+// Render binary message bodies as hex dump.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const hexDumpBytesPerLine = 16
+
+// maxControlCharRatio is the maximum ratio of control characters in a text
+const maxControlCharRatio = 0.1
+
+// hexDump renders data like 'xxd' as lines of offset, hex values and ASCII
+// characters, e.g.
+// 00000000: 4865 6c6c 6f0a 0001                      Hello...
+func hexDump(data []byte) string {
+	var b strings.Builder
+	for offset := 0; offset < len(data); offset += hexDumpBytesPerLine {
+		line := data[offset:min(offset+hexDumpBytesPerLine, len(data))]
+		if offset > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%08x:", offset)
+		for i := range hexDumpBytesPerLine {
+			if i%2 == 0 {
+				b.WriteByte(' ')
+			}
+			if i < len(line) {
+				fmt.Fprintf(&b, "%02x", line[i])
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteString("  ")
+		for _, c := range line {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+	}
+	return b.String()
+}
+
+// isBinary returns true if s is not valid UTF-8 or if more than 10% of its
+// characters are control characters other than white space.
+func isBinary(s string) bool {
+	if !utf8.ValidString(s) {
+		return true
+	}
+	var n, control int
+	for _, r := range s {
+		n++
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			control++
+		}
+	}
+	return control > 0 && float64(control) > maxControlCharRatio*float64(n)
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHexDumpRendersLikeXxd(t *testing.T) {
+	data := []byte("Hello World!\n\x00\x01\x02rabtap")
+
+	assert.Equal(t,
+		"00000000: 4865 6c6c 6f20 576f 726c 6421 0a00 0102  Hello World!....\n"+
+			"00000010: 7261 6274 6170                           rabtap",
+		hexDump(data))
+}
+
+func TestHexDumpOfEmptyData(t *testing.T) {
+	assert.Equal(t, "", hexDump(nil))
+}
+
+func TestIsBinary(t *testing.T) {
+	testcases := []struct {
+		s        string
+		expected bool
+	}{
+		{"", false},
+		{"hello\r\n\tworld", false},
+		{"grüße", false},
+		{"\xff\xfe", true},
+		{"\x00\x01\x02\x03abc", true},
+		{"one \x1b[1mbold\x1b[0m word in a long line of text.........", false},
+		{"\x1b[94ma\x1b[0m=\x1b[93m1\x1b[0m", true},
+	}
+	for _, tc := range testcases {
+		assert.Equal(t, tc.expected, isBinary(tc.s), tc.s)
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -56,3 +57,38 @@ func (s JSONMessageFormatter) Format(body []byte) string {
 	}
 	return string(formatted)
 }
+
+// formatAsJSON pretty prints a decoded value of a binary format like
+// msgpack or CBOR as JSON. Maps with non-string keys are converted to maps
+// with string keys.
+func formatAsJSON(value interface{}) (string, error) {
+	formatted, err := json.MarshalIndent(jsonCompatible(value), "", "  ")
+	return string(formatted), err
+}
+
+// jsonCompatible converts maps with arbitrary keys, as decoded by msgpack
+// and CBOR decoders, to maps with string keys.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(elem)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[key] = jsonCompatible(elem)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, elem := range v {
+			a[i] = jsonCompatible(elem)
+		}
+		return a
+	default:
+		return v
+	}
+}
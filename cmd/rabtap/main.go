@@ -7,6 +7,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
 	"os"
@@ -77,10 +78,23 @@ func startCmdInfo(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Conf
 
 // createMessageReaderForPublish returns a message source that reads
 // messages from the given source in the specified format. The source can
-// be either empty (=stdin), a filename or a directory name
-func newPublishMessageSource(source *string, format string) (MessageSource, error) {
+// be either empty (=stdin), a filename or a directory name. The mapping
+// is used with the csv format. If splitter is set, a raw file or stdin is
+// split into multiple messages.
+func newPublishMessageSource(source *string, format string, mapping CSVMapping,
+	splitter *messageSplitter,
+) (MessageSource, error) {
+	newReaderMessageSource := func(reader io.ReadCloser) (MessageSource, error) {
+		switch {
+		case format == "csv":
+			return NewCSVMessageSource(reader, mapping)
+		case splitter != nil:
+			return NewSplittingMessageSource(reader, *splitter), nil
+		}
+		return NewReaderMessageSource(format, reader)
+	}
 	if source == nil {
-		return NewReaderMessageSource(format, os.Stdin)
+		return newReaderMessageSource(os.Stdin)
 	}
 
 	fi, err := os.Stat(*source)
@@ -94,7 +108,7 @@ func newPublishMessageSource(source *string, format string) (MessageSource, erro
 			return nil, fmt.Errorf("open message source file: %w", err)
 		}
 		// TODO close file
-		return NewReaderMessageSource(format, file)
+		return newReaderMessageSource(file)
 	} else {
 
 		metadataFiles, err := LoadMetadataFilesFromDir(*source, os.ReadDir, NewRabtapFileInfoPredicate())
@@ -115,39 +129,107 @@ func startCmdPublish(ctx context.Context, args CommandLineArgs, tlsConfig *tls.C
 	if args.Format == "raw" && args.PubExchange == nil && args.PubRoutingKey == nil {
 		logger.Warn("using raw message format but neither exchange or routing key are set.")
 	}
-	source, err := newPublishMessageSource(args.Source, args.Format)
-	if err != nil {
-		return fmt.Errorf("message source: %w", err)
+	routingKey, headers := args.PubRoutingKey, args.Args
+	var source MessageSource
+	var err error
+	if args.Generate != nil {
+		// routing key and headers are rendered by the generator
+		tpl, err := readTemplateArg(*args.Generate)
+		if err != nil {
+			return err
+		}
+		source, err = NewGeneratorMessageSource(tpl, routingKey, headers, args.Count)
+		if err != nil {
+			return fmt.Errorf("message generator: %w", err)
+		}
+		routingKey, headers = nil, nil
+	} else {
+		var splitter *messageSplitter
+		if args.Split != nil {
+			s, err := newMessageSplitter(*args.Split)
+			if err != nil {
+				return fmt.Errorf("--split: %w", err)
+			}
+			splitter = &s
+		}
+		source, err = newPublishMessageSource(args.Source, args.Format, args.CSVMapping, splitter)
+		if err != nil {
+			return fmt.Errorf("message source: %w", err)
+		}
+	}
+	transformers := []MessageTransformer{FireHoseTransformer, NewPropertiesTransformer(args.Properties)}
+	if args.EncodeBody {
+		transformers = append(transformers, BodyEncodingTransformer)
 	}
-	source = NewTransformingMessageSource(source,
-		FireHoseTransformer,
-		NewPropertiesTransformer(args.Properties))
+	source = NewTransformingMessageSource(source, transformers...)
 
 	return cmdPublish(ctx, CmdPublishArg{
-		amqpURL:    args.AMQPURL,
-		exchange:   args.PubExchange,
-		routingKey: args.PubRoutingKey,
-		headers:    args.Args,
-		fixedDelay: args.Delay,
-		speed:      args.Speed,
-		tlsConfig:  tlsConfig,
-		mandatory:  args.Mandatory,
-		confirms:   args.Confirms,
-		source:     source,
+		amqpURL:        args.AMQPURL,
+		exchange:       args.PubExchange,
+		routingKey:     routingKey,
+		headers:        headers,
+		fixedDelay:     args.Delay,
+		speed:          args.Speed,
+		rate:           args.Rate,
+		parallel:       args.Parallel,
+		tlsConfig:      tlsConfig,
+		mandatory:      args.Mandatory,
+		confirms:       args.Confirms,
+		confirmWindow:  args.ConfirmWindow,
+		retries:        args.Retries,
+		retryBackoff:   args.RetryBackoff,
+		deadLetterFile: args.DeadLetterFile,
+		source:         source,
+		// keep stdout clean for scripts
+		out: os.Stderr,
 	}, logger)
 }
 
-func startCmdSubscribe(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
+// newTapSubMessageSink returns the message sink of the tap and sub command.
+// Registers the protobuf and Avro decoders, if configured.
+func newTapSubMessageSink(args CommandLineArgs, tlsConfig *tls.Config, out *os.File) (MessageSink, error) {
+	if args.Protobuf != nil {
+		decoder, err := LoadProtobufDecoder(*args.Protobuf)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf: %w", err)
+		}
+		RegisterProtobufDecoder(decoder)
+	}
+	if args.AvroSchemas != nil {
+		decoder, err := NewAvroDecoder(*args.AvroSchemas, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("avro: %w", err)
+		}
+		RegisterAvroDecoder(decoder)
+	}
+	var tpl string
+	if args.Template != nil {
+		var err error
+		if tpl, err = readMessageTemplate(*args.Template); err != nil {
+			return nil, err
+		}
+	}
 	opts := MessageSinkOptions{
 		out:              NewColorableWriter(out),
 		format:           args.Format,
+		template:         tpl,
+		bodyMode:         args.BodyMode,
 		silent:           args.Silent,
 		optSaveDir:       args.SaveDir,
+		keepCharset:      args.KeepCharset,
 		filenameProvider: defaultFilenameProvider,
 	}
 	messageSink, err := NewMessageSink(opts)
 	if err != nil {
-		return fmt.Errorf("create message sink: %w", err)
+		return nil, fmt.Errorf("create message sink: %w", err)
+	}
+	return messageSink, nil
+}
+
+func startCmdSubscribe(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
+	messageSink, err := newTapSubMessageSink(args, tlsConfig, out)
+	if err != nil {
+		return err
 	}
 
 	termPred, err := NewLoopCountPred(args.Limit)
@@ -159,26 +241,49 @@ func startCmdSubscribe(ctx context.Context, args CommandLineArgs, tlsConfig *tls
 		return fmt.Errorf("message filter predicate: %w", err)
 	}
 
+	var apiClient *rabtap.RabbitHTTPClient
+	if args.APIURL != nil {
+		apiClient = rabtap.NewRabbitHTTPClient(args.APIURL, tlsConfig)
+	}
+	subscriptions, err := resolveSubscriptions(ctx, args.SubConfig, apiClient)
+	if err != nil {
+		return err
+	}
+
+	offsetStateDir := ""
+	if args.ConsumerName != "" && args.StreamConfig == nil {
+		if offsetStateDir, err = defaultOffsetStateDir(); err != nil {
+			return fmt.Errorf("offset state directory: %w", err)
+		}
+	}
+
 	return cmdSubscribe(ctx, CmdSubscribeArg{
-		amqpURL:     args.AMQPURL,
-		queue:       args.QueueName,
-		requeue:     args.Requeue,
-		reject:      args.Reject,
-		tlsConfig:   tlsConfig,
-		messageSink: messageSink,
-		filterPred:  filterPred,
-		termPred:    termPred,
-		args:        args.Args,
-		timeout:     args.IdleTimeout,
+		subscriptions:  subscriptions,
+		requeue:        args.Requeue,
+		reject:         args.Reject,
+		tlsConfig:      tlsConfig,
+		messageSink:    messageSink,
+		filterPred:     filterPred,
+		termPred:       termPred,
+		args:           args.Args,
+		timeout:        args.IdleTimeout,
+		streamConfig:   args.StreamConfig,
+		consumerName:   args.ConsumerName,
+		resume:         args.Resume,
+		offsetStateDir: offsetStateDir,
+		prefetch:       args.Prefetch,
+		ackInterval:    args.AckInterval,
 	}, logger)
 }
 
-func startCmdTap(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
+func startCmdQueuePeek(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
 	opts := MessageSinkOptions{
 		out:              NewColorableWriter(out),
 		format:           args.Format,
+		bodyMode:         args.BodyMode,
 		silent:           args.Silent,
 		optSaveDir:       args.SaveDir,
+		keepCharset:      args.KeepCharset,
 		filenameProvider: defaultFilenameProvider,
 	}
 	messageSink, err := NewMessageSink(opts)
@@ -186,6 +291,52 @@ func startCmdTap(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Confi
 		return fmt.Errorf("create message sink: %w", err)
 	}
 
+	return cmdQueuePeek(ctx, CmdQueuePeekArg{
+		amqpURL:     args.AMQPURL,
+		queue:       args.QueueName,
+		limit:       args.Limit,
+		tlsConfig:   tlsConfig,
+		messageSink: messageSink,
+	}, logger)
+}
+
+func startCmdQueueMove(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
+	filterPred, err := NewExprPredicate(args.Filter)
+	if err != nil {
+		return fmt.Errorf("message filter predicate: %w", err)
+	}
+	transformers := []MessageTransformer{NewPropertiesTransformer(args.Properties)}
+	if args.StripDeathHeaders {
+		transformers = append(transformers, DeathHeadersTransformer)
+	}
+	for _, name := range args.Transformations {
+		transformer, err := NewNamedMessageTransformer(name)
+		if err != nil {
+			return err
+		}
+		transformers = append(transformers, transformer)
+	}
+
+	return cmdQueueMove(ctx, CmdQueueMoveArg{
+		amqpURL:      args.AMQPURL,
+		tlsConfig:    tlsConfig,
+		queue:        args.QueueName,
+		exchange:     *args.PubExchange,
+		routingKey:   args.PubRoutingKey,
+		filterPred:   filterPred,
+		limit:        args.Limit,
+		transformers: transformers,
+		idleTimeout:  defaultMoveIdleTimeout,
+		out:          out,
+	}, logger)
+}
+
+func startCmdTap(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Config, out *os.File, logger *slog.Logger) error {
+	messageSink, err := newTapSubMessageSink(args, tlsConfig, out)
+	if err != nil {
+		return err
+	}
+
 	termPred, err := NewLoopCountPred(args.Limit)
 	if err != nil {
 		return fmt.Errorf("message limit predicate: %w", err)
@@ -196,14 +347,22 @@ func startCmdTap(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Confi
 		return fmt.Errorf("message filter predicate: %w", err)
 	}
 
+	var apiClient *rabtap.RabbitHTTPClient
+	if args.APIURL != nil {
+		apiClient = rabtap.NewRabbitHTTPClient(args.APIURL, tlsConfig)
+	}
+
 	return cmdTap(ctx,
 		CmdTapArg{
-			tapConfig:   args.TapConfig,
-			tlsConfig:   tlsConfig,
-			messageSink: messageSink,
-			filterPred:  filterPred,
-			termPred:    termPred,
-			timeout:     args.IdleTimeout,
+			tapConfig:         args.TapConfig,
+			tlsConfig:         tlsConfig,
+			messageSink:       messageSink,
+			filterPred:        filterPred,
+			termPred:          termPred,
+			timeout:           args.IdleTimeout,
+			apiClient:         apiClient,
+			discoveryInterval: defaultDiscoveryInterval,
+			fireHose:          args.FireHose,
 		}, logger)
 }
 
@@ -229,6 +388,9 @@ func dispatchCmd(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Confi
 		return startCmdPublish(ctx, args, tlsConfig, logger)
 	case TapCmd:
 		return startCmdTap(ctx, args, tlsConfig, out, logger)
+	case TapCleanupCmd:
+		return cmdTapCleanup(ctx, rabtap.NewRabbitHTTPClient(args.APIURL, tlsConfig),
+			args.DryRun, logger)
 	case ExchangeCreateCmd:
 		return cmdExchangeCreate(CmdExchangeCreateArg{
 			amqpURL:  args.AMQPURL,
@@ -257,6 +419,10 @@ func dispatchCmd(ctx context.Context, args CommandLineArgs, tlsConfig *tls.Confi
 		return cmdQueueRemove(args.AMQPURL, args.QueueName, tlsConfig, logger)
 	case QueuePurgeCmd:
 		return cmdQueuePurge(args.AMQPURL, args.QueueName, tlsConfig, logger)
+	case QueuePeekCmd:
+		return startCmdQueuePeek(ctx, args, tlsConfig, out, logger)
+	case QueueMoveCmd:
+		return startCmdQueueMove(ctx, args, tlsConfig, out, logger)
 	case QueueBindCmd:
 		return cmdQueueBindToExchange(CmdQueueBindArg{
 			amqpURL:  args.AMQPURL,
@@ -299,6 +465,7 @@ func rabtapMain(out *os.File) {
 	logOut := os.Stderr
 	logColored := args.ForceColor || (!args.NoColor && isatty.IsTerminal(logOut.Fd()))
 	logger := initLogging(logOut, args.Verbose, logColored)
+	slog.SetDefault(logger)
 
 	tlsConfig, err := getTLSConfig(args.InsecureTLS, args.TLSCertFile, args.TLSKeyFile, args.TLSCaFile)
 	if err != nil {
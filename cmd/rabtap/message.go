@@ -36,6 +36,7 @@ type RabtapPersistentMessage struct {
 
 	// rabtap specific fields
 	XRabtapReceivedTimestamp time.Time
+	XRabtapQueue             string `json:",omitempty"`
 
 	// will be serialized as base64
 	Body []byte
@@ -78,10 +79,35 @@ func NewRabtapPersistentMessage(message rabtap.TapMessage) RabtapPersistentMessa
 		Exchange:                 m.Exchange,
 		RoutingKey:               m.RoutingKey,
 		XRabtapReceivedTimestamp: message.ReceivedTimestamp,
+		XRabtapQueue:             message.Queue,
 		Body:                     m.Body,
 	}
 }
 
+// NewRabtapPersistentMessageFromPublishing creates a RabtapPersistentMessage
+// object from a published message and its routing. Like during publishing,
+// the headers are taken from the routing.
+func NewRabtapPersistentMessageFromPublishing(routing rabtap.Routing, p amqp.Publishing) RabtapPersistentMessage {
+	return RabtapPersistentMessage{
+		Headers:         routing.Headers(),
+		ContentType:     p.ContentType,
+		ContentEncoding: p.ContentEncoding,
+		DeliveryMode:    p.DeliveryMode,
+		Priority:        p.Priority,
+		CorrelationID:   p.CorrelationId,
+		ReplyTo:         p.ReplyTo,
+		Expiration:      p.Expiration,
+		MessageID:       p.MessageId,
+		Timestamp:       p.Timestamp,
+		Type:            p.Type,
+		UserID:          p.UserId,
+		AppID:           p.AppId,
+		Exchange:        routing.Exchange(),
+		RoutingKey:      routing.Key(),
+		Body:            p.Body,
+	}
+}
+
 // ToAmqpPublishing converts message to an amqp.Publishing object
 func (s *RabtapPersistentMessage) ToAmqpPublishing() amqp.Publishing {
 	return amqp.Publishing{
@@ -0,0 +1,149 @@
+// Copyright (C) 2026 Jan Delgado
+// Message source generating synthetic messages from templates.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	uuid "github.com/google/uuid"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+const randomStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// messageGenerator renders the body, routing key and headers of each
+// generated message from Go templates.
+type messageGenerator struct {
+	seq        int64
+	count      int64
+	body       *template.Template
+	routingKey *template.Template
+	headers    map[string]*template.Template
+}
+
+// funcMap returns the helper functions available in the templates
+func (s *messageGenerator) funcMap() template.FuncMap {
+	return template.FuncMap{
+		// Seq returns the sequence number of the message, starting with 1
+		"Seq": func() int64 { return s.seq },
+		"UUID": func() string {
+			return uuid.Must(uuid.NewRandom()).String()
+		},
+		// RandInt returns a random int in the interval [from, to]
+		"RandInt": func(from, to int) (int, error) {
+			if to < from {
+				return 0, fmt.Errorf("RandInt: invalid interval [%d, %d]", from, to)
+			}
+			return from + rand.IntN(to-from+1), nil
+		},
+		// RandString returns a random alphanumeric string of length n
+		"RandString": func(n int) string {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = randomStringChars[rand.IntN(len(randomStringChars))]
+			}
+			return string(b)
+		},
+		"Now": time.Now,
+		// Pick returns one of its arguments, chosen randomly
+		"Pick": func(choices ...string) string {
+			if len(choices) == 0 {
+				return ""
+			}
+			return choices[rand.IntN(len(choices))]
+		},
+	}
+}
+
+func (s *messageGenerator) parse(name, text string) (*template.Template, error) {
+	tpl, err := template.New(name).Funcs(s.funcMap()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s template: %w", name, err)
+	}
+	return tpl, nil
+}
+
+func render(tpl *template.Template) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// next renders the next message
+func (s *messageGenerator) next() (RabtapPersistentMessage, error) {
+	if s.count > 0 && s.seq >= s.count {
+		return RabtapPersistentMessage{}, io.EOF
+	}
+	s.seq++
+
+	var m RabtapPersistentMessage
+	body, err := render(s.body)
+	if err != nil {
+		return m, err
+	}
+	m.Body = []byte(body)
+	if s.routingKey != nil {
+		if m.RoutingKey, err = render(s.routingKey); err != nil {
+			return m, err
+		}
+	}
+	if len(s.headers) > 0 {
+		m.Headers = make(map[string]interface{}, len(s.headers))
+		for key, tpl := range s.headers {
+			if m.Headers[key], err = render(tpl); err != nil {
+				return m, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// NewGeneratorMessageSource returns a MessageSource generating count
+// messages, or an endless stream of messages when count is 0. The body, the
+// optional routing key and the header values are Go templates, which are
+// rendered for each message.
+func NewGeneratorMessageSource(body string, routingKey *string,
+	headers rabtap.KeyValueMap, count int64,
+) (MessageSource, error) {
+	g := &messageGenerator{count: count, headers: map[string]*template.Template{}}
+	var err error
+	if g.body, err = g.parse("body", body); err != nil {
+		return nil, err
+	}
+	if routingKey != nil {
+		if g.routingKey, err = g.parse("routingkey", *routingKey); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range headers {
+		if g.headers[key], err = g.parse("header "+key, value); err != nil {
+			return nil, err
+		}
+	}
+	return g.next, nil
+}
+
+// readTemplateArg returns the template given with --generate or --template,
+// which is read from a file if prefixed with '@'.
+func readTemplateArg(arg string) (string, error) {
+	filename, isFile := strings.CutPrefix(arg, "@")
+	if !isFile {
+		return arg, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("read template: %w", err)
+	}
+	return string(data), nil
+}
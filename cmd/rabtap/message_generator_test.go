@@ -0,0 +1,80 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+func TestGeneratorMessageSourceGeneratesCountMessages(t *testing.T) {
+	routingKey := `orders.{{ Pick "eu" }}`
+	headers := rabtap.KeyValueMap{"seq": "{{ Seq }}"}
+	source, err := NewGeneratorMessageSource(`{"id":{{ Seq }}}`, &routingKey, headers, 2)
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		m, err := source()
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":`+strconv.Itoa(i)+`}`, string(m.Body))
+		assert.Equal(t, "orders.eu", m.RoutingKey)
+		assert.Equal(t, map[string]interface{}{"seq": strconv.Itoa(i)}, m.Headers)
+	}
+	_, err = source()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestGeneratorMessageSourceIsEndlessWhenCountIsZero(t *testing.T) {
+	source, err := NewGeneratorMessageSource("hello", nil, nil, 0)
+	require.NoError(t, err)
+
+	for range 1000 {
+		m, err := source()
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(m.Body))
+		assert.Empty(t, m.RoutingKey)
+		assert.Nil(t, m.Headers)
+	}
+}
+
+func TestGeneratorMessageSourceProvidesHelpers(t *testing.T) {
+	tpl := `{{ UUID }}|{{ RandInt 5 7 }}|{{ RandString 8 }}|{{ Now.Year }}|{{ Pick "a" "b" }}`
+	source, err := NewGeneratorMessageSource(tpl, nil, nil, 1)
+	require.NoError(t, err)
+
+	m, err := source()
+
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f-]{36}\|[5-7]\|[a-zA-Z0-9]{8}\|\d{4}\|[ab]$`, string(m.Body))
+}
+
+func TestGeneratorMessageSourceFailsOnInvalidTemplate(t *testing.T) {
+	_, err := NewGeneratorMessageSource("{{ Unknown }}", nil, nil, 1)
+	assert.ErrorContains(t, err, "parse body template")
+
+	source, err := NewGeneratorMessageSource("{{ RandInt 7 5 }}", nil, nil, 1)
+	require.NoError(t, err)
+	_, err = source()
+	assert.ErrorContains(t, err, "invalid interval")
+}
+
+func TestReadTemplateArgReadsTemplateFromFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "body.tpl")
+	require.NoError(t, os.WriteFile(filename, []byte("{{ Seq }}"), 0o644))
+
+	tpl, err := readTemplateArg("@" + filename)
+	require.NoError(t, err)
+	assert.Equal(t, "{{ Seq }}", tpl)
+
+	tpl, err = readTemplateArg("{{ UUID }}")
+	require.NoError(t, err)
+	assert.Equal(t, "{{ UUID }}", tpl)
+}
@@ -3,16 +3,25 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
+	"unicode/utf8"
 
 	rabtap "github.com/jandelgado/rabtap/pkg"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 // messageTemplate is the default template to print a message
-// TODO allow externalization of template
 const messageTemplate = `------ message received on {{ .Message.ReceivedTimestamp.Format "2006-01-02T15:04:05Z07:00" }} ------
-exchange.......: {{ ExchangeColor .Message.AmqpMessage.Exchange }}
+{{with .Message.Queue}}queue..........: {{ QueueColor .}}
+{{end}}exchange.......: {{ ExchangeColor .Message.AmqpMessage.Exchange }}
 {{with .Message.AmqpMessage.RoutingKey}}routingkey.....: {{ KeyColor .}}
 {{end}}{{with .Message.AmqpMessage.Priority}}priority.......: {{.}}
 {{end}}{{with .Message.AmqpMessage.Expiration}}expiration.....: {{.}}
@@ -31,6 +40,46 @@ exchange.......: {{ ExchangeColor .Message.AmqpMessage.Exchange }}
 
 `
 
+// messageTemplateOneLine prints a message on a single line, e.g. to grep
+// through the output
+const messageTemplateOneLine = `{{ .Message.ReceivedTimestamp.Format "2006-01-02T15:04:05.000Z07:00" }}` +
+	`{{with .Message.Queue}} queue={{ QueueColor .}}{{end}}` +
+	` exchange={{ ExchangeColor (or .Message.AmqpMessage.Exchange "-") }}` +
+	` routingkey={{ KeyColor (or .Message.AmqpMessage.RoutingKey "-") }}` +
+	`{{with .Message.AmqpMessage.MessageId}} id={{.}}{{end}}` +
+	`{{with .Message.AmqpMessage.Headers}} headers={{ json . }}{{end}}` +
+	` {{ MessageColor (call .Body | oneline | truncate 500) }}
+`
+
+// messageTemplateMarkdown prints a message as markdown, e.g. to paste it
+// into a ticket
+const messageTemplateMarkdown = `#### Message received on {{ .Message.ReceivedTimestamp.Format "2006-01-02T15:04:05Z07:00" }}
+
+| Field | Value |
+| --- | --- |
+{{with .Message.Queue}}| queue | ` + "`{{.}}`" + ` |
+{{end}}| exchange | ` + "`{{ .Message.AmqpMessage.Exchange }}`" + ` |
+{{with .Message.AmqpMessage.RoutingKey}}| routingkey | ` + "`{{.}}`" + ` |
+{{end}}{{with .Message.AmqpMessage.ContentType}}| content-type | {{.}} |
+{{end}}{{with .Message.AmqpMessage.ContentEncoding}}| content-enc | {{.}} |
+{{end}}{{with .Message.AmqpMessage.MessageId}}| app-message-id | {{.}} |
+{{end}}{{with .Message.AmqpMessage.CorrelationId}}| app-corr-id | {{.}} |
+{{end}}{{if not .Message.AmqpMessage.Timestamp.IsZero}}| app-timestamp | {{ .Message.AmqpMessage.Timestamp }} |
+{{end}}{{with .Message.AmqpMessage.Headers}}| app-headers | ` + "`{{ json . }}`" + ` |
+{{end}}
+` + "```" + `
+{{ call .Body }}
+` + "```" + `
+
+`
+
+// messageTemplatePresets are the templates selectable by name with --template
+var messageTemplatePresets = map[string]string{
+	"default":  messageTemplate,
+	"oneline":  messageTemplateOneLine,
+	"markdown": messageTemplateMarkdown,
+}
+
 // PrintMessageEnv holds info for template
 type PrintMessageEnv struct {
 	// Message receveived
@@ -44,6 +93,26 @@ type MessageBodyFormatter interface {
 	Format(body []byte) string
 }
 
+// MessageFormatter is implemented by message formatters which need the
+// properties of a message to format its body, e.g. the type of the message.
+type MessageFormatter interface {
+	FormatMessage(message *amqp.Delivery, body []byte) string
+}
+
+// MessageDecoder is implemented by message formatters which decode binary
+// messages, e.g. protobuf, into a generic structure.
+type MessageDecoder interface {
+	Unmarshal(message *amqp.Delivery) (map[string]interface{}, error)
+}
+
+// formatBody formats the (decoded) body of the message using the formatter
+func formatBody(formatter MessageBodyFormatter, message *amqp.Delivery, body []byte) string {
+	if f, ok := formatter.(MessageFormatter); ok {
+		return f.FormatMessage(message, body)
+	}
+	return formatter.Format(body)
+}
+
 // Registry of available message formatters. Key is contentType
 var messageFormatters = map[string]MessageBodyFormatter{}
 
@@ -53,34 +122,237 @@ func RegisterMessageFormatter(contentType string, formatter MessageBodyFormatter
 	messageFormatters[contentType] = formatter
 }
 
+// mediaType returns the media type of the given content type, i.e. without
+// parameters like "; charset=utf-8", in lower case.
+func mediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
 // NewMessageFormatter return a message formatter suitable the given
-// contentType.
+// contentType. Parameters of the content type are ignored.
 func NewMessageFormatter(contentType string) MessageBodyFormatter {
 	if formatter, ok := messageFormatters[contentType]; ok {
 		return formatter
 	}
+	if formatter, ok := messageFormatters[mediaType(contentType)]; ok {
+		return formatter
+	}
 	return DefaultMessageFormatter{}
 }
 
-// PrettyPrintMessage formats and prints a tapped message
-func PrettyPrintMessage(out io.Writer, message rabtap.TapMessage) error {
+// BodyMode controls how message bodies are printed
+type BodyMode string
+
+const (
+	// BodyModeAuto formats bodies by their content type and prints binary
+	// bodies as hex dump
+	BodyModeAuto BodyMode = "auto"
+	// BodyModeText formats bodies by their content type
+	BodyModeText BodyMode = "text"
+	// BodyModeHex prints bodies as hex dump
+	BodyModeHex BodyMode = "hex"
+	// BodyModeBase64 prints bodies base64 encoded
+	BodyModeBase64 BodyMode = "base64"
+)
+
+// MessagePrinter prints messages using a template
+type MessagePrinter struct {
+	tpl      *template.Template
+	bodyMode BodyMode
+	mu       sync.Mutex
+	// current is the message being printed, which is used by the header
+	// and bodyField template functions
+	current *rabtap.TapMessage
+	// currentBody is the body of the current message decoded as JSON, which
+	// is decoded on first use by bodyField
+	currentBody     interface{}
+	currentBodyRead bool
+}
 
-	formatter := NewMessageFormatter(message.AmqpMessage.ContentType)
+// NewMessagePrinter returns a MessagePrinter for the given template, which
+// is executed with a PrintMessageEnv. Besides the color functions, the
+// following functions are available in the template:
+//   - json VALUE - VALUE as JSON
+//   - header "KEY" - value of the header KEY of the message
+//   - bodyField "A.B" - field A.B of the JSON body of the message, array
+//     elements are selected by their index, e.g. "items.0.id"
+//   - truncate N STRING - STRING truncated to N characters
+//   - oneline STRING - STRING with line breaks replaced by a space
+//   - hexdump DATA - DATA (string or []byte) as xxd-style hex dump
+//   - base64 DATA - DATA (string or []byte) base64 encoded
+//
+// The bodyMode controls how the body is formatted by the Body function.
+func NewMessagePrinter(text string, bodyMode BodyMode) (*MessagePrinter, error) {
+	s := &MessagePrinter{bodyMode: bodyMode}
+	funcs := MergeTemplateFuncs(NewColorPrinter().GetFuncMap(), template.FuncMap{
+		"json":      toJSON,
+		"header":    s.header,
+		"bodyField": s.bodyField,
+		"truncate":  truncate,
+		"oneline":   oneline,
+		"hexdump":   withBytes(hexDump),
+		"base64":    withBytes(base64.StdEncoding.EncodeToString),
+	})
+	tpl, err := template.New("message").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse message template: %w", err)
+	}
+	s.tpl = tpl
+	return s, nil
+}
+
+// Print formats and prints the given message
+func (s *MessagePrinter) Print(out io.Writer, message rabtap.TapMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current, s.currentBody, s.currentBodyRead = &message, nil, false
 
 	printEnv := PrintMessageEnv{
 		Message: message,
 		Body: func() string {
-			if b, err := Body(message.AmqpMessage); err != nil {
-				// decoding failed, printing body as-is
-				return formatter.Format(message.AmqpMessage.Body)
-			} else {
-				return formatter.Format(b)
-			}
+			return s.formatBody(message.AmqpMessage)
 		},
 	}
+	return s.tpl.Execute(out, printEnv)
+}
+
+// formatBody formats the (decompressed) body of the message as configured by
+// the body mode
+func (s *MessagePrinter) formatBody(message *amqp.Delivery) string {
+	body, err := Body(message)
+	if err != nil {
+		// decoding failed, printing body as-is
+		body = message.Body
+	}
+	switch s.bodyMode {
+	case BodyModeHex:
+		return hexDump(body)
+	case BodyModeBase64:
+		return base64.StdEncoding.EncodeToString(body)
+	}
+	formatted := formatBody(NewMessageFormatter(message.ContentType), message, body)
+	// only the unformatted body is checked, since formatters escape control
+	// characters and may add color codes
+	if s.bodyMode != BodyModeText && formatted == string(body) && isBinary(formatted) {
+		return hexDump(body)
+	}
+	return formatted
+}
+
+// withBytes makes f accept a string or a []byte
+func withBytes(f func([]byte) string) func(interface{}) (string, error) {
+	return func(data interface{}) (string, error) {
+		switch v := data.(type) {
+		case []byte:
+			return f(v), nil
+		case string:
+			return f([]byte(v)), nil
+		default:
+			return "", fmt.Errorf("expected string or []byte, got %T", data)
+		}
+	}
+}
+
+// header returns the value of the given header of the current message or an
+// empty string, if the header is not set
+func (s *MessagePrinter) header(key string) string {
+	value, ok := s.current.AmqpMessage.Headers[key]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// bodyField returns the field with the given path, e.g. "order.items.0.id",
+// of the JSON or decoded binary body of the current message. Strings are returned as-is, other
+// values as JSON. If the body is not JSON or the field does not exist, an
+// empty string is returned.
+func (s *MessagePrinter) bodyField(path string) (string, error) {
+	if !s.currentBodyRead {
+		s.currentBodyRead = true
+		s.currentBody = decodeBody(s.current.AmqpMessage)
+	}
+	value := s.currentBody
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", nil
+			}
+			value = v[i]
+		default:
+			return "", nil
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return toJSON(v)
+	}
+}
+
+// decodeBody decodes the body of the message using the MessageDecoder
+// registered for its content type, or as JSON otherwise. Returns nil if the
+// body can not be decoded.
+func decodeBody(m *amqp.Delivery) interface{} {
+	if decoder, ok := NewMessageFormatter(m.ContentType).(MessageDecoder); ok {
+		if decoded, err := decoder.Unmarshal(m); err == nil {
+			return decoded
+		}
+		return nil
+	}
+	var decoded interface{}
+	if b, err := Body(m); err == nil {
+		_ = json.Unmarshal(b, &decoded)
+	}
+	return decoded
+}
+
+func toJSON(value interface{}) (string, error) {
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// truncate truncates s to n characters, marking truncated strings with "..."
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
 
-	colorizer := NewColorPrinter()
-	t := template.Must(template.New("message").
-		Funcs(colorizer.GetFuncMap()).Parse(messageTemplate))
-	return t.Execute(out, printEnv)
+var lineBreaks = regexp.MustCompile(`\s*\r?\n\s*`)
+
+// oneline replaces line breaks, including surrounding white space, by a
+// single space
+func oneline(s string) string {
+	return strings.TrimSpace(lineBreaks.ReplaceAllString(s, " "))
+}
+
+// readMessageTemplate returns the template given with --template, which is
+// either the name of a preset template, a file containing the template when
+// given as '@FILE' or the template itself.
+func readMessageTemplate(arg string) (string, error) {
+	if tpl, ok := messageTemplatePresets[arg]; ok {
+		return tpl, nil
+	}
+	return readTemplateArg(arg)
+}
+
+// PrettyPrintMessage formats and prints a tapped message using the default
+// template
+func PrettyPrintMessage(out io.Writer, message rabtap.TapMessage) error {
+	printer, err := NewMessagePrinter(messageTemplate, BodyModeAuto)
+	if err != nil {
+		return err
+	}
+	return printer.Print(out, message)
 }
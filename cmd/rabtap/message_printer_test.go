@@ -3,7 +3,9 @@
 package main
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	rabtap "github.com/jandelgado/rabtap/pkg"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMessageFormatter(t *testing.T) {
@@ -21,6 +24,17 @@ func TestNewMessageFormatter(t *testing.T) {
 		NewMessageFormatter("unknown"))
 }
 
+func TestNewMessageFormatterIgnoresContentTypeParameters(t *testing.T) {
+	assert.Equal(t, JSONMessageFormatter{},
+		NewMessageFormatter("application/json; charset=utf-8"))
+	assert.Equal(t, XMLMessageFormatter{},
+		NewMessageFormatter("Text/XML;charset=ISO-8859-1"))
+	assert.Equal(t, YAMLMessageFormatter{},
+		NewMessageFormatter("application/yaml"))
+	assert.Equal(t, FormMessageFormatter{},
+		NewMessageFormatter("application/x-www-form-urlencoded"))
+}
+
 func ExamplePrettyPrintMessage() {
 
 	message := amqp.Delivery{
@@ -82,3 +96,183 @@ func ExamplePrettyPrintMessage_withFilteredAtributes() {
 	// simple test message
 	//
 }
+
+func ExamplePrettyPrintMessage_withQueue() {
+
+	message := amqp.Delivery{
+		Exchange: "exchange",
+		Body:     []byte("simple test message"),
+	}
+
+	color.NoColor = true
+	ts := time.Date(2019, time.June, 6, 23, 0, 0, 0, time.UTC)
+	tapMessage := rabtap.NewTapMessage(&message, ts)
+	tapMessage.Queue = "orders.dlq"
+	_ = PrettyPrintMessage(os.Stdout, tapMessage)
+
+	// Output:
+	// ------ message received on 2019-06-06T23:00:00Z ------
+	// queue..........: orders.dlq
+	// exchange.......: exchange
+	// simple test message
+	//
+}
+
+func ExampleMessagePrinter_oneline() {
+
+	message := amqp.Delivery{
+		Exchange:   "exchange",
+		RoutingKey: "orders.eu",
+		MessageId:  "4711",
+		Headers:    amqp.Table{"tenant": "acme"},
+		Body:       []byte("{\n  \"id\": 1\n}\n"),
+	}
+
+	color.NoColor = true
+	ts := time.Date(2019, time.June, 6, 23, 0, 0, 0, time.UTC)
+	printer, _ := NewMessagePrinter(messageTemplatePresets["oneline"], BodyModeAuto)
+	_ = printer.Print(os.Stdout, rabtap.NewTapMessage(&message, ts))
+
+	// Output:
+	// 2019-06-06T23:00:00.000Z exchange=exchange routingkey=orders.eu id=4711 headers={"tenant":"acme"} { "id": 1 }
+}
+
+func ExampleMessagePrinter_markdown() {
+
+	message := amqp.Delivery{
+		Exchange:    "exchange",
+		RoutingKey:  "orders.eu",
+		ContentType: "text/plain",
+		Body:        []byte("simple test message"),
+	}
+
+	color.NoColor = true
+	ts := time.Date(2019, time.June, 6, 23, 0, 0, 0, time.UTC)
+	printer, _ := NewMessagePrinter(messageTemplatePresets["markdown"], BodyModeAuto)
+	_ = printer.Print(os.Stdout, rabtap.NewTapMessage(&message, ts))
+
+	// Output:
+	// #### Message received on 2019-06-06T23:00:00Z
+	//
+	// | Field | Value |
+	// | --- | --- |
+	// | exchange | `exchange` |
+	// | routingkey | `orders.eu` |
+	// | content-type | text/plain |
+	//
+	// ```
+	// simple test message
+	// ```
+	//
+}
+
+func TestMessagePrinterProvidesHelpers(t *testing.T) {
+	message := amqp.Delivery{
+		Headers: amqp.Table{"tenant": "acme", "retries": int32(3)},
+		Body:    []byte(`{"order":{"id":"o-1","items":[{"sku":"A"},{"sku":"B"}]}}`),
+	}
+	tpl := `{{ header "tenant" }}|{{ header "retries" }}|{{ header "missing" }}|` +
+		`{{ bodyField "order.id" }}|{{ bodyField "order.items.1" }}|{{ bodyField "order.none" }}|` +
+		`{{ json .Message.AmqpMessage.Headers }}|{{ truncate 5 "hello world" }}|{{ oneline "a\n  b" }}`
+	printer, err := NewMessagePrinter(tpl, BodyModeAuto)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	err = printer.Print(&b, rabtap.NewTapMessage(&message, time.Now()))
+
+	require.NoError(t, err)
+	assert.Equal(t, `acme|3||o-1|{"sku":"B"}||{"retries":3,"tenant":"acme"}|hello...|a b`, b.String())
+}
+
+func TestMessagePrinterBodyFieldIgnoresNonJSONBody(t *testing.T) {
+	message := amqp.Delivery{Body: []byte("not json")}
+	printer, err := NewMessagePrinter(`[{{ bodyField "a.b" }}]`, BodyModeAuto)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	err = printer.Print(&b, rabtap.NewTapMessage(&message, time.Now()))
+
+	require.NoError(t, err)
+	assert.Equal(t, "[]", b.String())
+}
+
+func TestMessagePrinterPrintsBodyAccordingToBodyMode(t *testing.T) {
+	testcases := []struct {
+		mode     BodyMode
+		body     []byte
+		expected string
+	}{
+		{BodyModeAuto, []byte("hello"), "hello"},
+		{BodyModeAuto, []byte{0xca, 0xfe, 'A'}, "00000000: cafe 41                                  ..A"},
+		{BodyModeAuto, []byte("\x1b[2J\x1b]0;x\x07"), "00000000: 1b5b 324a 1b5d 303b 7807                 .[2J.]0;x."},
+		{BodyModeText, []byte{0x00, 'A'}, "\x00A"},
+		{BodyModeHex, []byte("hello"), "00000000: 6865 6c6c 6f                             hello"},
+		{BodyModeBase64, []byte("hello"), "aGVsbG8="},
+	}
+	for _, tc := range testcases {
+		printer, err := NewMessagePrinter(`{{ call .Body }}`, tc.mode)
+		require.NoError(t, err)
+
+		var b bytes.Buffer
+		err = printer.Print(&b, rabtap.NewTapMessage(&amqp.Delivery{Body: tc.body}, time.Now()))
+
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, b.String(), "mode %s", tc.mode)
+	}
+}
+
+func TestMessagePrinterDoesNotPrintColorizedBodyAsHexDump(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+	message := amqp.Delivery{ContentType: "text/xml", Body: []byte(`<a b="c">d</a>`)}
+	printer, err := NewMessagePrinter(`{{ call .Body }}`, BodyModeAuto)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	err = printer.Print(&b, rabtap.NewTapMessage(&message, time.Now()))
+
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), "\x1b[94ma\x1b[0m")
+}
+
+func TestMessagePrinterProvidesEncodingHelpers(t *testing.T) {
+	message := amqp.Delivery{Body: []byte("hi")}
+	printer, err := NewMessagePrinter(`{{ .Message.AmqpMessage.Body | hexdump }}|{{ base64 "hi" }}`, BodyModeText)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	err = printer.Print(&b, rabtap.NewTapMessage(&message, time.Now()))
+
+	require.NoError(t, err)
+	assert.Equal(t, "00000000: 6869                                     hi|aGk=", b.String())
+}
+
+func TestNewMessagePrinterFailsOnInvalidTemplate(t *testing.T) {
+	_, err := NewMessagePrinter("{{ unknown }}", BodyModeAuto)
+	assert.ErrorContains(t, err, "parse message template")
+}
+
+func TestReadMessageTemplate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "message.tpl")
+	require.NoError(t, os.WriteFile(filename, []byte("{{ .Message.Queue }}"), 0o644))
+
+	tpl, err := readMessageTemplate("@" + filename)
+	require.NoError(t, err)
+	assert.Equal(t, "{{ .Message.Queue }}", tpl)
+
+	// without '@', the argument is the template itself
+	tpl, err = readMessageTemplate(filename)
+	require.NoError(t, err)
+	assert.Equal(t, filename, tpl)
+
+	_, err = readMessageTemplate("@" + filename + ".missing")
+	assert.ErrorContains(t, err, "read template")
+
+	tpl, err = readMessageTemplate("oneline")
+	require.NoError(t, err)
+	assert.Equal(t, messageTemplateOneLine, tpl)
+
+	tpl, err = readMessageTemplate("{{ call .Body }}")
+	require.NoError(t, err)
+	assert.Equal(t, "{{ call .Body }}", tpl)
+}
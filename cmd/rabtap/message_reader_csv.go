@@ -0,0 +1,153 @@
+// Copyright (C) 2026 Jan Delgado
+// Read messages from CSV files using a column mapping.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvFieldMapping maps a CSV column to a field of the message. The column is
+// either given by its index (colN, starting with 1) or by its name in the
+// header row.
+type csvFieldMapping struct {
+	target string // body, routingkey, exchange, header.NAME or property.NAME
+	column string
+	index  int // index of the column, -1 if given by name
+}
+
+// CSVMapping is the column mapping of the csv format, e.g.
+// 'routingkey=col2,header.tenant=col3,body=col4'
+type CSVMapping []csvFieldMapping
+
+// hasColumnNames returns true if a column is referenced by its name, which
+// requires the first row to be the header.
+func (s CSVMapping) hasColumnNames() bool {
+	for _, m := range s {
+		if m.index < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCSVMapping parses a mapping like 'routingkey=col2,body=col4'.
+func parseCSVMapping(s string) (CSVMapping, error) {
+	var mapping CSVMapping
+	for _, expr := range strings.Split(s, ",") {
+		target, column, found := strings.Cut(expr, "=")
+		if !found || target == "" || column == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected TARGET=COLUMN", expr)
+		}
+		// targets are case insensitive, header and property names are kept
+		switch lower := strings.ToLower(target); {
+		case lower == "body", lower == "routingkey", lower == "exchange":
+			target = lower
+		case strings.HasPrefix(lower, "header.") && len(lower) > len("header."):
+			target = "header." + target[len("header."):]
+		case strings.HasPrefix(lower, "property.") && len(lower) > len("property."):
+			target = "property." + target[len("property."):]
+		default:
+			return nil, fmt.Errorf("invalid mapping target %q, expected one of body, routingkey, "+
+				"exchange, header.NAME or property.NAME", target)
+		}
+		index := -1
+		if n, ok := strings.CutPrefix(column, "col"); ok {
+			i, err := strconv.Atoi(n)
+			if err == nil {
+				if i < 1 {
+					return nil, fmt.Errorf("invalid column %q, first column is col1", column)
+				}
+				index = i - 1
+			}
+		}
+		mapping = append(mapping, csvFieldMapping{target: target, column: column, index: index})
+	}
+	return mapping, nil
+}
+
+// resolveColumnNames sets the index of columns given by name using the
+// header row.
+func (s CSVMapping) resolveColumnNames(header []string) (CSVMapping, error) {
+	resolved := make(CSVMapping, len(s))
+	for i, m := range s {
+		if m.index < 0 {
+			for j, name := range header {
+				if name == m.column {
+					m.index = j
+					break
+				}
+			}
+			if m.index < 0 {
+				return nil, fmt.Errorf("column %q not found in header", m.column)
+			}
+		}
+		resolved[i] = m
+	}
+	return resolved, nil
+}
+
+// toMessage creates a message from a CSV record
+func (s CSVMapping) toMessage(record []string) (RabtapPersistentMessage, error) {
+	var m RabtapPersistentMessage
+	props := map[string]string{}
+	for _, field := range s {
+		if field.index >= len(record) {
+			return m, fmt.Errorf("column %s not found", field.column)
+		}
+		value := record[field.index]
+		switch target := field.target; {
+		case target == "body":
+			m.Body = []byte(value)
+		case target == "routingkey":
+			m.RoutingKey = value
+		case target == "exchange":
+			m.Exchange = value
+		case strings.HasPrefix(target, "header."):
+			if m.Headers == nil {
+				m.Headers = map[string]interface{}{}
+			}
+			m.Headers[target[len("header."):]] = value
+		case strings.HasPrefix(target, "property."):
+			props[target[len("property."):]] = value
+		}
+	}
+	override, err := parseMessageProperties(props)
+	if err != nil {
+		return m, err
+	}
+	return *m.WithProperties(override), nil
+}
+
+// NewCSVMessageSource returns a MessageSource that reads a message from each
+// row of a CSV file, using the given mapping of columns to message fields.
+// When columns are referenced by name, the first row is the header.
+func NewCSVMessageSource(reader io.Reader, mapping CSVMapping) (MessageSource, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1 // rows may have different lengths
+	if mapping.hasColumnNames() {
+		header, err := csvReader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("read CSV header: %w", err)
+		}
+		if mapping, err = mapping.resolveColumnNames(header); err != nil {
+			return nil, err
+		}
+	}
+	return func() (RabtapPersistentMessage, error) {
+		record, err := csvReader.Read()
+		if err != nil {
+			return RabtapPersistentMessage{}, err
+		}
+		m, err := mapping.toMessage(record)
+		if err != nil {
+			line, _ := csvReader.FieldPos(0)
+			return m, fmt.Errorf("CSV line %d: %w", line, err)
+		}
+		return m, nil
+	}, nil
+}
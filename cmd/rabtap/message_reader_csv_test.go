@@ -0,0 +1,79 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSVMapping(t *testing.T) {
+	mapping, err := parseCSVMapping("RoutingKey=col2,header.Tenant=col3,body=payload,property.ContentType=col1")
+
+	require.NoError(t, err)
+	assert.Equal(t, CSVMapping{
+		{target: "routingkey", column: "col2", index: 1},
+		{target: "header.Tenant", column: "col3", index: 2},
+		{target: "body", column: "payload", index: -1},
+		{target: "property.ContentType", column: "col1", index: 0},
+	}, mapping)
+}
+
+func TestParseCSVMappingFailsOnInvalidMapping(t *testing.T) {
+	for _, s := range []string{"", "body", "body=", "=col1", "unknown=col1", "header.=col1", "body=col0"} {
+		_, err := parseCSVMapping(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestCSVMessageSourceMapsColumnsByIndex(t *testing.T) {
+	mapping, _ := parseCSVMapping("routingkey=col2,header.tenant=col3,body=col4,property.MessageID=col1")
+	data := "1,orders.eu,acme,\"{\"\"id\"\":1}\"\n2,orders.us,globex,hello\n"
+
+	source, err := NewCSVMessageSource(strings.NewReader(data), mapping)
+	require.NoError(t, err)
+
+	m, err := source()
+	require.NoError(t, err)
+	assert.Equal(t, "orders.eu", m.RoutingKey)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, m.Headers)
+	assert.Equal(t, `{"id":1}`, string(m.Body))
+	assert.Equal(t, "1", m.MessageID)
+
+	m, err = source()
+	require.NoError(t, err)
+	assert.Equal(t, "orders.us", m.RoutingKey)
+	assert.Equal(t, "hello", string(m.Body))
+
+	_, err = source()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCSVMessageSourceMapsColumnsByNameFromHeaderRow(t *testing.T) {
+	mapping, _ := parseCSVMapping("routingkey=key,body=payload")
+	data := "payload,key\nhello,k1\n"
+
+	source, err := NewCSVMessageSource(strings.NewReader(data), mapping)
+	require.NoError(t, err)
+
+	m, err := source()
+	require.NoError(t, err)
+	assert.Equal(t, "k1", m.RoutingKey)
+	assert.Equal(t, "hello", string(m.Body))
+}
+
+func TestCSVMessageSourceFailsOnUnknownColumn(t *testing.T) {
+	mapping, _ := parseCSVMapping("body=unknown")
+	_, err := NewCSVMessageSource(strings.NewReader("payload\nhello\n"), mapping)
+	assert.ErrorContains(t, err, `column "unknown" not found in header`)
+
+	mapping, _ = parseCSVMapping("body=col3")
+	source, err := NewCSVMessageSource(strings.NewReader("a,b\n"), mapping)
+	require.NoError(t, err)
+	_, err = source()
+	assert.ErrorContains(t, err, "CSV line 1: column col3 not found")
+}
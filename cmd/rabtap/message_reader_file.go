@@ -36,6 +36,13 @@ func NewReaderMessageSource(format string, reader io.ReadCloser) (MessageSource,
 			msg, err := readMessageFromJSONStream(decoder)
 			return msg, err
 		}, nil
+	case "ndjson-body":
+		// each line is the body of a message, like with --split=newline
+		splitter, err := newMessageSplitter("newline")
+		if err != nil {
+			return nil, err
+		}
+		return NewSplittingMessageSource(reader, splitter), nil
 	case "raw":
 		read := false // only read one file, then return EOF
 		return func() (RabtapPersistentMessage, error) {
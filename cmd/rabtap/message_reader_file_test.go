@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReadMessageFromJSON(t *testing.T) {
@@ -120,3 +121,19 @@ func TestCreateMessageReaderFuncReturnsRawFileReaderForRawFormats(t *testing.T)
 	msg, err = source()
 	assert.Equal(t, io.EOF, err)
 }
+
+func TestCreateMessageReaderFuncReturnsLineReaderForNDJSONBodyFormat(t *testing.T) {
+	data := "{\"id\":1}\n\n{\"id\":2}\r\n{\"id\":3}"
+	reader := io.NopCloser(bytes.NewReader([]byte(data)))
+
+	source, err := NewReaderMessageSource("ndjson-body", reader)
+	require.NoError(t, err)
+
+	for _, expected := range []string{`{"id":1}`, `{"id":2}`, `{"id":3}`} {
+		msg, err := source()
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(msg.Body))
+	}
+	_, err = source()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,215 @@
+// Copyright (C) 2026 Jan Delgado
+// Split a raw stream into multiple messages.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSplitMessageSize is the maximum size of a message read from a split
+// stream, which is the default maximum message size of RabbitMQ.
+const maxSplitMessageSize = 128 * 1024 * 1024
+
+// messageSplitter splits a raw stream into messages
+type messageSplitter struct {
+	split bufio.SplitFunc
+	// skipEmpty skips empty messages, e.g. empty lines
+	skipEmpty bool
+}
+
+// newMessageSplitter returns the splitter for the given --split argument,
+// which is one of 'newline', 'nul', 're:REGEX', 'length-prefixed' (4 byte
+// big-endian length) or 'length-prefixed:varint'. Only length-prefixed
+// streams can contain empty messages.
+func newMessageSplitter(spec string) (messageSplitter, error) {
+	if expr, ok := strings.CutPrefix(spec, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return messageSplitter{}, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		if re.MatchString("") {
+			return messageSplitter{}, fmt.Errorf("regular expression must not match the empty string: %s", expr)
+		}
+		return messageSplitter{split: splitByRegexp(re), skipEmpty: true}, nil
+	}
+	switch strings.ToLower(spec) {
+	case "newline":
+		return messageSplitter{split: bufio.ScanLines, skipEmpty: true}, nil
+	case "nul":
+		return messageSplitter{split: splitByByte(0), skipEmpty: true}, nil
+	case "length-prefixed":
+		return messageSplitter{split: splitLengthPrefixedUint32}, nil
+	case "length-prefixed:varint":
+		return messageSplitter{split: splitLengthPrefixedVarint}, nil
+	}
+	return messageSplitter{}, fmt.Errorf("invalid split mode %q, expected one of newline, nul, "+
+		"re:REGEX, length-prefixed or length-prefixed:varint", spec)
+}
+
+// splitByByte splits the data at each occurence of the separator
+func splitByByte(separator byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, separator); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// splitByRegexp splits the data at each match of the regular expression,
+// which must not match the empty string. A match at the end of the data is
+// only held back until more data or EOF arrives, when the regular expression
+// could still match more bytes, e.g. with "\n+".
+func splitByRegexp(re *regexp.Regexp) bufio.SplitFunc {
+	prog, err := compileRegexp(re)
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if loc := re.FindIndex(data); loc != nil {
+			if loc[1] < len(data) || atEOF || (err == nil && !canMatchMore(prog, data, loc[0])) {
+				return loc[1], data[:loc[0]], nil
+			}
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+func compileRegexp(re *regexp.Regexp) (*syntax.Prog, error) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return syntax.Compile(parsed.Simplify())
+}
+
+// canMatchMore returns true if a match of prog starting at or before start
+// is still in progress at the end of data, i.e. the leftmost match could
+// change when data is continued. The program is run as a simple NFA.
+// Empty-width assertions are assumed to hold, which may keep more threads
+// alive than necessary, but never less.
+func canMatchMore(prog *syntax.Prog, data []byte, start int) bool {
+	var threads, next []uint32
+	seen := make([]bool, len(prog.Inst))
+	var add func(threads []uint32, pc uint32) []uint32
+	add = func(threads []uint32, pc uint32) []uint32 {
+		if seen[pc] {
+			return threads
+		}
+		seen[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			return add(add(threads, inst.Out), inst.Arg)
+		case syntax.InstCapture, syntax.InstNop, syntax.InstEmptyWidth:
+			return add(threads, inst.Out)
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			return append(threads, pc)
+		}
+		return threads // InstMatch, InstFail
+	}
+	for pos := 0; ; {
+		if pos <= start {
+			threads = add(threads, uint32(prog.Start))
+		}
+		if pos == len(data) {
+			return len(threads) > 0
+		}
+		r, size := utf8.DecodeRune(data[pos:])
+		clear(seen)
+		next = next[:0]
+		for _, pc := range threads {
+			if inst := &prog.Inst[pc]; matchRune(inst, r) {
+				next = add(next, inst.Out)
+			}
+		}
+		threads, next = next, threads
+		pos += size
+	}
+}
+
+func matchRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return inst.MatchRune(r)
+}
+
+// splitLengthPrefixed returns messages prefixed by their length, which is
+// decoded by readLength. readLength returns the length and the size of the
+// prefix, or a size of 0 if more data is needed.
+func splitLengthPrefixed(readLength func([]byte) (uint64, int, error)) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		length, size, err := readLength(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if length > maxSplitMessageSize {
+			return 0, nil, fmt.Errorf("message length %d exceeds maximum of %d", length, maxSplitMessageSize)
+		}
+		if size > 0 && uint64(len(data)-size) >= length {
+			end := size + int(length)
+			return end, data[size:end], nil
+		}
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+}
+
+var splitLengthPrefixedUint32 = splitLengthPrefixed(func(data []byte) (uint64, int, error) {
+	if len(data) < 4 {
+		return 0, 0, nil
+	}
+	return uint64(binary.BigEndian.Uint32(data)), 4, nil
+})
+
+var splitLengthPrefixedVarint = splitLengthPrefixed(func(data []byte) (uint64, int, error) {
+	length, size := binary.Uvarint(data)
+	if size < 0 {
+		return 0, 0, errors.New("invalid varint length prefix")
+	}
+	return length, size, nil
+})
+
+// NewSplittingMessageSource returns a MessageSource that reads messages from
+// a raw stream, which is split into messages by the given splitter. The
+// stream is read as needed, so messages are published while it is written.
+func NewSplittingMessageSource(reader io.Reader, splitter messageSplitter) MessageSource {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSplitMessageSize+binary.MaxVarintLen64)
+	scanner.Split(splitter.split)
+	return func() (RabtapPersistentMessage, error) {
+		for scanner.Scan() {
+			if splitter.skipEmpty && len(scanner.Bytes()) == 0 {
+				continue
+			}
+			// the scanner reuses its buffer
+			return RabtapPersistentMessage{Body: bytes.Clone(scanner.Bytes())}, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return RabtapPersistentMessage{}, err
+		}
+		return RabtapPersistentMessage{}, io.EOF
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAllMessages reads the bodies of all messages of the source
+func readAllMessages(t *testing.T, source MessageSource) []string {
+	t.Helper()
+	var bodies []string
+	for {
+		m, err := source()
+		if err == io.EOF {
+			return bodies
+		}
+		require.NoError(t, err)
+		bodies = append(bodies, string(m.Body))
+	}
+}
+
+func TestSplittingMessageSourceSplitsByDelimiter(t *testing.T) {
+	testcases := []struct {
+		mode     string
+		input    string
+		expected []string
+	}{
+		{"newline", "line1\nline2\r\n\nline3", []string{"line1", "line2", "line3"}},
+		{"NUL", "a\x00b\x00\x00c\x00", []string{"a", "b", "c"}},
+		{"re:-{3,}\n", "a\n---\nb\n-----\nc", []string{"a\n", "b\n", "c"}},
+	}
+	for _, tc := range testcases {
+		splitter, err := newMessageSplitter(tc.mode)
+		require.NoError(t, err, tc.mode)
+
+		source := NewSplittingMessageSource(strings.NewReader(tc.input), splitter)
+
+		assert.Equal(t, tc.expected, readAllMessages(t, source), tc.mode)
+	}
+}
+
+func TestSplittingMessageSourceSplitsLengthPrefixedMessages(t *testing.T) {
+	var be32, varint bytes.Buffer
+	for _, body := range []string{"hello", "", strings.Repeat("x", 300)} {
+		be32.Write(binary.BigEndian.AppendUint32(nil, uint32(len(body))))
+		be32.WriteString(body)
+		varint.Write(binary.AppendUvarint(nil, uint64(len(body))))
+		varint.WriteString(body)
+	}
+	expected := []string{"hello", "", strings.Repeat("x", 300)}
+
+	splitter, err := newMessageSplitter("length-prefixed")
+	require.NoError(t, err)
+	assert.Equal(t, expected, readAllMessages(t, NewSplittingMessageSource(&be32, splitter)))
+
+	splitter, err = newMessageSplitter("length-prefixed:varint")
+	require.NoError(t, err)
+	assert.Equal(t, expected, readAllMessages(t, NewSplittingMessageSource(&varint, splitter)))
+}
+
+func TestSplittingMessageSourceFailsOnTruncatedLengthPrefixedMessage(t *testing.T) {
+	splitter, _ := newMessageSplitter("length-prefixed")
+	data := append(binary.BigEndian.AppendUint32(nil, 10), "short"...)
+
+	_, err := NewSplittingMessageSource(bytes.NewReader(data), splitter)()
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSplittingMessageSourceReadsStreamAsNeeded(t *testing.T) {
+	splitter, _ := newMessageSplitter("newline")
+	reader, writer := io.Pipe()
+	source := NewSplittingMessageSource(reader, splitter)
+
+	go func() { _, _ = writer.Write([]byte("first\n")) }()
+	m, err := source()
+
+	// the message is available while the stream is still open
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(m.Body))
+	writer.Close()
+	_, err = source()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSplittingMessageSourceReturnsMessageWhenRegexpMatchIsComplete(t *testing.T) {
+	testcases := []struct {
+		mode  string
+		input string
+	}{
+		{"re:-{3,}\n", "first---\n"},
+		{"re:\r?\n", "first\r\n"},
+		{"re:(?i)end", "firstEND"},
+	}
+	for _, tc := range testcases {
+		splitter, err := newMessageSplitter(tc.mode)
+		require.NoError(t, err, tc.mode)
+		reader, writer := io.Pipe()
+		source := NewSplittingMessageSource(reader, splitter)
+
+		go func() { _, _ = writer.Write([]byte(tc.input)) }()
+		m, err := source()
+
+		// the message is available before the stream is continued
+		require.NoError(t, err, tc.mode)
+		assert.Equal(t, "first", string(m.Body), tc.mode)
+		writer.Close()
+	}
+}
+
+func TestSplitByRegexpHoldsBackMatchWhichCouldContinue(t *testing.T) {
+	testcases := []struct {
+		expr, data string
+	}{
+		{"\n+", "a\n"},
+		{"-{3,}", "a---"},
+		{"ab+c|b", "xab"}, // the leftmost match could start earlier
+	}
+	for _, tc := range testcases {
+		split := splitByRegexp(regexp.MustCompile(tc.expr))
+
+		advance, token, err := split([]byte(tc.data), false)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, advance, tc.expr)
+		assert.Nil(t, token, tc.expr)
+
+		advance, _, _ = split([]byte(tc.data), true)
+		assert.NotEqual(t, 0, advance, tc.expr)
+	}
+}
+
+func TestNewMessageSplitterFailsOnInvalidMode(t *testing.T) {
+	for _, mode := range []string{"", "tab", "re:(", "re:x*", "length-prefixed:u16"} {
+		_, err := newMessageSplitter(mode)
+		assert.Error(t, err, mode)
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "fmt"
+
 // MessageTransformer transforms the given message
 type MessageTransformer func(m RabtapPersistentMessage) (RabtapPersistentMessage, error)
 
@@ -20,3 +22,19 @@ func NewTransformingMessageSource(f MessageSource, transformer ...MessageTransfo
 		return m, nil
 	}
 }
+
+// namedMessageTransformers are the transformers which can be selected by name,
+// e.g. with the --transform option of queue move. The x-death headers are
+// removed with the --strip-x-death option instead.
+var namedMessageTransformers = map[string]MessageTransformer{
+	"encode-body": BodyEncodingTransformer,
+	"firehose":    FireHoseTransformer,
+}
+
+// NewNamedMessageTransformer returns the transformer with the given name.
+func NewNamedMessageTransformer(name string) (MessageTransformer, error) {
+	if transformer, ok := namedMessageTransformers[name]; ok {
+		return transformer, nil
+	}
+	return nil, fmt.Errorf("unknown transformation %q, must be one of {encode-body, firehose}", name)
+}
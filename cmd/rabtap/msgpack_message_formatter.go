@@ -0,0 +1,47 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackContentTypes are the content types of msgpack encoded messages
+var msgpackContentTypes = []string{
+	"application/msgpack",
+	"application/x-msgpack",
+	"application/vnd.msgpack",
+}
+
+// MsgpackMessageFormatter pretty prints msgpack encoded messages as JSON.
+type MsgpackMessageFormatter struct{}
+
+var (
+	_ = func() struct{} {
+		for _, contentType := range msgpackContentTypes {
+			RegisterMessageFormatter(contentType, MsgpackMessageFormatter{})
+		}
+		return struct{}{}
+	}()
+)
+
+// Format tries to decode a msgpack encoded message and to format it as JSON.
+// If the message is not valid msgpack, it will be returned unformatted as-is.
+func (s MsgpackMessageFormatter) Format(body []byte) string {
+	dec := msgpack.NewDecoder(bytes.NewReader(body))
+	// maps can have keys of any type
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+	value, err := dec.DecodeInterface()
+	if err != nil {
+		return string(body)
+	}
+	formatted, err := formatAsJSON(value)
+	if err != nil {
+		return string(body)
+	}
+	return formatted
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackFormatterFormatsBodyAsJSON(t *testing.T) {
+	body, err := msgpack.Marshal(map[string]interface{}{"id": 1, "tags": []string{"a"}})
+	require.NoError(t, err)
+
+	formatted := MsgpackMessageFormatter{}.Format(body)
+
+	assert.JSONEq(t, `{"id":1,"tags":["a"]}`, formatted)
+}
+
+func TestMsgpackFormatterConvertsNonStringKeys(t *testing.T) {
+	body, err := msgpack.Marshal(map[int]string{1: "one"})
+	require.NoError(t, err)
+
+	formatted := MsgpackMessageFormatter{}.Format(body)
+
+	assert.JSONEq(t, `{"1":"one"}`, formatted)
+}
+
+func TestMsgpackFormatterReturnsInvalidBodyAsIs(t *testing.T) {
+	assert.Equal(t, "\xc1", MsgpackMessageFormatter{}.Format([]byte("\xc1")))
+}
+
+func TestNewMessageFormatterReturnsBinaryFormatters(t *testing.T) {
+	assert.Equal(t, MsgpackMessageFormatter{}, NewMessageFormatter("application/msgpack"))
+	assert.Equal(t, MsgpackMessageFormatter{}, NewMessageFormatter("application/x-msgpack"))
+	assert.Equal(t, CBORMessageFormatter{}, NewMessageFormatter("application/cbor"))
+}
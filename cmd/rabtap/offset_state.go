@@ -0,0 +1,221 @@
+// Copyright (C) 2026 Jan Delgado
+// Local state file to remember the last processed stream offsets of named
+// consumers, when streams are consumed with AMQP.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// offsetStateFile stores the last processed offset per stream of a named
+// consumer as JSON object in a file. Streams are identified by the key
+// returned by offsetStateKey.
+type offsetStateFile struct {
+	path string
+}
+
+// defaultOffsetStateDir returns the directory where offset state files are
+// kept, which is the 'rabtap/offsets' directory below the user's config dir.
+func defaultOffsetStateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rabtap", "offsets"), nil
+}
+
+func newOffsetStateFile(dir, consumerName string) offsetStateFile {
+	return offsetStateFile{path: filepath.Join(dir, url.PathEscape(consumerName)+".json")}
+}
+
+func (s offsetStateFile) read() (map[string]int64, error) {
+	offsets := map[string]int64{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, fmt.Errorf("invalid offset state file %s: %w", s.path, err)
+	}
+	return offsets, nil
+}
+
+// Load returns the offset stored under the given key. Returns false if no
+// offset was stored.
+func (s offsetStateFile) Load(key string) (int64, bool, error) {
+	offsets, err := s.read()
+	if err != nil {
+		return 0, false, err
+	}
+	offset, ok := offsets[key]
+	return offset, ok, nil
+}
+
+// Save stores the offset under the given key. The file is replaced
+// atomically, so it is not corrupted when rabtap is interrupted.
+func (s offsetStateFile) Save(key string, offset int64) error {
+	offsets, err := s.read()
+	if err != nil {
+		return err
+	}
+	offsets[key] = offset
+	data, err := json.MarshalIndent(offsets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// streamOffsetOf returns the value of the x-stream-offset header set by the
+// broker on messages consumed from a stream.
+func streamOffsetOf(message rabtap.TapMessage) (int64, bool) {
+	switch offset := message.AmqpMessage.Headers[rabtap.StreamOffsetHeader].(type) {
+	case int64:
+		return offset, true
+	case int32:
+		return int64(offset), true
+	case int:
+		return int64(offset), true
+	}
+	return 0, false
+}
+
+// offsetStateKey returns the key under which the offset of the given
+// subscription is stored. The key includes the broker and the vhost, so
+// that streams with the same name on different brokers or vhosts do not
+// share an offset, e.g. "localhost:5672/%2F/stream".
+func offsetStateKey(sub subscription) (string, error) {
+	vhost, err := rabtap.VhostFromURL(sub.amqpURL)
+	if err != nil {
+		return "", err
+	}
+	return sub.amqpURL.Host + "/" + url.PathEscape(vhost) + "/" + sub.queue, nil
+}
+
+// processedOffsets records the offset of the last processed message per
+// offset state key. Since messages of all subscriptions are received on the
+// same channel, the subscription of a message is identified by the AMQP
+// channel it was received on, which must be bound to the key of the
+// subscription before the message is acknowledged.
+type processedOffsets struct {
+	mu      sync.Mutex
+	keys    map[amqp.Acknowledger]string
+	offsets map[string]int64
+}
+
+func newProcessedOffsets() *processedOffsets {
+	return &processedOffsets{
+		keys:    map[amqp.Acknowledger]string{},
+		offsets: map[string]int64{},
+	}
+}
+
+// bind associates the channel the given message was received on with the
+// given offset state key.
+func (s *processedOffsets) bind(message rabtap.TapMessage, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[message.AmqpMessage.Acknowledger] = key
+}
+
+// record records the stream offset of the given, acknowledged message.
+func (s *processedOffsets) record(message rabtap.TapMessage) {
+	offset, ok := streamOffsetOf(message)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[message.AmqpMessage.Acknowledger]; ok {
+		s.offsets[key] = offset
+	}
+}
+
+// get returns a copy of the recorded offsets
+func (s *processedOffsets) get() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.offsets)
+}
+
+// newBindingTapChannel returns a channel on which the messages of the
+// subscription with the given offset state key are received. The messages
+// are bound to the key and forwarded to tapCh until ctx is cancelled.
+func (s *processedOffsets) newBindingTapChannel(ctx context.Context, key string, tapCh rabtap.TapChannel) rabtap.TapChannel {
+	ch := make(rabtap.TapChannel)
+	go func() {
+		for {
+			select {
+			case message := <-ch:
+				s.bind(message, key)
+				select {
+				case tapCh <- message:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// newOffsetTrackingAcknowledgeFunc returns an AcknowledgeFunc which records
+// the stream offset of each message after it was successfully acknowledged
+// by the provided AcknowledgeFunc. The provided AcknowledgeFunc must not
+// reject messages.
+func newOffsetTrackingAcknowledgeFunc(acknowledger AcknowledgeFunc, processed *processedOffsets) AcknowledgeFunc {
+	return func(message rabtap.TapMessage) error {
+		if err := acknowledger(message); err != nil {
+			return err
+		}
+		processed.record(message)
+		return nil
+	}
+}
+
+// saveProcessedOffsets saves the last processed offsets of all
+// subscriptions.
+func saveProcessedOffsets(state offsetStateFile, processed *processedOffsets, logger *slog.Logger) error {
+	var errs []error
+	for key, offset := range processed.get() {
+		logger.Debug("saving stream offset", "key", key, "offset", offset, "file", state.path)
+		if err := state.Save(key, offset); err != nil {
+			errs = append(errs, fmt.Errorf("save offset of %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2026 Jan Delgado
+// Decode protobuf messages using a FileDescriptorSet.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufContentTypes are the content types the protobuf formatter is
+// registered for
+var protobufContentTypes = []string{
+	"application/protobuf",
+	"application/x-protobuf",
+	"application/vnd.google.protobuf",
+	"application/x-google-protobuf",
+}
+
+// ProtobufConfig configures the decoding of protobuf messages
+type ProtobufConfig struct {
+	// DescriptorFile is a FileDescriptorSet, as created by protoc
+	// --include_imports --descriptor_set_out=FILE
+	DescriptorFile string
+	// TypeSource is the source of the message type, either "type" for the
+	// AMQP Type property or "header:NAME" for the header NAME
+	TypeSource string
+	// TypeMap maps values of the type source to full message names. Values
+	// not mapped are used as full message name.
+	TypeMap map[string]string
+}
+
+// ProtobufDecoder decodes protobuf messages using the message descriptors of
+// a FileDescriptorSet and formats them as JSON.
+type ProtobufDecoder struct {
+	types  *dynamicpb.Types
+	config ProtobufConfig
+}
+
+// protobufDecoder is the decoder registered with RegisterProtobufDecoder,
+// which is also used in filter expressions
+var protobufDecoder *ProtobufDecoder
+
+// RegisterProtobufDecoder registers the decoder as message formatter for the
+// protobuf content types and makes it available in filter expressions.
+func RegisterProtobufDecoder(decoder *ProtobufDecoder) {
+	protobufDecoder = decoder
+	for _, contentType := range protobufContentTypes {
+		RegisterMessageFormatter(contentType, decoder)
+	}
+}
+
+// LoadProtobufDecoder returns a ProtobufDecoder using the descriptor file of
+// the given config.
+func LoadProtobufDecoder(config ProtobufConfig) (*ProtobufDecoder, error) {
+	data, err := os.ReadFile(config.DescriptorFile)
+	if err != nil {
+		return nil, fmt.Errorf("read descriptor set: %w", err)
+	}
+	return NewProtobufDecoder(data, config)
+}
+
+// NewProtobufDecoder returns a ProtobufDecoder for the given serialized
+// FileDescriptorSet.
+func NewProtobufDecoder(descriptorSet []byte, config ProtobufConfig) (*ProtobufDecoder, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(descriptorSet, &set); err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+	s := &ProtobufDecoder{types: dynamicpb.NewTypes(files), config: config}
+	for value, name := range config.TypeMap {
+		if _, err := s.types.FindMessageByName(protoreflect.FullName(name)); err != nil {
+			return nil, fmt.Errorf("message type %s of %s not found in descriptor set", name, value)
+		}
+	}
+	return s, nil
+}
+
+// messageType returns the protobuf message type of the given message
+func (s *ProtobufDecoder) messageType(m *amqp.Delivery) (protoreflect.MessageType, error) {
+	name := m.Type
+	if header, ok := strings.CutPrefix(s.config.TypeSource, "header:"); ok {
+		value, found := m.Headers[header]
+		if !found {
+			return nil, fmt.Errorf("message type header %s not set", header)
+		}
+		name = fmt.Sprint(value)
+	}
+	if mapped, ok := s.config.TypeMap[name]; ok {
+		name = mapped
+	}
+	if name == "" {
+		return nil, errors.New("message type not set")
+	}
+	return s.types.FindMessageByName(protoreflect.FullName(name))
+}
+
+// Decode decodes the given body of the message
+func (s *ProtobufDecoder) Decode(m *amqp.Delivery, body []byte) (proto.Message, error) {
+	messageType, err := s.messageType(m)
+	if err != nil {
+		return nil, err
+	}
+	message := messageType.New().Interface()
+	if err := proto.Unmarshal(body, message); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", messageType.Descriptor().FullName(), err)
+	}
+	return message, nil
+}
+
+// Format returns the body as-is, since the message type is not known.
+func (s *ProtobufDecoder) Format(body []byte) string {
+	return string(body)
+}
+
+// FormatMessage pretty prints the given body of the message as JSON. If the
+// message can not be decoded, it will be returned unformatted as-is.
+func (s *ProtobufDecoder) FormatMessage(m *amqp.Delivery, body []byte) string {
+	message, err := s.Decode(m, body)
+	if err != nil {
+		return string(body)
+	}
+	data, err := protojson.MarshalOptions{Resolver: s.types}.Marshal(message)
+	if err != nil {
+		return string(body)
+	}
+	// protojson output is deliberately unstable, so it is indented with
+	// encoding/json
+	var formatted bytes.Buffer
+	if err := json.Indent(&formatted, data, "", "  "); err != nil {
+		return string(body)
+	}
+	return formatted.String()
+}
+
+// Unmarshal decodes the body of the message into a generic structure, as used
+// in filter expressions.
+func (s *ProtobufDecoder) Unmarshal(m *amqp.Delivery) (map[string]interface{}, error) {
+	body, err := Body(m)
+	if err != nil {
+		return nil, err
+	}
+	message, err := s.Decode(m, body)
+	if err != nil {
+		return nil, err
+	}
+	data, err := protojson.MarshalOptions{Resolver: s.types}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
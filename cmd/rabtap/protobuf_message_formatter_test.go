@@ -0,0 +1,163 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// testDescriptorSet returns a FileDescriptorSet with the message
+// shop.v1.Order { string id = 1; int32 amount = 2; }
+func testDescriptorSet(t *testing.T) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("shop/v1/order.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+			},
+		}},
+	}}}
+}
+
+// testOrder returns an encoded shop.v1.Order message
+func testOrder(t *testing.T, id string, amount int32) []byte {
+	t.Helper()
+	files, err := protodesc.NewFiles(testDescriptorSet(t))
+	require.NoError(t, err)
+	desc, err := files.FindDescriptorByName("shop.v1.Order")
+	require.NoError(t, err)
+
+	md := desc.(protoreflect.MessageDescriptor)
+	message := dynamicpb.NewMessage(md)
+	message.Set(md.Fields().ByName("id"), protoreflect.ValueOfString(id))
+	message.Set(md.Fields().ByName("amount"), protoreflect.ValueOfInt32(amount))
+	data, err := proto.Marshal(message)
+	require.NoError(t, err)
+	return data
+}
+
+func newTestProtobufDecoder(t *testing.T, config ProtobufConfig) *ProtobufDecoder {
+	t.Helper()
+	data, err := proto.Marshal(testDescriptorSet(t))
+	require.NoError(t, err)
+	decoder, err := NewProtobufDecoder(data, config)
+	require.NoError(t, err)
+	return decoder
+}
+
+func TestProtobufDecoderFormatsMessageWithTypeProperty(t *testing.T) {
+	decoder := newTestProtobufDecoder(t, ProtobufConfig{TypeSource: "type"})
+	message := &amqp.Delivery{Type: "shop.v1.Order", Body: testOrder(t, "o-1", 42)}
+
+	formatted := decoder.FormatMessage(message, message.Body)
+
+	assert.JSONEq(t, `{"id":"o-1","amount":42}`, formatted)
+	assert.Contains(t, formatted, "\n  ")
+}
+
+func TestProtobufDecoderMapsTypeFromHeader(t *testing.T) {
+	decoder := newTestProtobufDecoder(t, ProtobufConfig{
+		TypeSource: "header:event",
+		TypeMap:    map[string]string{"order.created": "shop.v1.Order"},
+	})
+	message := &amqp.Delivery{
+		Headers: amqp.Table{"event": "order.created"},
+		Body:    testOrder(t, "o-2", 7),
+	}
+
+	decoded, err := decoder.Unmarshal(message)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "o-2", "amount": float64(7)}, decoded)
+}
+
+func TestProtobufDecoderReturnsBodyAsIsWhenTypeIsUnknown(t *testing.T) {
+	decoder := newTestProtobufDecoder(t, ProtobufConfig{TypeSource: "type"})
+	body := testOrder(t, "o-1", 42)
+
+	assert.Equal(t, string(body), decoder.FormatMessage(&amqp.Delivery{Type: "shop.v1.Unknown"}, body))
+	assert.Equal(t, string(body), decoder.FormatMessage(&amqp.Delivery{}, body))
+	assert.Equal(t, string(body), decoder.Format(body))
+}
+
+func TestNewProtobufDecoderFailsOnUnknownMappedType(t *testing.T) {
+	data, err := proto.Marshal(testDescriptorSet(t))
+	require.NoError(t, err)
+
+	_, err = NewProtobufDecoder(data, ProtobufConfig{
+		TypeSource: "type",
+		TypeMap:    map[string]string{"order.created": "shop.v1.Unknown"},
+	})
+	assert.ErrorContains(t, err, "shop.v1.Unknown")
+
+	_, err = NewProtobufDecoder([]byte("invalid"), ProtobufConfig{})
+	assert.ErrorContains(t, err, "parse descriptor set")
+}
+
+func TestLoadProtobufDecoderReadsDescriptorFile(t *testing.T) {
+	data, err := proto.Marshal(testDescriptorSet(t))
+	require.NoError(t, err)
+	filename := filepath.Join(t.TempDir(), "shop.pb")
+	require.NoError(t, os.WriteFile(filename, data, 0o644))
+
+	decoder, err := LoadProtobufDecoder(ProtobufConfig{DescriptorFile: filename, TypeSource: "type"})
+
+	require.NoError(t, err)
+	assert.NotNil(t, decoder)
+}
+
+func TestRegisteredProtobufDecoderIsUsedByPrinterAndFilter(t *testing.T) {
+	RegisterProtobufDecoder(newTestProtobufDecoder(t, ProtobufConfig{TypeSource: "type"}))
+	t.Cleanup(func() {
+		protobufDecoder = nil
+		for _, contentType := range protobufContentTypes {
+			delete(messageFormatters, contentType)
+		}
+	})
+	message := rabtap.NewTapMessage(&amqp.Delivery{
+		ContentType: "application/x-protobuf",
+		Type:        "shop.v1.Order",
+		Body:        testOrder(t, "o-3", 5),
+	}, time.Now())
+
+	printer, err := NewMessagePrinter(`{{ bodyField "id" }} {{ call .Body | oneline }}`, BodyModeAuto)
+	require.NoError(t, err)
+	var b bytes.Buffer
+	require.NoError(t, printer.Print(&b, message))
+	assert.Equal(t, `o-3 { "id": "o-3", "amount": 5 }`, b.String())
+
+	pred, err := NewExprPredicate(`r.proto(r.msg).amount == 5`)
+	require.NoError(t, err)
+	res, err := pred.Eval(createMessagePredEnv(message, 1))
+	require.NoError(t, err)
+	assert.True(t, res)
+}
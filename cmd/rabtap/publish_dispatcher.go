@@ -0,0 +1,41 @@
+// Copyright (C) 2026 Jan Delgado
+// Distribute messages to concurrent publishers.
+
+package main
+
+import (
+	"context"
+	"hash/fnv"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// routingPartition returns the index of the publisher to use for the given
+// routing, which is the same for all messages with the same exchange and
+// routing key.
+func routingPartition(routing rabtap.Routing, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(routing.Exchange()))
+	h.Write([]byte{0})
+	h.Write([]byte(routing.Key()))
+	return int(h.Sum32() % uint32(n))
+}
+
+// dispatchByRoutingKey distributes the messages received on in to the out
+// channels. Since messages with the same exchange and routing key are always
+// sent to the same channel, their order is kept. The out channels are closed
+// when in is closed or ctx is cancelled.
+func dispatchByRoutingKey(ctx context.Context, in rabtap.PublishChannel, out []rabtap.PublishChannel) {
+	defer func() {
+		for _, ch := range out {
+			close(ch)
+		}
+	}()
+	for message := range in {
+		select {
+		case out[routingPartition(message.Routing, len(out))] <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
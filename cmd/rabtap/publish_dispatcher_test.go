@@ -0,0 +1,70 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+func TestDispatchByRoutingKeyKeepsOrderPerRoutingKey(t *testing.T) {
+	in := make(rabtap.PublishChannel)
+	out := make([]rabtap.PublishChannel, 3)
+	for i := range out {
+		out[i] = make(rabtap.PublishChannel)
+	}
+
+	go func() {
+		for i := range 100 {
+			in <- &rabtap.PublishMessage{
+				Routing:    rabtap.NewRouting("exchange", fmt.Sprintf("key%d", i%10), nil),
+				Publishing: &amqp.Publishing{},
+				Position:   int64(i),
+			}
+		}
+		close(in)
+	}()
+	go dispatchByRoutingKey(context.Background(), in, out)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	received := map[string][]int64{}
+	channelOfKey := map[string]int{}
+	for i, ch := range out {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for message := range ch {
+				mu.Lock()
+				key := message.Routing.Key()
+				received[key] = append(received[key], message.Position)
+				if c, ok := channelOfKey[key]; ok {
+					assert.Equal(t, c, i, "messages of %s were sent to different channels", key)
+				}
+				channelOfKey[key] = i
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, received, 10)
+	for key, positions := range received {
+		assert.IsIncreasing(t, positions, key)
+		assert.Len(t, positions, 10, key)
+	}
+}
+
+func TestRoutingPartitionIsStable(t *testing.T) {
+	routing := rabtap.NewRouting("exchange", "key", nil)
+
+	assert.Equal(t, routingPartition(routing, 8), routingPartition(routing, 8))
+	assert.Equal(t, 0, routingPartition(routing, 1))
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2026 Jan Delgado
+// Limit the publishing rate with a token bucket.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateBurstDuration is the time the bucket can hold tokens for, which allows
+// to catch up when waiting took longer than requested.
+const rateBurstDuration = 100 * time.Millisecond
+
+// tokenBucket limits the rate of events. The bucket is refilled with rate
+// tokens per second and holds up to burst tokens. Each event takes a token,
+// or waits until the next token is available.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := max(rate*rateBurstDuration.Seconds(), 1)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: 1,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait takes a token from the bucket, waiting until one is available.
+func (s *tokenBucket) Wait(ctx context.Context) error {
+	now := s.now()
+	s.tokens = min(s.tokens+now.Sub(s.last).Seconds()*s.rate, s.burst)
+	s.last = now
+	// a missing token is borrowed from the future, and is paid back by
+	// waiting until it was refilled.
+	s.tokens--
+	if s.tokens >= 0 {
+		return nil
+	}
+	return s.sleep(ctx, time.Duration(-s.tokens/s.rate*float64(time.Second)))
+}
+
+// parseRate parses a rate like '100/s', '500/m' or '10/h' and returns the
+// rate per second. Without a unit, the rate is per second. The rate must be
+// a finite number greater than 0.
+func parseRate(s string) (float64, error) {
+	count, unit, _ := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %s", s)
+	}
+	var rate float64
+	switch unit {
+	case "", "s":
+		rate = n
+	case "m":
+		rate = n / 60
+	case "h":
+		rate = n / 3600
+	default:
+		return 0, fmt.Errorf("invalid unit of rate, use 's', 'm' or 'h': %s", s)
+	}
+	// also rejects NaN and rates too small to be represented per second
+	if !(rate > 0) || math.IsInf(rate, 0) {
+		return 0, fmt.Errorf("rate must be a positive, finite number: %s", s)
+	}
+	return rate, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClockTokenBucket returns a tokenBucket which records the waits instead
+// of sleeping and advances the clock by the waited time.
+func fakeClockTokenBucket(rate float64, waits *[]time.Duration) (*tokenBucket, *time.Time) {
+	now := time.Unix(0, 0)
+	bucket := newTokenBucket(rate)
+	bucket.last = now
+	bucket.now = func() time.Time { return now }
+	bucket.sleep = func(_ context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		now = now.Add(d)
+		return nil
+	}
+	return bucket, &now
+}
+
+func TestTokenBucketWaitsForNextToken(t *testing.T) {
+	var waits []time.Duration
+	bucket, _ := fakeClockTokenBucket(10, &waits)
+
+	for range 3 {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond}, waits)
+}
+
+func TestTokenBucketAllowsBurstAfterIdleTime(t *testing.T) {
+	var waits []time.Duration
+	bucket, now := fakeClockTokenBucket(100, &waits)
+
+	*now = now.Add(time.Second)
+	// the bucket holds tokens for 100ms, i.e. 10 tokens
+	for range 10 {
+		require.NoError(t, bucket.Wait(context.Background()))
+	}
+	assert.Empty(t, waits)
+
+	require.NoError(t, bucket.Wait(context.Background()))
+	assert.Equal(t, []time.Duration{10 * time.Millisecond}, waits)
+}
+
+func TestTokenBucketWaitReturnsErrorWhenCancelled(t *testing.T) {
+	bucket := newTokenBucket(0.001)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, bucket.Wait(ctx))
+	assert.ErrorIs(t, bucket.Wait(ctx), context.Canceled)
+}
+
+func TestParseRate(t *testing.T) {
+	testcases := map[string]float64{
+		"100":    100,
+		"100/s":  100,
+		"0.5/s":  0.5,
+		"600/m":  10,
+		"7200/h": 2,
+	}
+	for s, expected := range testcases {
+		rate, err := parseRate(s)
+		require.NoError(t, err, s)
+		assert.InDelta(t, expected, rate, 1e-9, s)
+	}
+}
+
+func TestParseRateFailsOnInvalidRate(t *testing.T) {
+	for _, s := range []string{"", "x/s", "0/s", "-1/s", "-0/s", "10/d",
+		"NaN", "nan/m", "Inf", "+Inf/s", "-Inf/h", "infinity", "1e400", "5e-324/h"} {
+		_, err := parseRate(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -6,6 +6,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -29,6 +30,9 @@ type AcknowledgeFunc func(rabtap.TapMessage) error
 type MessageSinkOptions struct {
 	out              io.Writer
 	format           string // currently: raw, json, json-nopp
+	template         string // template to print messages in raw format, default if empty
+	bodyMode         BodyMode
+	keepCharset      bool // save bodies with their original charset
 	silent           bool
 	optSaveDir       *string
 	filenameProvider FilenameProvider
@@ -42,6 +46,7 @@ type MessageSink func(rabtap.TapMessage) error
 func createMessagePredEnv(msg rabtap.TapMessage, count int64) map[string]interface{} {
 	return map[string]interface{}{
 		"msg":   msg.AmqpMessage,
+		"queue": msg.Queue,
 		"count": count,
 		"toStr": func(b []byte) string { return string(b) },
 		"gunzip": func(b []byte) ([]byte, error) {
@@ -50,6 +55,18 @@ func createMessagePredEnv(msg rabtap.TapMessage, count int64) map[string]interfa
 		"body": func(m *amqp.Delivery) ([]byte, error) {
 			return Body(m)
 		},
+		"proto": func(m *amqp.Delivery) (map[string]interface{}, error) {
+			if protobufDecoder == nil {
+				return nil, errors.New("no protobuf descriptor set given (--proto-descriptor)")
+			}
+			return protobufDecoder.Unmarshal(m)
+		},
+		"avro": func(m *amqp.Delivery) (map[string]interface{}, error) {
+			if avroDecoder == nil {
+				return nil, errors.New("no Avro schemas given (--avro-schemas)")
+			}
+			return avroDecoder.Unmarshal(m)
+		},
 	}
 }
 
@@ -92,12 +109,28 @@ func CreateAcknowledgeFunc(reject, requeue bool) AcknowledgeFunc {
 	}
 }
 
+// messagePipelineDepth is the number of received messages which may wait
+// for the message sink.
+const messagePipelineDepth = 128
+
+// pipelinedMessage is a received message passed to the sink stage of the
+// MessageReceiveLoop. Filtered messages are only acknowledged.
+type pipelinedMessage struct {
+	message rabtap.TapMessage
+	sink    bool
+}
+
 // MessageReceiveLoop passes received AMQP messages to the messageSink and
 // handles errors received on the errorChan. AMQP messages are ascknowledged by
 // the provides acknowleder function. Each message is passed to the predicate
 // termPred function. If true is returned, processing is ended. Timeout
 // specifies an idle timeout, which will end processing when for the given
 // duration no new messages are received on messageChan.
+//
+// Messages are processed in a pipeline: while the messageSink processes a
+// message, the next messages are already received and filtered. Messages are
+// passed to the messageSink and then acknowledged in the order they were
+// received. Before returning, all received messages are processed.
 // TODO pass in struct, limit number of arguments
 func MessageReceiveLoop(ctx context.Context,
 	messageChan rabtap.TapChannel,
@@ -108,6 +141,41 @@ func MessageReceiveLoop(ctx context.Context,
 	acknowledger AcknowledgeFunc,
 	timeout time.Duration,
 	logger *slog.Logger,
+) error {
+	pipeline := make(chan pipelinedMessage, messagePipelineDepth)
+	sinkDone := make(chan struct{})
+	go func() {
+		defer close(sinkDone)
+		for item := range pipeline {
+			if item.sink {
+				if err := messageSink(item.message); err != nil {
+					logger.Error("message sink error", "error", err)
+				}
+			}
+			// acknowledge or reject the message
+			if err := acknowledger(item.message); err != nil {
+				logger.Error("acknowledge failed", "error", err)
+			}
+		}
+	}()
+
+	err := receiveMessages(ctx, messageChan, errorChan, pipeline, filterPred, termPred, timeout, logger)
+	close(pipeline)
+	<-sinkDone
+	return err
+}
+
+// receiveMessages receives and filters messages and passes them to the
+// pipeline until termPred is true, the idle timeout occurs or the context
+// is cancelled.
+func receiveMessages(ctx context.Context,
+	messageChan rabtap.TapChannel,
+	errorChan rabtap.SubscribeErrorChannel,
+	pipeline chan<- pipelinedMessage,
+	filterPred Predicate,
+	termPred Predicate,
+	timeout time.Duration,
+	logger *slog.Logger,
 ) error {
 	timeoutTicker := time.NewTicker(timeout)
 	defer timeoutTicker.Stop()
@@ -133,11 +201,6 @@ func MessageReceiveLoop(ctx context.Context,
 			}
 			logger.Debug("new message", "message", message)
 
-			// acknowledge or reject the message
-			if err := acknowledger(message); err != nil {
-				logger.Error("acknowledge failed", "error", err)
-			}
-
 			env := createMessagePredEnv(message, count)
 			passed, err := filterPred.Eval(env)
 			if err != nil {
@@ -146,12 +209,14 @@ func MessageReceiveLoop(ctx context.Context,
 
 			if !passed {
 				logger.Debug("message was filtered out", "message_id", message.AmqpMessage.MessageId)
+				if !sendToPipeline(ctx, pipeline, pipelinedMessage{message: message}) {
+					return ctx.Err()
+				}
 				continue
 			}
 			count += 1
-
-			if err := messageSink(message); err != nil {
-				logger.Error("message sink error", "error", err)
+			if !sendToPipeline(ctx, pipeline, pipelinedMessage{message: message, sink: true}) {
+				return ctx.Err()
 			}
 
 			env = createMessagePredEnv(message, count)
@@ -169,6 +234,17 @@ func MessageReceiveLoop(ctx context.Context,
 	}
 }
 
+// sendToPipeline sends item to the pipeline. Returns false if the context was
+// cancelled while the pipeline is full.
+func sendToPipeline(ctx context.Context, pipeline chan<- pipelinedMessage, item pipelinedMessage) bool {
+	select {
+	case pipeline <- item:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func nopMessageSink(rabtap.TapMessage) error {
 	return nil
 }
@@ -205,19 +281,27 @@ func newWriteToJSONFileMessageSink(dir string, marshaller marshalFunc, filenameP
 // the provided writer
 func newPrintJSONMessageSink(out io.Writer, marshaller marshalFunc) MessageSink {
 	return func(message rabtap.TapMessage) error {
-		return WriteMessage(out, message, marshaller)
+		return WriteMessage(out, withUTF8Body(message), marshaller)
 	}
 }
 
-// newPrettyPrintJSONMessageSink returns a function that pretty prints received
-// messaged to the provided writer
-func newPrettyPrintJSONMessageSink(out io.Writer) MessageSink {
+// newUTF8MessageSink returns a sink which converts the body of messages to
+// UTF-8 before passing them to the given sink
+func newUTF8MessageSink(sink MessageSink) MessageSink {
 	return func(message rabtap.TapMessage) error {
-		return PrettyPrintMessage(out, message)
+		return sink(withUTF8Body(message))
 	}
 }
 
-func newPrintMessageMessageSink(format string, out io.Writer, silent bool) (MessageSink, error) {
+// newPrettyPrintMessageSink returns a function that pretty prints received
+// messaged to the provided writer using the given printer
+func newPrettyPrintMessageSink(out io.Writer, printer *MessagePrinter) MessageSink {
+	return func(message rabtap.TapMessage) error {
+		return printer.Print(out, message)
+	}
+}
+
+func newPrintMessageMessageSink(format string, tpl string, bodyMode BodyMode, out io.Writer, silent bool) (MessageSink, error) {
 	if silent {
 		return nopMessageSink, nil
 	}
@@ -228,7 +312,14 @@ func newPrintMessageMessageSink(format string, out io.Writer, silent bool) (Mess
 	case "json":
 		return newPrintJSONMessageSink(out, JSONMarshalIndent), nil
 	case "raw":
-		return newPrettyPrintJSONMessageSink(out), nil
+		if tpl == "" {
+			tpl = messageTemplate
+		}
+		printer, err := NewMessagePrinter(tpl, bodyMode)
+		if err != nil {
+			return nil, err
+		}
+		return newPrettyPrintMessageSink(out, printer), nil
 	default:
 		return nil, fmt.Errorf("invalid format %s", format)
 	}
@@ -256,10 +347,13 @@ func newSaveFileMessageSink(format string, optSaveDir *string, filenameProvider
 // that optionally prints to the proviced io.Writer and optionally to the
 // provided directory is returned.
 func NewMessageSink(opts MessageSinkOptions) (MessageSink, error) {
-	printFunc, err := newPrintMessageMessageSink(opts.format, opts.out, opts.silent)
+	printFunc, err := newPrintMessageMessageSink(opts.format, opts.template, opts.bodyMode, opts.out, opts.silent)
 	if err != nil {
 		return printFunc, err
 	}
 	saveFunc, err := newSaveFileMessageSink(opts.format, opts.optSaveDir, opts.filenameProvider)
+	if err == nil && opts.optSaveDir != nil && !opts.keepCharset {
+		saveFunc = newUTF8MessageSink(saveFunc)
+	}
 	return messageSinkTee(printFunc, saveFunc), err
 }
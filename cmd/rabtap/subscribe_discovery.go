@@ -0,0 +1,48 @@
+// Copyright (C) 2026 Jan Delgado
+// Discovery of the queues to subscribe to.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// resolveSubscriptions returns the queues to subscribe to for the given
+// configurations. Queues selected by patterns are discovered using the
+// management API, which is only supported with a single broker.
+func resolveSubscriptions(ctx context.Context, configs []rabtap.SubscribeConfiguration, apiClient *rabtap.RabbitHTTPClient) ([]subscription, error) {
+	var subscriptions []subscription
+	for _, config := range configs {
+		queues := selectorNames(config.Queues)
+		if config.NeedsDiscovery() {
+			vhost, err := rabtap.VhostFromURL(config.AMQPURL)
+			if err != nil {
+				return nil, err
+			}
+			discovered, err := rabtap.DiscoverQueues(ctx, apiClient, vhost, config.Queues)
+			if err != nil {
+				return nil, err
+			}
+			if len(discovered) == 0 {
+				return nil, fmt.Errorf("no queues found matching %s", strings.Join(queues, ","))
+			}
+			queues = discovered
+		}
+		for _, queue := range queues {
+			subscriptions = append(subscriptions, subscription{amqpURL: config.AMQPURL, queue: queue})
+		}
+	}
+	return subscriptions, nil
+}
+
+func selectorNames(selectors []rabtap.QueueSelector) []string {
+	names := make([]string, 0, len(selectors))
+	for _, selector := range selectors {
+		names = append(names, selector.Queue)
+	}
+	return names
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+	"github.com/jandelgado/rabtap/pkg/testcommon"
+)
+
+func TestResolveSubscriptionsReturnsQueuesOfAllBrokers(t *testing.T) {
+	url1, _ := url.Parse("amqp://broker1")
+	url2, _ := url.Parse("amqp://broker2")
+	config1, _ := rabtap.NewSubscribeConfiguration(url1, "q1,q2")
+	config2, _ := rabtap.NewSubscribeConfiguration(url2, "q3")
+
+	subscriptions, err := resolveSubscriptions(context.TODO(),
+		[]rabtap.SubscribeConfiguration{*config1, *config2}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{
+		{amqpURL: url1, queue: "q1"},
+		{amqpURL: url1, queue: "q2"},
+		{amqpURL: url2, queue: "q3"},
+	}, subscriptions)
+}
+
+func TestResolveSubscriptionsDiscoversQueuesMatchingPatterns(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	apiURL, _ := url.Parse(mock.URL)
+	client := rabtap.NewRabbitHTTPClient(apiURL, &tls.Config{})
+	amqpURL, _ := url.Parse("amqp://localhost/")
+	config, _ := rabtap.NewSubscribeConfiguration(amqpURL, "direct-*,topic-q1")
+
+	subscriptions, err := resolveSubscriptions(context.TODO(), []rabtap.SubscribeConfiguration{*config}, client)
+
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{
+		{amqpURL: amqpURL, queue: "direct-q1"},
+		{amqpURL: amqpURL, queue: "direct-q2"},
+		{amqpURL: amqpURL, queue: "topic-q1"},
+	}, subscriptions)
+}
+
+func TestResolveSubscriptionsFailsWhenNoQueueMatches(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	apiURL, _ := url.Parse(mock.URL)
+	client := rabtap.NewRabbitHTTPClient(apiURL, &tls.Config{})
+	amqpURL, _ := url.Parse("amqp://localhost/")
+	config, _ := rabtap.NewSubscribeConfiguration(amqpURL, "unknown-*")
+
+	_, err := resolveSubscriptions(context.TODO(), []rabtap.SubscribeConfiguration{*config}, client)
+
+	assert.ErrorContains(t, err, "no queues found matching unknown-*")
+}
@@ -61,6 +61,7 @@ func TestCreateMessagePredicateProvidesMessageContext(t *testing.T) {
 	env := createMessagePredEnv(msg, 123)
 
 	assert.Contains(t, env, "msg")
+	assert.Equal(t, "", env["queue"])
 	assert.Equal(t, int64(123), env["count"])
 	assert.Contains(t, env, "gunzip")
 	assert.Contains(t, env, "toStr")
@@ -199,6 +200,23 @@ func TestCreateMessageSinkRawToFile(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestCreateMessageSinkPrintsWithTemplate(t *testing.T) {
+	var b bytes.Buffer
+	opts := MessageSinkOptions{
+		out:      &b,
+		format:   "raw",
+		template: "body={{ call .Body }}",
+	}
+	rcvFunc, err := NewMessageSink(opts)
+	require.NoError(t, err)
+	message := rabtap.NewTapMessage(&amqp.Delivery{Body: []byte("Testmessage")}, time.Now())
+
+	err = rcvFunc(message)
+
+	require.NoError(t, err)
+	assert.Equal(t, "body=Testmessage", b.String())
+}
+
 func TestCreateMessageSinkPrintsNothingWhenSilentOptionIsSet(t *testing.T) {
 	var b bytes.Buffer
 	opts := MessageSinkOptions{
@@ -268,6 +286,58 @@ func TestCreateMessageSinkJSONNoPPToFile(t *testing.T) {
 	assert.True(t, strings.Contains(string(contents), "\"Body\": \"VGVzdG1lc3NhZ2U=\""))
 }
 
+func TestCreateMessageSinkSavesBodiesInUTF8UnlessCharsetIsKept(t *testing.T) {
+	for _, keepCharset := range []bool{false, true} {
+		testDir := t.TempDir()
+		var b bytes.Buffer
+		opts := MessageSinkOptions{
+			out:              &b,
+			format:           "raw",
+			optSaveDir:       &testDir,
+			keepCharset:      keepCharset,
+			filenameProvider: func() string { return "tapfilename" },
+		}
+		rcvFunc, err := NewMessageSink(opts)
+		require.NoError(t, err)
+		message := rabtap.NewTapMessage(&amqp.Delivery{
+			ContentType: "text/plain; charset=iso-8859-1",
+			Body:        []byte("M\xfcnchen"),
+		}, time.Now())
+
+		err = rcvFunc(message)
+
+		require.NoError(t, err)
+		assert.Contains(t, b.String(), "München")
+		contents, err := os.ReadFile(path.Join(testDir, "tapfilename.dat"))
+		require.NoError(t, err)
+		metadata, err := os.ReadFile(path.Join(testDir, "tapfilename.json"))
+		require.NoError(t, err)
+		if keepCharset {
+			assert.Equal(t, "M\xfcnchen", string(contents))
+			assert.Contains(t, string(metadata), `"ContentType": "text/plain; charset=iso-8859-1"`)
+		} else {
+			assert.Equal(t, "München", string(contents))
+			assert.Contains(t, string(metadata), `"ContentType": "text/plain; charset=utf-8"`)
+		}
+	}
+}
+
+func TestCreateMessageSinkJSONConvertsBodyToUTF8(t *testing.T) {
+	var b bytes.Buffer
+	rcvFunc, err := NewMessageSink(MessageSinkOptions{out: &b, format: "json-nopp"})
+	require.NoError(t, err)
+	message := rabtap.NewTapMessage(&amqp.Delivery{
+		ContentType: "text/plain; charset=windows-1252",
+		Body:        []byte("\x80"),
+	}, time.Now())
+
+	err = rcvFunc(message)
+
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), `"ContentType":"text/plain; charset=utf-8"`)
+	assert.Contains(t, b.String(), `"Body":"4oKs"`) // base64 of "€"
+}
+
 func TestMessageReceiveLoopForwardsMessagesOnChannel(t *testing.T) {
 	logger := slog.New(slog.DiscardHandler)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -374,3 +444,78 @@ func TestMessageReceiveLoopExitsWithErrorWhenIdle(t *testing.T) {
 	// Then
 	assert.Equal(t, ErrIdleTimeout, err)
 }
+
+func TestMessageReceiveLoopAcknowledgesMessagesInOrderAfterSink(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	messageChan := make(rabtap.TapChannel, 5)
+	errorChan := make(rabtap.SubscribeErrorChannel)
+	var events []string
+
+	sink := func(m rabtap.TapMessage) error {
+		time.Sleep(time.Millisecond) // a slow sink
+		events = append(events, "sink "+m.AmqpMessage.MessageId)
+		return nil
+	}
+	acknowledger := func(m rabtap.TapMessage) error {
+		events = append(events, "ack "+m.AmqpMessage.MessageId)
+		return nil
+	}
+	filterPred := funcPred{f: func(env map[string]interface{}) (bool, error) {
+		return env["msg"].(*amqp.Delivery).MessageId != "2", nil
+	}}
+
+	for _, id := range []string{"1", "2", "3"} {
+		messageChan <- rabtap.TapMessage{AmqpMessage: &amqp.Delivery{MessageId: id}}
+	}
+	close(messageChan)
+
+	err := MessageReceiveLoop(context.Background(), messageChan, errorChan, sink,
+		filterPred, constantPred{val: false}, acknowledger, time.Second*10, logger)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sink 1", "ack 1", "ack 2", "sink 3", "ack 3"}, events)
+}
+
+func TestMessageReceiveLoopProcessesReceivedMessagesWhenTerminating(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	messageChan := make(rabtap.TapChannel, 3)
+	errorChan := make(rabtap.SubscribeErrorChannel)
+	received := 0
+	acked := 0
+
+	sink := func(rabtap.TapMessage) error {
+		time.Sleep(5 * time.Millisecond)
+		received++
+		return nil
+	}
+	acknowledger := func(rabtap.TapMessage) error {
+		acked++
+		return nil
+	}
+	termPred, _ := NewLoopCountPred(2)
+	for i := 0; i < 3; i++ {
+		messageChan <- rabtap.TapMessage{AmqpMessage: &amqp.Delivery{}}
+	}
+
+	err := MessageReceiveLoop(context.Background(), messageChan, errorChan, sink,
+		constantPred{val: true}, termPred, acknowledger, time.Second*10, logger)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, received)
+	assert.Equal(t, 2, acked)
+}
+
+func TestMessageFilterCanSelectMessagesByQueue(t *testing.T) {
+	filterPred, err := NewExprPredicate(`r.queue == "orders.dlq"`)
+	require.NoError(t, err)
+
+	msg := rabtap.TapMessage{AmqpMessage: &amqp.Delivery{}, Queue: "orders.dlq"}
+	passed, err := filterPred.Eval(createMessagePredEnv(msg, 0))
+	require.NoError(t, err)
+	assert.True(t, passed)
+
+	msg.Queue = "billing.dlq"
+	passed, err = filterPred.Eval(createMessagePredEnv(msg, 0))
+	require.NoError(t, err)
+	assert.False(t, passed)
+}
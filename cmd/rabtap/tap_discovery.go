@@ -0,0 +1,76 @@
+// tap with discovery of bindings
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+// defaultDiscoveryInterval is the interval in which the bindings of tapped
+// exchanges are re-discovered, when no binding keys were given.
+const defaultDiscoveryInterval = 30 * time.Second
+
+// discoverFunc returns the exchanges to tap to
+type discoverFunc func(ctx context.Context) ([]rabtap.ExchangeConfiguration, error)
+
+// establishFunc establishes a tap to the given exchanges and runs until ctx
+// is cancelled or an error occurs
+type establishFunc func(ctx context.Context, exchanges []rabtap.ExchangeConfiguration) error
+
+// establishTapWithDiscovery discovers the exchanges to tap to and
+// establishes the tap. The discovery is repeated every interval and the tap
+// is re-established, when the set of discovered exchanges and bindings
+// changed.
+func establishTapWithDiscovery(ctx context.Context,
+	discover discoverFunc,
+	establish establishFunc,
+	interval time.Duration,
+	logger *slog.Logger,
+) error {
+	exchanges, err := discover(ctx)
+	if err != nil {
+		return fmt.Errorf("discovery failed: %w", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		logger.Debug("establishing tap", "exchanges", exchanges)
+		tapCtx, cancelTap := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func(exchanges []rabtap.ExchangeConfiguration) {
+			done <- establish(tapCtx, exchanges)
+		}(exchanges)
+
+	resync:
+		for {
+			select {
+			case err := <-done:
+				cancelTap()
+				return err
+			case <-ticker.C:
+				discovered, err := discover(ctx)
+				if err != nil {
+					logger.Warn("re-discovery of bindings failed", "error", err)
+					continue
+				}
+				if !reflect.DeepEqual(exchanges, discovered) {
+					logger.Info("bindings changed, re-establishing tap", "exchanges", discovered)
+					exchanges = discovered
+					break resync
+				}
+			}
+		}
+		cancelTap()
+		if err := <-done; err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rabtap "github.com/jandelgado/rabtap/pkg"
+)
+
+func TestEstablishTapWithDiscoveryFailsWhenInitialDiscoveryFails(t *testing.T) {
+	discover := func(ctx context.Context) ([]rabtap.ExchangeConfiguration, error) {
+		return nil, errors.New("discovery error")
+	}
+	establish := func(ctx context.Context, exchanges []rabtap.ExchangeConfiguration) error {
+		require.Fail(t, "establish must not be called")
+		return nil
+	}
+
+	err := establishTapWithDiscovery(context.TODO(), discover, establish, time.Hour, slog.New(slog.DiscardHandler))
+
+	assert.ErrorContains(t, err, "discovery error")
+}
+
+func TestEstablishTapWithDiscoveryReestablishesTapWhenBindingsChange(t *testing.T) {
+	var numDiscoveries atomic.Int32
+	discover := func(ctx context.Context) ([]rabtap.ExchangeConfiguration, error) {
+		// first two discoveries return the same result, then a new binding appears
+		if numDiscoveries.Add(1) <= 2 {
+			return []rabtap.ExchangeConfiguration{{Exchange: "ex", BindingKey: "k1"}}, nil
+		}
+		return []rabtap.ExchangeConfiguration{
+			{Exchange: "ex", BindingKey: "k1"},
+			{Exchange: "ex", BindingKey: "k2"},
+		}, nil
+	}
+
+	established := make(chan []rabtap.ExchangeConfiguration, 2)
+	establish := func(ctx context.Context, exchanges []rabtap.ExchangeConfiguration) error {
+		established <- exchanges
+		<-ctx.Done()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- establishTapWithDiscovery(ctx, discover, establish, time.Millisecond*10, slog.New(slog.DiscardHandler))
+	}()
+
+	assert.Equal(t, []rabtap.ExchangeConfiguration{{Exchange: "ex", BindingKey: "k1"}}, <-established)
+	assert.Equal(t, 2, len(<-established))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "establishTapWithDiscovery did not terminate")
+	}
+}
+
+func TestEstablishTapWithDiscoveryReturnsErrorOfTap(t *testing.T) {
+	discover := func(ctx context.Context) ([]rabtap.ExchangeConfiguration, error) {
+		return []rabtap.ExchangeConfiguration{{Exchange: "ex", BindingKey: "#"}}, nil
+	}
+	establish := func(ctx context.Context, exchanges []rabtap.ExchangeConfiguration) error {
+		return errors.New("tap error")
+	}
+
+	err := establishTapWithDiscovery(context.TODO(), discover, establish, time.Hour, slog.New(slog.DiscardHandler))
+
+	assert.ErrorContains(t, err, "tap error")
+}
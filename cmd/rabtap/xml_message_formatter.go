@@ -0,0 +1,183 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	amqp "github.com/rabbitmq/amqp091-go"
+	htmlcharset "golang.org/x/net/html/charset"
+)
+
+// xmlContentTypes are the content types of XML messages
+var xmlContentTypes = []string{
+	"application/xml",
+	"text/xml",
+	"application/soap+xml",
+}
+
+var (
+	xmlTagColor   = color.New(color.FgHiBlue).SprintFunc()
+	xmlAttrColor  = color.New(color.FgHiCyan).SprintFunc()
+	xmlValueColor = color.New(color.FgHiYellow).SprintFunc()
+)
+
+// XMLMessageFormatter pretty prints and colorizes XML messages.
+type XMLMessageFormatter struct{}
+
+var (
+	_ = func() struct{} {
+		for _, contentType := range xmlContentTypes {
+			RegisterMessageFormatter(contentType, XMLMessageFormatter{})
+		}
+		return struct{}{}
+	}()
+)
+
+// charsetReader converts an XML document to UTF-8
+type charsetReader func(label string, input io.Reader) (io.Reader, error)
+
+// utf8CharsetReader ignores the encoding declared by the XML document, used
+// when the body was already converted to UTF-8
+func utf8CharsetReader(_ string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+// readXMLTokens reads all tokens of an XML document, omitting white space
+// between elements. Namespace prefixes are kept as-is. Documents declaring
+// an encoding other than UTF-8 are read using the given charsetReader.
+func readXMLTokens(body []byte, charsetReader charsetReader) ([]xml.Token, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charsetReader
+	var tokens []xml.Token
+	var open []xml.Name // RawToken does not check that elements match
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			if len(open) > 0 {
+				return nil, fmt.Errorf("unclosed element <%s>", xmlName(open[len(open)-1]))
+			}
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+		case xml.StartElement:
+			open = append(open, t.Name)
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, fmt.Errorf("unexpected end element </%s>", xmlName(t.Name))
+			}
+			open = open[:len(open)-1]
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func xmlEscape(s []byte) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, s)
+	return b.String()
+}
+
+func formatXMLStartElement(e xml.StartElement) string {
+	var b strings.Builder
+	b.WriteString("<" + xmlTagColor(xmlName(e.Name)))
+	for _, attr := range e.Attr {
+		b.WriteString(" " + xmlAttrColor(xmlName(attr.Name)) + "=" +
+			xmlValueColor(`"`+xmlEscape([]byte(attr.Value))+`"`))
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// FormatMessage indents and colorizes the XML body of the message. Bodies of
+// messages with a charset in the ContentType are already converted to UTF-8,
+// so the encoding declared by the document is ignored.
+func (s XMLMessageFormatter) FormatMessage(m *amqp.Delivery, body []byte) string {
+	if charset(m.ContentType) != "" {
+		return s.format(body, utf8CharsetReader)
+	}
+	return s.format(body, htmlcharset.NewReaderLabel)
+}
+
+// Format tries to indent and colorize an XML message. Elements containing
+// only text are printed on a single line. If the message is not valid XML,
+// it will be returned unformatted as-is.
+func (s XMLMessageFormatter) Format(body []byte) string {
+	return s.format(body, htmlcharset.NewReaderLabel)
+}
+
+func (s XMLMessageFormatter) format(body []byte, charsetReader charsetReader) string {
+	tokens, err := readXMLTokens(body, charsetReader)
+	if err != nil || len(tokens) == 0 {
+		return string(body)
+	}
+
+	var b strings.Builder
+	depth := 0
+	newline := func() {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("  ", depth))
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i].(type) {
+		case xml.StartElement:
+			newline()
+			b.WriteString(formatXMLStartElement(t))
+			// print <a>text</a> and <a></a> on a single line
+			if i+2 < len(tokens) {
+				if text, ok := tokens[i+1].(xml.CharData); ok {
+					if _, ok := tokens[i+2].(xml.EndElement); ok {
+						b.WriteString(xmlEscape(bytes.TrimSpace(text)))
+						i++
+					}
+				}
+			}
+			if i+1 < len(tokens) {
+				if end, ok := tokens[i+1].(xml.EndElement); ok {
+					b.WriteString("</" + xmlTagColor(xmlName(end.Name)) + ">")
+					i++
+					continue
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			newline()
+			b.WriteString("</" + xmlTagColor(xmlName(t.Name)) + ">")
+		case xml.CharData:
+			newline()
+			b.WriteString(xmlEscape(bytes.TrimSpace(t)))
+		case xml.Comment:
+			newline()
+			b.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			newline()
+			b.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			newline()
+			b.WriteString("<!" + string(t) + ">")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXMLFormatterIndentsMessage(t *testing.T) {
+	color.NoColor = true
+	body := `<?xml version="1.0"?><soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">` +
+		`<soap:Body><!-- order --><order id="o-1"><item sku="A &amp; B"/><note>a &lt; b</note></order>` +
+		`</soap:Body></soap:Envelope>`
+
+	formatted := XMLMessageFormatter{}.Format([]byte(body))
+
+	assert.Equal(t, `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+  <soap:Body>
+    <!-- order -->
+    <order id="o-1">
+      <item sku="A &amp; B"></item>
+      <note>a &lt; b</note>
+    </order>
+  </soap:Body>
+</soap:Envelope>`, formatted)
+}
+
+func TestXMLFormatterColorizesMessage(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+
+	formatted := XMLMessageFormatter{}.Format([]byte(`<a b="c">d</a>`))
+
+	assert.Equal(t, "<\x1b[94ma\x1b[0m \x1b[96mb\x1b[0m=\x1b[93m\"c\"\x1b[0m>d</\x1b[94ma\x1b[0m>", formatted)
+}
+
+func TestXMLFormatterReturnsInvalidMessageAsIs(t *testing.T) {
+	assert.Equal(t, "<a><b></a>", XMLMessageFormatter{}.Format([]byte("<a><b></a>")))
+	assert.Equal(t, "<a>", XMLMessageFormatter{}.Format([]byte("<a>")))
+	assert.Equal(t, "", XMLMessageFormatter{}.Format([]byte("")))
+}
+
+func TestXMLFormatterDecodesDeclaredEncoding(t *testing.T) {
+	color.NoColor = true
+	body := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>gr\xfc\xdfe</a>")
+	expected := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<a>grüße</a>"
+
+	assert.Equal(t, expected, XMLMessageFormatter{}.Format(body))
+	assert.Equal(t, expected, XMLMessageFormatter{}.FormatMessage(&amqp.Delivery{ContentType: "text/xml"}, body))
+}
+
+func TestXMLFormatterIgnoresDeclaredEncodingOfConvertedBody(t *testing.T) {
+	color.NoColor = true
+	// body was converted to UTF-8 according to the charset of the ContentType
+	body := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><a>grüße</a>`)
+	m := &amqp.Delivery{ContentType: "text/xml; charset=ISO-8859-1"}
+
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<a>grüße</a>",
+		XMLMessageFormatter{}.FormatMessage(m, body))
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlContentTypes are the content types of YAML messages
+var yamlContentTypes = []string{
+	"application/yaml",
+	"application/x-yaml",
+	"text/yaml",
+	"text/x-yaml",
+}
+
+// YAMLMessageFormatter pretty prints YAML messages.
+type YAMLMessageFormatter struct{}
+
+var (
+	_ = func() struct{} {
+		for _, contentType := range yamlContentTypes {
+			RegisterMessageFormatter(contentType, YAMLMessageFormatter{})
+		}
+		return struct{}{}
+	}()
+)
+
+// Format tries to re-indent a YAML message, which can consist of multiple
+// documents. Comments and the order of keys are kept. If the message is not
+// valid YAML, it will be returned unformatted as-is.
+func (s YAMLMessageFormatter) Format(body []byte) string {
+	dec := yaml.NewDecoder(bytes.NewReader(body))
+	var formatted strings.Builder
+	enc := yaml.NewEncoder(&formatted)
+	enc.SetIndent(2)
+	documents := 0
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return string(body)
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return string(body)
+		}
+		documents++
+	}
+	if documents == 0 || enc.Close() != nil {
+		return string(body)
+	}
+	return strings.TrimSuffix(formatted.String(), "\n")
+}
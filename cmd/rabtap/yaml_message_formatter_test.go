@@ -0,0 +1,22 @@
+// Copyright (C) 2026 Jan Delgado
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLFormatterIndentsDocuments(t *testing.T) {
+	body := "order:\n    id: o-1 # the id\n    items:\n        - A\n---\nb: 1\n"
+
+	formatted := YAMLMessageFormatter{}.Format([]byte(body))
+
+	assert.Equal(t, "order:\n  id: o-1 # the id\n  items:\n    - A\n---\nb: 1", formatted)
+}
+
+func TestYAMLFormatterReturnsInvalidMessageAsIs(t *testing.T) {
+	assert.Equal(t, "a: [b", YAMLMessageFormatter{}.Format([]byte("a: [b")))
+	assert.Equal(t, "", YAMLMessageFormatter{}.Format([]byte("")))
+}
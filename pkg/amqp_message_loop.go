@@ -11,13 +11,15 @@ import (
 )
 
 // amqpMessageLoop forwards incoming amqp messages from an "in" chan to an "out"
-// chan, transforming them into TapMessage objects. Can be terminated
-// using provided ctx or by closing the in chan.
+// chan, transforming them into TapMessage objects. queue is the queue the
+// messages are consumed from, if any. Can be terminated using provided ctx or
+// by closing the in chan.
 func amqpMessageLoop(
 	ctx context.Context,
 	outCh TapChannel,
 	errOutCh SubscribeErrorChannel,
-	inCh <-chan interface{}) (ReconnectAction, error) {
+	inCh <-chan interface{},
+	queue string) (ReconnectAction, error) {
 
 	for {
 		select {
@@ -41,7 +43,7 @@ func amqpMessageLoop(
 				select {
 				case <-ctx.Done():
 					return doNotReconnect, nil
-				case outCh <- NewTapMessage(&msg, received):
+				case outCh <- TapMessage{AmqpMessage: &msg, ReceivedTimestamp: received, Queue: queue}:
 				}
 			default:
 				panic("unknown message type")
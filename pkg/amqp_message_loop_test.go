@@ -18,7 +18,7 @@ func TestAmqpMessageLoopPanicsWithInvalidMessage(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		assert.Panics(t, func() { _, _ = amqpMessageLoop(ctx, out, errOut, in) }, "did not panic")
+		assert.Panics(t, func() { _, _ = amqpMessageLoop(ctx, out, errOut, in, "queue") }, "did not panic")
 		done <- true
 	}()
 
@@ -43,7 +43,7 @@ func TestAmqpMessageLoopTerminatesWhenInputChannelIsClosed(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		result, _ := amqpMessageLoop(ctx, out, errOut, in)
+		result, _ := amqpMessageLoop(ctx, out, errOut, in, "queue")
 		done <- result
 	}()
 
@@ -65,7 +65,7 @@ func TestAmqpMessageLoopCancelBlockingWrite(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		result, _ := amqpMessageLoop(ctx, out, errOut, in)
+		result, _ := amqpMessageLoop(ctx, out, errOut, in, "queue")
 		done <- result
 	}()
 
@@ -93,7 +93,7 @@ func TestAmqpMessageLoopForwardsAMessage(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		result, _ := amqpMessageLoop(ctx, out, errOut, in)
+		result, _ := amqpMessageLoop(ctx, out, errOut, in, "queue")
 		done <- result
 	}()
 
@@ -103,6 +103,7 @@ func TestAmqpMessageLoopForwardsAMessage(t *testing.T) {
 	select {
 	case msg := <-out:
 		assert.Equal(t, expected, *msg.AmqpMessage)
+		assert.Equal(t, "queue", msg.Queue)
 	case <-time.After(2 * time.Second):
 		assert.Fail(t, "amqpMessageLoop() did not terminate")
 	}
@@ -117,7 +118,7 @@ func TestAmqpMessageLoopForwardsAnError(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		result, _ := amqpMessageLoop(ctx, out, errOut, in)
+		result, _ := amqpMessageLoop(ctx, out, errOut, in, "queue")
 		done <- result
 	}()
 
@@ -141,7 +142,7 @@ func TestAmqpMessageLoopCancelBlockingRead(t *testing.T) {
 	errOut := make(SubscribeErrorChannel)
 
 	go func() {
-		result, _ := amqpMessageLoop(ctx, out, errOut, in)
+		result, _ := amqpMessageLoop(ctx, out, errOut, in, "queue")
 		done <- result
 	}()
 
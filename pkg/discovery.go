@@ -5,6 +5,10 @@ package rabtap
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -45,7 +49,7 @@ func DiscoverBindingsForExchange(ctx context.Context, rabbitAPIClient *RabbitHTT
 		}
 
 		for _, binding := range bindings {
-			if binding.Source == exchangeName {
+			if binding.Source == exchangeName && binding.Vhost == vhost {
 				bindingKeys = append(bindingKeys, binding.RoutingKey)
 			}
 		}
@@ -59,3 +63,132 @@ func DiscoverBindingsForExchange(ctx context.Context, rabbitAPIClient *RabbitHTT
 	return nil, errors.New("exchange '" + exchangeName + "': unknown type " +
 		*exchangeType)
 }
+
+// VhostFromURL returns the vhost of the given AMQP URL, which defaults to "/"
+func VhostFromURL(amqpURL *url.URL) (string, error) {
+	uri, err := amqp.ParseURI(amqpURL.String())
+	if err != nil {
+		return "", err
+	}
+	return uri.Vhost, nil
+}
+
+// DiscoverExchangesMatching returns the sorted names of all exchanges on the
+// given vhost, whose names match the given pattern. The default exchange and
+// exchanges created by rabtap itself are never returned.
+func DiscoverExchangesMatching(ctx context.Context, rabbitAPIClient *RabbitHTTPClient, vhost string, pattern *regexp.Regexp) ([]string, error) {
+	exchanges, err := rabbitAPIClient.Exchanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, exchange := range exchanges {
+		if exchange.Vhost != vhost || exchange.Name == "" ||
+			IsTapExchange(exchange.Name) {
+			continue
+		}
+		if pattern.MatchString(exchange.Name) {
+			names = append(names, exchange.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// DiscoverExchangeConfigurations returns a list of ExchangeConfigurations,
+// where each configuration with an ExchangePattern is replaced by one
+// configuration for each matching exchange, and each configuration with
+// DiscoverBindings set is replaced by one configuration for each binding key
+// discovered on the given broker and vhost. All other configurations are
+// returned unchanged.
+func DiscoverExchangeConfigurations(ctx context.Context,
+	rabbitAPIClient *RabbitHTTPClient,
+	vhost string,
+	exchangeConfigs []ExchangeConfiguration,
+) ([]ExchangeConfiguration, error) {
+	var result []ExchangeConfiguration
+	for _, config := range exchangeConfigs {
+		if !config.DiscoverBindings && config.ExchangePattern == nil {
+			result = append(result, config)
+			continue
+		}
+		exchanges := []string{config.Exchange}
+		if config.ExchangePattern != nil {
+			var err error
+			exchanges, err = DiscoverExchangesMatching(ctx, rabbitAPIClient, vhost, config.ExchangePattern)
+			if err != nil {
+				return nil, fmt.Errorf("discover exchanges matching %s: %w", config.Exchange, err)
+			}
+		}
+		for _, exchange := range exchanges {
+			if !config.DiscoverBindings {
+				result = append(result, ExchangeConfiguration{
+					Exchange:    exchange,
+					BindingKey:  config.BindingKey,
+					BindingArgs: config.BindingArgs,
+				})
+				continue
+			}
+			keys, err := DiscoverBindingsForExchange(ctx, rabbitAPIClient, vhost, exchange)
+			if err != nil {
+				return nil, fmt.Errorf("discover bindings of exchange %s: %w", exchange, err)
+			}
+			// multiple queues can be bound using the same key, but we must
+			// tap each key only once to avoid duplicate messages.
+			slices.Sort(keys)
+			for _, key := range slices.Compact(keys) {
+				result = append(result, ExchangeConfiguration{Exchange: exchange, BindingKey: key})
+			}
+		}
+	}
+	return result, nil
+}
+
+// DiscoverQueuesMatching returns the sorted names of all queues on the given
+// vhost, whose names match the given pattern. Queues created by rabtap
+// itself are never returned.
+func DiscoverQueuesMatching(ctx context.Context, rabbitAPIClient *RabbitHTTPClient, vhost string, pattern *regexp.Regexp) ([]string, error) {
+	queues, err := rabbitAPIClient.Queues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, queue := range queues {
+		if queue.Vhost != vhost || IsTapQueue(queue.Name) {
+			continue
+		}
+		if pattern.MatchString(queue.Name) {
+			names = append(names, queue.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// DiscoverQueues returns the names of the queues selected by the given
+// selectors, where each selector with a QueuePattern is replaced by the
+// matching queues on the given broker and vhost. Each queue is returned
+// only once.
+func DiscoverQueues(ctx context.Context,
+	rabbitAPIClient *RabbitHTTPClient,
+	vhost string,
+	selectors []QueueSelector,
+) ([]string, error) {
+	var result []string
+	for _, selector := range selectors {
+		queues := []string{selector.Queue}
+		if selector.QueuePattern != nil {
+			var err error
+			queues, err = DiscoverQueuesMatching(ctx, rabbitAPIClient, vhost, selector.QueuePattern)
+			if err != nil {
+				return nil, fmt.Errorf("discover queues matching %s: %w", selector.Queue, err)
+			}
+		}
+		for _, queue := range queues {
+			if !slices.Contains(result, queue) {
+				result = append(result, queue)
+			}
+		}
+	}
+	return result, nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"net/url"
+	"regexp"
 	"testing"
 
 	"github.com/jandelgado/rabtap/pkg/testcommon"
@@ -75,3 +76,136 @@ func TestDiscoveryHeadersExchange(t *testing.T) {
 	assert.Equal(t, 1, len(result))
 	assert.Equal(t, "", result[0])
 }
+
+func TestVhostFromURL(t *testing.T) {
+	for input, expected := range map[string]string{
+		"amqp://localhost":            "/",
+		"amqp://localhost/":           "/",
+		"amqp://guest@localhost/%2f":  "/",
+		"amqp://localhost:5672/vhost": "vhost",
+	} {
+		u, _ := url.Parse(input)
+		vhost, err := VhostFromURL(u)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, vhost, input)
+	}
+}
+
+func TestDiscoverExchangeConfigurationsExpandsBindings(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	configs := []ExchangeConfiguration{
+		{Exchange: "test-direct", DiscoverBindings: true},
+		{Exchange: "test-topic", BindingKey: "key"},
+		{Exchange: "test-topic", DiscoverBindings: true},
+	}
+	result, err := DiscoverExchangeConfigurations(context.TODO(), client, "/", configs)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ExchangeConfiguration{
+		{Exchange: "test-direct", BindingKey: "direct-q1"},
+		{Exchange: "test-direct", BindingKey: "direct-q2"},
+		{Exchange: "test-topic", BindingKey: "key"},
+		{Exchange: "test-topic", BindingKey: "#"},
+	}, result)
+}
+
+func TestDiscoverExchangeConfigurationsFailsOnUnknownExchange(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	configs := []ExchangeConfiguration{{Exchange: "unknown", DiscoverBindings: true}}
+	_, err := DiscoverExchangeConfigurations(context.TODO(), client, "/", configs)
+
+	assert.NotNil(t, err)
+}
+
+func TestDiscoverExchangesMatching(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	result, err := DiscoverExchangesMatching(context.TODO(), client, "/", regexp.MustCompile("^test-(topic|fanout)$"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test-fanout", "test-topic"}, result)
+}
+
+func TestDiscoverExchangesMatchingIgnoresDefaultExchange(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	result, err := DiscoverExchangesMatching(context.TODO(), client, "/", regexp.MustCompile(".*"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"amq.direct", "amq.fanout", "amq.headers", "amq.match", "amq.rabbitmq.log",
+		"amq.rabbitmq.trace", "amq.topic", "test-direct", "test-fanout", "test-headers",
+		"test-topic"}, result)
+}
+
+func TestDiscoverExchangeConfigurationsResolvesPatterns(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	configs := []ExchangeConfiguration{
+		{Exchange: "test-*", ExchangePattern: regexp.MustCompile("^test-(topic|headers)$"), BindingKey: "key"},
+		{Exchange: "test-d*", ExchangePattern: regexp.MustCompile("^test-d.*$"), DiscoverBindings: true},
+	}
+	result, err := DiscoverExchangeConfigurations(context.TODO(), client, "/", configs)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ExchangeConfiguration{
+		{Exchange: "test-headers", BindingKey: "key"},
+		{Exchange: "test-topic", BindingKey: "key"},
+		{Exchange: "test-direct", BindingKey: "direct-q1"},
+		{Exchange: "test-direct", BindingKey: "direct-q2"},
+	}, result)
+}
+
+func TestDiscoverQueuesMatching(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	result, err := DiscoverQueuesMatching(context.TODO(), client, "/", regexp.MustCompile("^direct-"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"direct-q1", "direct-q2"}, result)
+}
+
+func TestDiscoverQueuesResolvesPatternsAndRemovesDuplicates(t *testing.T) {
+
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+	var selectors []QueueSelector
+	for _, queue := range []string{"topic-q2", "topic-*", "re:^fanout-q[1]$", "unknown"} {
+		selector, err := NewQueueSelector(queue)
+		assert.NoError(t, err)
+		selectors = append(selectors, selector)
+	}
+
+	result, err := DiscoverQueues(context.TODO(), client, "/", selectors)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"topic-q2", "topic-q1", "fanout-q1", "unknown"}, result)
+}
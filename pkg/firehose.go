@@ -0,0 +1,57 @@
+// Copyright (C) 2026 Jan Delgado
+// Tapping the RabbitMQ FireHose tracer (see https://www.rabbitmq.com/firehose.html)
+
+package rabtap
+
+import "strings"
+
+const (
+	// FireHoseExchange is the exchange the FireHose tracer publishes to
+	FireHoseExchange = "amq.rabbitmq.trace"
+	// DefaultExchangeAlias is used to reference the default exchange "", which
+	// would otherwise not be expressable e.g. in a list of exchange names.
+	DefaultExchangeAlias = "amq.default"
+)
+
+// fireHoseRoutingKey returns the routing key used by the FireHose tracer
+// for the given event ("publish", "deliver") and exchange or queue name.
+// Wildcards are not escaped, since topic routing keys do not support escaping.
+func fireHoseRoutingKey(event, name string) string {
+	if name == DefaultExchangeAlias {
+		name = ""
+	}
+	return event + "." + name
+}
+
+// NewFireHoseExchangeConfigurations returns the ExchangeConfigurations to tap
+// the FireHose exchange for messages published to the given exchanges and for
+// messages delivered to the given queues. The FireHose tracer publishes these
+// events with the routing keys "publish.{exchange}" and "deliver.{queue}",
+// where the default exchange can be referenced by DefaultExchangeAlias. If
+// neither exchanges nor queues are given, all published messages are tapped.
+func NewFireHoseExchangeConfigurations(exchanges, queues []string) []ExchangeConfiguration {
+	var result []ExchangeConfiguration
+	for _, exchange := range exchanges {
+		result = append(result, ExchangeConfiguration{
+			Exchange:   FireHoseExchange,
+			BindingKey: fireHoseRoutingKey("publish", exchange),
+		})
+	}
+	for _, queue := range queues {
+		result = append(result, ExchangeConfiguration{
+			Exchange:   FireHoseExchange,
+			BindingKey: fireHoseRoutingKey("deliver", queue),
+		})
+	}
+	if len(result) == 0 {
+		result = append(result, ExchangeConfiguration{Exchange: FireHoseExchange, BindingKey: "publish.#"})
+	}
+	return result
+}
+
+// IsFireHoseEvent returns true if the given exchange and routing key denote
+// a message published by the FireHose tracer.
+func IsFireHoseEvent(exchange, routingKey string) bool {
+	return exchange == FireHoseExchange &&
+		(strings.HasPrefix(routingKey, "publish.") || strings.HasPrefix(routingKey, "deliver."))
+}
@@ -0,0 +1,33 @@
+package rabtap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFireHoseExchangeConfigurationsDefaultsToAllPublishedMessages(t *testing.T) {
+	configs := NewFireHoseExchangeConfigurations(nil, nil)
+
+	assert.Equal(t, []ExchangeConfiguration{
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish.#"},
+	}, configs)
+}
+
+func TestNewFireHoseExchangeConfigurationsBindsExchangesAndQueues(t *testing.T) {
+	configs := NewFireHoseExchangeConfigurations(
+		[]string{"amq.default", "orders"}, []string{"q1"})
+
+	assert.Equal(t, []ExchangeConfiguration{
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish."},
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "publish.orders"},
+		{Exchange: "amq.rabbitmq.trace", BindingKey: "deliver.q1"},
+	}, configs)
+}
+
+func TestIsFireHoseEvent(t *testing.T) {
+	assert.True(t, IsFireHoseEvent("amq.rabbitmq.trace", "publish.orders"))
+	assert.True(t, IsFireHoseEvent("amq.rabbitmq.trace", "deliver.q1"))
+	assert.False(t, IsFireHoseEvent("amq.rabbitmq.trace", "other"))
+	assert.False(t, IsFireHoseEvent("amq.topic", "publish.orders"))
+}
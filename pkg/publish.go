@@ -16,6 +16,8 @@ import (
 const (
 	timeoutWaitACK    = time.Second * 2
 	timeoutWaitServer = time.Millisecond * 500
+	// maxRetryBackoff limits the doubled delay before a retry
+	maxRetryBackoff = time.Minute * 5
 )
 
 // PublishMessage is a message to be published by AmqpPublish via
@@ -23,17 +25,82 @@ const (
 type PublishMessage struct {
 	Routing    Routing
 	Publishing *amqp.Publishing
+	// Position is the position of the message in its source, starting with
+	// 1. Used to report failed messages, 0 if unknown.
+	Position int64
+	// Done is called, if set, when publishing of the message finished. With
+	// confirms enabled, this is after the broker confirmed the message. err is
+	// nil on success or the *PublishError describing the failure. Not called
+	// when publishing is cancelled.
+	Done func(err error)
+
+	// retries counts how often publishing of the message was retried
+	retries int
+	// sent counts how often the message was published
+	sent int
+}
+
+// done reports the result of publishing the message, if requested.
+func (s *PublishMessage) done(err error) {
+	if s.Done != nil {
+		s.Done(err)
+	}
 }
 
 // PublishChannel is a channel for PublishMessage message objects
 type PublishChannel chan *PublishMessage
 
+// AmqpPublishConfig stores the configuration of the publisher
+type AmqpPublishConfig struct {
+	// Mandatory sets the mandatory flag on published messages
+	Mandatory bool
+	// Confirms enables publisher confirms
+	Confirms bool
+	// ConfirmWindow is the maximum number of published messages waiting for
+	// their confirmation. If 0, each message is confirmed before the next
+	// message is published.
+	ConfirmWindow int
+	// Retries is the number of times a failed message (e.g. nacked, not
+	// confirmed in time or returned) is published again, before the error is
+	// reported.
+	Retries int
+	// RetryBackoff is the delay before a failed message is published again,
+	// which is doubled with every retry.
+	RetryBackoff time.Duration
+}
+
 // AmqpPublish allows to send to a RabbitMQ exchange.
 type AmqpPublish struct {
 	logger     *slog.Logger
 	connection *AmqpConnector
-	mandatory  bool
-	confirms   bool
+	config     AmqpPublishConfig
+	// retryCh receives failed messages to publish again after their backoff.
+	// Retries are kept across reconnects.
+	retryCh chan *PublishMessage
+	// scheduledRetries is the number of messages waiting for their retry
+	scheduledRetries int
+	// journal tracks the unconfirmed messages across sessions. Messages not
+	// confirmed when the channel was closed are re-sent after the reconnect.
+	journal *pendingConfirms
+	stats   PublishStats
+}
+
+// PublishStats counts the messages processed by AmqpPublish
+type PublishStats struct {
+	Published int64 // messages received on the publish channel
+	Confirmed int64 // messages confirmed by the broker
+	Resent    int64 // messages published again after a reconnect or retry
+	Failed    int64 // messages reported as failed
+}
+
+// Add returns the sum of the stats
+func (s PublishStats) Add(other PublishStats) PublishStats {
+	return PublishStats{
+		Published: s.Published + other.Published,
+		Confirmed: s.Confirmed + other.Confirmed,
+		Resent:    s.Resent + other.Resent,
+		Failed:    s.Failed + other.Failed,
+	}
 }
 
 type PublishErrorReason int
@@ -51,7 +118,8 @@ const (
 type PublishError struct {
 	Reason PublishErrorReason
 	// Publishing stores the original message, if available (AckTimeout, Nack,
-	// PublishFailed)
+	// PublishFailed, Returned when confirms are enabled, and ChannelError
+	// when the broker closed the channel while the message was unconfirmed)
 	Message *PublishMessage
 	// ReturnedMessage stores the returned message in case of PublishErrorReturned
 	ReturnedMessage *amqp.Return
@@ -61,26 +129,46 @@ type PublishError struct {
 
 type PublishErrorChannel chan *PublishError
 
+// messageName names the message in error messages, including its position in
+// the source, if known.
+func (s *PublishError) messageName() string {
+	if s.Message != nil && s.Message.Position > 0 {
+		return fmt.Sprintf("message #%d", s.Message.Position)
+	}
+	return "message"
+}
+
+// IsMessageError returns true if the error reports a failed message. Other
+// errors report a failed channel, after which the connection is
+// re-established.
+func (s *PublishError) IsMessageError() bool {
+	return s.Message != nil || s.ReturnedMessage != nil
+}
+
 func (s *PublishError) Error() string {
 	switch s.Reason {
 	case PublishErrorAckTimeout:
-		return fmt.Sprintf("publish to %s failed: timeout waiting for ACK",
-			s.Message.Routing)
+		return fmt.Sprintf("publish of %s to %s failed: timeout waiting for ACK",
+			s.messageName(), s.Message.Routing)
 	case PublishErrorNack:
-		return fmt.Sprintf("publish to %s failed: NACK",
-			s.Message.Routing)
+		return fmt.Sprintf("publish of %s to %s failed: NACK",
+			s.messageName(), s.Message.Routing)
 	case PublishErrorPublishFailed:
-		return fmt.Sprintf("publish to %s failed: %s",
-			s.Message.Routing, s.Cause)
+		return fmt.Sprintf("publish of %s to %s failed: %s",
+			s.messageName(), s.Message.Routing, s.Cause)
 	case PublishErrorReturned:
 		// note: RabbitMQ seems not to set the headers on a returned message
 		// when e.g. header based routing was used.
 		routing := NewRouting(s.ReturnedMessage.Exchange,
 			s.ReturnedMessage.RoutingKey,
 			s.ReturnedMessage.Headers)
-		return fmt.Sprintf("server returned message for %s: %s",
-			routing, s.ReturnedMessage.ReplyText)
+		return fmt.Sprintf("server returned %s for %s: %s",
+			s.messageName(), routing, s.ReturnedMessage.ReplyText)
 	case PublishErrorChannelError:
+		if s.Message != nil {
+			return fmt.Sprintf("publish of %s to %s failed: channel error: %s",
+				s.messageName(), s.Message.Routing, s.Cause)
+		}
 		return fmt.Sprintf("channel error: %s", s.Cause)
 	}
 	return "unexpected error"
@@ -88,15 +176,39 @@ func (s *PublishError) Error() string {
 
 // NewAmqpPublish returns a new AmqpPublish object associated with the RabbitMQ
 // broker denoted by the uri parameter.
-func NewAmqpPublish(url *url.URL, tlsConfig *tls.Config,
-	mandatory, confirms bool, logger *slog.Logger,
+func NewAmqpPublish(config AmqpPublishConfig, url *url.URL, tlsConfig *tls.Config,
+	logger *slog.Logger,
 ) *AmqpPublish {
 	return &AmqpPublish{
 		connection: NewAmqpConnector(url, tlsConfig, logger),
-		mandatory:  mandatory,
-		confirms:   confirms,
+		config:     config,
 		logger:     logger,
+		retryCh:    make(chan *PublishMessage),
+		journal:    &pendingConfirms{},
+	}
+}
+
+// Stats returns the message counts. Must not be called before
+// EstablishConnection returned.
+func (s *AmqpPublish) Stats() PublishStats {
+	return s.stats
+}
+
+// retryBackoff returns the delay before the given retry of a message. The
+// delay is doubled with every retry, up to maxRetryBackoff or RetryBackoff,
+// whichever is greater.
+func (s *AmqpPublish) retryBackoff(retry int) time.Duration {
+	backoff := s.config.RetryBackoff
+	limit := max(s.config.RetryBackoff, maxRetryBackoff)
+	for i := 1; i < retry && backoff > 0 && backoff < limit; i++ {
+		backoff *= 2
 	}
+	return min(backoff, limit)
+}
+
+// confirmWindow returns the maximum number of unconfirmed messages.
+func (s *AmqpPublish) confirmWindow() int {
+	return max(s.config.ConfirmWindow, 1)
 }
 
 // createWorkerFunc creates a function that receives messages on the provided
@@ -117,131 +229,315 @@ func NewAmqpPublish(url *url.URL, tlsConfig *tls.Config,
 // The immedeate flag is not supported since RabbitMQ 3.0, see
 // https://blog.rabbitmq.com/posts/2012/11/breaking-things-with-rabbitmq-3-0
 //
+// Publisher confirms:
+// With confirms enabled, up to ConfirmWindow messages are published before
+// waiting for their confirmations (https://www.rabbitmq.com/confirms.html).
+// Each confirmation and returned message is matched back to its message.
+//
+// Retries:
+// Failed messages are published again after a backoff, up to Retries times.
+// Only then the error is sent to the error channel.
+//
+// Reconnects:
+// With confirms enabled, messages not confirmed when the connection was lost
+// are kept in the journal and published again on the new session. When the
+// broker closed the channel because of an error (e.g. publishing to a
+// non-existing exchange), the unconfirmed messages fail instead, since they
+// would be rejected again.
+//
 // TODO detect throttling
-// TODO simplify
 func (s *AmqpPublish) createWorkerFunc(
 	publishCh PublishChannel,
 	errorCh PublishErrorChannel,
 ) AmqpWorkerFunc {
 	return func(ctx context.Context, session Session) (ReconnectAction, error) {
-		// errors receives channel errors (e.g. publishing to non-existant exchange)
-		errors := session.Channel.NotifyClose(make(chan *amqp.Error, 1))
-		// return receivces unroutable messages back from the server
-		returns := session.NotifyReturn(make(chan amqp.Return, 1))
-		// confirms receives confirmations from the server (if enabled below)
-		confirms := session.NotifyPublish(make(chan amqp.Confirmation, 1))
-
-		if s.confirms {
-			if err := session.Confirm(false); err != nil {
-				s.logger.Error("Channel could not be put into confirm mode", "error", err)
-			}
+		return s.publishSession(ctx, session.Channel, publishCh, errorCh)
+	}
+}
+
+// publishingChannel is the part of an amqp.Channel used to publish messages
+type publishingChannel interface {
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Confirm(noWait bool) error
+	GetNextPublishSeqNo() uint64
+	PublishWithContext(ctx context.Context, exchange, key string,
+		mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// isClosedByServer returns true if the broker closed the channel because of
+// a (soft) error caused by the client, as opposed to a lost connection.
+func isClosedByServer(err *amqp.Error) bool {
+	return err != nil && err.Server && err.Recover
+}
+
+// publishSession publishes the messages received on publishCh on the given
+// channel until publishCh is closed or the channel fails.
+func (s *AmqpPublish) publishSession(
+	ctx context.Context,
+	session publishingChannel,
+	publishCh PublishChannel,
+	errorCh PublishErrorChannel,
+) (ReconnectAction, error) {
+	window := s.confirmWindow()
+	// errors receives channel errors (e.g. publishing to non-existant exchange)
+	errors := session.NotifyClose(make(chan *amqp.Error, 1))
+	// return receivces unroutable messages back from the server
+	returns := session.NotifyReturn(make(chan amqp.Return, window))
+	// confirms receives confirmations from the server (if enabled below).
+	// The buffer holds the confirmations of all pending messages.
+	confirms := session.NotifyPublish(make(chan amqp.Confirmation, window))
+
+	if s.config.Confirms {
+		if err := session.Confirm(false); err != nil {
+			s.logger.Error("Channel could not be put into confirm mode", "error", err)
 		}
+	}
+
+	pending := s.journal
 
-		// wait a while for outstanding errors and returned messages
-		// since these can arrive after we finished publishing.
-		defer func() {
-			s.logger.Debug("waiting for pending server messages ... ")
-			timeout := time.After(timeoutWaitServer)
-
-			// wait for pending returned messages from the broker, when e.g. a
-			// message could not be routed. in this case the message WILL be
-			// confirmed (ACK=true), but an async return message will be send,
-			// for which we wait here.
-			for {
+	// fail retries the failed message of publishErr or reports the error
+	// when all retries are used up.
+	fail := func(publishErr *PublishError) {
+		message := publishErr.Message
+		if message != nil && message.retries < s.config.Retries {
+			message.retries++
+			backoff := s.retryBackoff(message.retries)
+			s.logger.Warn("publishing failed, retrying", "error", publishErr,
+				"retry", message.retries, "backoff", backoff)
+			s.scheduledRetries++
+			time.AfterFunc(backoff, func() {
 				select {
-				case <-timeout:
-					return
+				case s.retryCh <- message:
+				case <-ctx.Done():
+				}
+			})
+			return
+		}
+		s.stats.Failed++
+		errorCh <- publishErr
+		if message != nil {
+			message.done(publishErr)
+		}
+	}
 
-				case returned, more := <-returns:
-					if more {
-						errorCh <- &PublishError{Reason: PublishErrorReturned, ReturnedMessage: &returned}
-					}
+	onReturn := func(returned amqp.Return) {
+		publishErr := &PublishError{Reason: PublishErrorReturned, ReturnedMessage: &returned}
+		if s.config.Confirms && pending.returned(publishErr) {
+			return // the message fails when it is confirmed
+		}
+		s.stats.Failed++
+		errorCh <- publishErr
+	}
 
-				case err, more := <-errors:
-					if more {
-						errorCh <- &PublishError{Reason: PublishErrorChannelError, Cause: err}
-					}
+	// "For unroutable messages, the broker will issue a confirm
+	// once the exchange verifies a message won't route to any
+	// queue (returns an empty list of queues). If the message
+	// is also published as mandatory, the basic.return is sent
+	// to the client before basic.ack. The same is true for
+	// negative acknowledgements (basic.nack)."
+	onConfirm := func(confirmed amqp.Confirmation) {
+		// the return is received before the confirmation, but select
+		// may have chosen the confirmation first.
+		for drained := false; !drained; {
+			select {
+			case returned, more := <-returns:
+				if more {
+					onReturn(returned)
 				}
+			default:
+				drained = true
 			}
-		}()
+		}
+		p, ok := pending.confirmed(confirmed.DeliveryTag)
+		if !ok {
+			s.logger.Debug("ignoring confirmation of unknown message",
+				"delivery_tag", confirmed.DeliveryTag)
+			return
+		}
+		switch {
+		case !confirmed.Ack:
+			fail(&PublishError{Reason: PublishErrorNack, Message: p.message})
+		case p.returnErr != nil:
+			fail(p.returnErr)
+		default:
+			s.logger.Info("delivery was ACKed by the server",
+				"delivery_tag", confirmed.DeliveryTag)
+			s.stats.Confirmed++
+			p.message.done(nil)
+		}
+	}
 
-		ackTimeout := time.NewTimer(timeoutWaitACK)
-		defer ackTimeout.Stop()
+	// wait a while for outstanding errors and returned messages
+	// since these can arrive after we finished publishing.
+	defer func() {
+		s.logger.Debug("waiting for pending server messages ... ")
+		timeout := time.After(timeoutWaitServer)
 
+		// wait for pending returned messages from the broker, when e.g. a
+		// message could not be routed. in this case the message WILL be
+		// confirmed (ACK=true), but an async return message will be send,
+		// for which we wait here.
 		for {
 			select {
-			case err := <-errors:
-				// all errors render the channel invalid, so reconnect
-				errorCh <- &PublishError{Reason: PublishErrorChannelError, Cause: err}
-				return doReconnect, fmt.Errorf("channel error: %w", err)
+			case <-timeout:
+				return
 
 			case returned, more := <-returns:
 				if more {
-					errorCh <- &PublishError{Reason: PublishErrorReturned, ReturnedMessage: &returned}
+					onReturn(returned)
+				}
+
+			case confirmed, more := <-confirms:
+				if more {
+					onConfirm(confirmed)
 				}
 
-			case message, more := <-publishCh:
-				if !more {
-					s.logger.Debug("publishing channel closed.")
-					return doNotReconnect, nil
+			case err, more := <-errors:
+				if more {
+					errorCh <- &PublishError{Reason: PublishErrorChannelError, Cause: err}
 				}
+			}
+		}
+	}()
+
+	// ackTimeout fires when the oldest pending message was not confirmed in
+	// time. Each message times out on its own, so that messages published
+	// later can still be confirmed.
+	ackTimeout := time.NewTimer(timeoutWaitACK)
+	defer ackTimeout.Stop()
+	resetAckTimeout := func() {
+		if p, ok := pending.oldest(); ok {
+			ackTimeout.Reset(time.Until(p.sent.Add(timeoutWaitACK)))
+		}
+	}
+
+	publish := func(message *PublishMessage) {
+		if message.sent > 0 {
+			s.stats.Resent++
+		} else {
+			s.stats.Published++
+		}
+		message.sent++
+		size := len((*message.Publishing).Body)
+		s.logger.Debug("publishing message", "routing", message.Routing, "size", size)
+		headers := EnsureAMQPTable(message.Routing.Headers()).(amqp.Table)
+		message.Publishing.Headers = headers
+		tag := session.GetNextPublishSeqNo()
+		err := session.PublishWithContext(
+			ctx,
+			message.Routing.Exchange(),
+			message.Routing.Key(),
+			s.config.Mandatory,
+			false, // immeadiate flag was removed with RabbitMQ 3
+			*message.Publishing)
 
-				size := len((*message.Publishing).Body)
-				s.logger.Debug("publishing message", "routing", message.Routing, "size", size)
-				headers := EnsureAMQPTable(message.Routing.Headers()).(amqp.Table)
-				message.Publishing.Headers = headers
-				err := session.PublishWithContext(
-					ctx,
-					message.Routing.Exchange(),
-					message.Routing.Key(),
-					s.mandatory,
-					false, // immeadiate flag was removed with RabbitMQ 3
-					*message.Publishing)
-
-				if err != nil {
-					errorCh <- &PublishError{Reason: PublishErrorPublishFailed, Message: message, Cause: err}
-				} else {
-					// wait for the confirmation before publishing a new message
-					// https://www.rabbitmq.com/confirms.html
-					// TODO batched confirms
-					//
-					// "For unroutable messages, the broker will issue a confirm
-					// once the exchange verifies a message won't route to any
-					// queue (returns an empty list of queues). If the message
-					// is also published as mandatory, the basic.return is sent
-					// to the client before basic.ack. The same is true for
-					// negative acknowledgements (basic.nack)."
-					if s.confirms {
-						ackTimeout.Reset(timeoutWaitACK)
-					Outer:
-						for {
-							select {
-							case <-ackTimeout.C:
-								errorCh <- &PublishError{Reason: PublishErrorAckTimeout, Message: message}
-								break Outer
-							case returned, more := <-returns:
-								if more {
-									errorCh <- &PublishError{Reason: PublishErrorReturned, ReturnedMessage: &returned}
-								}
-							case confirmed := <-confirms:
-								if !confirmed.Ack {
-									errorCh <- &PublishError{Reason: PublishErrorNack, Message: message}
-								} else {
-									s.logger.Info("delivery was ACKed by the server",
-										"delivery_tag", confirmed.DeliveryTag)
-								}
-								break Outer
-							case <-ctx.Done():
-								return doNotReconnect, nil
-							}
-						}
+		switch {
+		case err != nil:
+			fail(&PublishError{Reason: PublishErrorPublishFailed, Message: message, Cause: err})
+		case !s.config.Confirms:
+			message.done(nil)
+		default:
+			if pending.Len() == 0 {
+				ackTimeout.Reset(timeoutWaitACK)
+			}
+			pending.add(tag, message)
+		}
+	}
+
+	// re-send messages which were not confirmed on the previous session.
+	// Returned messages fail instead, since they would be returned again.
+	for _, p := range pending.removeAll() {
+		if p.returnErr != nil {
+			fail(p.returnErr)
+			continue
+		}
+		s.logger.Warn("re-sending unconfirmed message", "routing", p.message.Routing,
+			"position", p.message.Position)
+		publish(p.message)
+	}
+
+	input := publishCh
+	for {
+		// stop reading messages while the window is full
+		in, retryIn := input, s.retryCh
+		if pending.Len() >= window {
+			in, retryIn = nil, nil
+		}
+		var timeout <-chan time.Time
+		if pending.Len() > 0 {
+			timeout = ackTimeout.C
+		}
+		if input == nil && pending.Len() == 0 && s.scheduledRetries == 0 {
+			s.logger.Debug("publishing channel closed.")
+			return doNotReconnect, nil
+		}
+
+		select {
+		case err := <-errors:
+			// all errors render the channel invalid, so reconnect
+			errorCh <- &PublishError{Reason: PublishErrorChannelError, Cause: err}
+			// messages not confirmed until now will never be confirmed
+			for drained := false; !drained; {
+				select {
+				case confirmed, more := <-confirms:
+					if more {
+						onConfirm(confirmed)
+					} else {
+						drained = true
 					}
+				default:
+					drained = true
+				}
+			}
+			if isClosedByServer(err) {
+				// re-sending would fail again, so the messages are
+				// retried, if configured.
+				for _, p := range pending.removeAll() {
+					fail(&PublishError{Reason: PublishErrorChannelError, Message: p.message, Cause: err})
 				}
+			}
+			// the remaining messages are re-sent after the reconnect
+			if pending.Len() > 0 {
+				s.logger.Warn("messages not confirmed before connection was lost",
+					"count", pending.Len())
+			}
+			return doReconnect, fmt.Errorf("channel error: %w", err)
+
+		case returned, more := <-returns:
+			if more {
+				onReturn(returned)
+			}
 
-			case <-ctx.Done():
-				return doNotReconnect, nil
+		case confirmed, more := <-confirms:
+			if more {
+				onConfirm(confirmed)
+				resetAckTimeout()
+			}
 
+		case <-timeout:
+			for _, p := range pending.expired(time.Now().Add(-timeoutWaitACK)) {
+				fail(&PublishError{Reason: PublishErrorAckTimeout, Message: p.message})
 			}
+			resetAckTimeout()
+
+		case message, more := <-in:
+			if !more {
+				// wait for pending confirmations before returning
+				input = nil
+				continue
+			}
+
+			publish(message)
+
+		case message := <-retryIn:
+			s.scheduledRetries--
+			publish(message)
+
+		case <-ctx.Done():
+			return doNotReconnect, nil
+
 		}
 	}
 }
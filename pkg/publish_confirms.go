@@ -0,0 +1,139 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"bytes"
+	"time"
+)
+
+// pendingConfirm is a published message waiting for its confirmation
+type pendingConfirm struct {
+	tag     uint64
+	message *PublishMessage
+	// sent is the time the message was published
+	sent time.Time
+	// returnErr is set when the message was returned by the broker
+	returnErr *PublishError
+	// confirmed is set when the message was removed from the pending messages
+	confirmed bool
+}
+
+// pendingConfirms tracks published messages until they are confirmed by the
+// broker. Confirmations are matched by their delivery tag. Returned messages
+// carry no delivery tag and are matched to the oldest pending message with
+// the same routing and body, since the broker returns a message before it
+// confirms it.
+type pendingConfirms struct {
+	byTag map[uint64]*pendingConfirm
+	// order holds the pending messages in the order they were published.
+	// Confirmed messages are removed lazily, when they reach the head or
+	// when they make up most of the queue.
+	order []*pendingConfirm
+}
+
+// Len returns the number of pending messages
+func (s *pendingConfirms) Len() int {
+	return len(s.byTag)
+}
+
+func (s *pendingConfirms) add(tag uint64, message *PublishMessage) {
+	if s.byTag == nil {
+		s.byTag = map[uint64]*pendingConfirm{}
+	}
+	p := &pendingConfirm{tag: tag, message: message, sent: time.Now()}
+	s.byTag[tag] = p
+	s.order = append(s.order, p)
+}
+
+// returned records the returned message of publishErr for the matching
+// pending message, which is set as publishErr.Message. Returns false if no
+// pending message matches.
+func (s *pendingConfirms) returned(publishErr *PublishError) bool {
+	returned := publishErr.ReturnedMessage
+	for _, p := range s.order {
+		m := p.message
+		if !p.confirmed && p.returnErr == nil &&
+			m.Routing.Exchange() == returned.Exchange &&
+			m.Routing.Key() == returned.RoutingKey &&
+			bytes.Equal(m.Publishing.Body, returned.Body) {
+			publishErr.Message = m
+			p.returnErr = publishErr
+			return true
+		}
+	}
+	return false
+}
+
+// confirmed removes and returns the pending message with the given delivery
+// tag.
+func (s *pendingConfirms) confirmed(tag uint64) (*pendingConfirm, bool) {
+	p, ok := s.byTag[tag]
+	if !ok {
+		return nil, false
+	}
+	delete(s.byTag, tag)
+	p.confirmed = true
+	s.compact()
+	return p, true
+}
+
+// compact removes confirmed messages from the head of the order queue, or
+// from the whole queue, when most of its messages are confirmed because
+// the oldest message is still pending. Amortized, this is O(1) per message.
+func (s *pendingConfirms) compact() {
+	for len(s.order) > 0 && s.order[0].confirmed {
+		s.order[0] = nil
+		s.order = s.order[1:]
+	}
+	if len(s.order) > 2*len(s.byTag)+16 {
+		pending := make([]*pendingConfirm, 0, len(s.byTag))
+		for _, p := range s.order {
+			if !p.confirmed {
+				pending = append(pending, p)
+			}
+		}
+		s.order = pending
+	}
+}
+
+// oldest returns the pending message published first
+func (s *pendingConfirms) oldest() (*pendingConfirm, bool) {
+	for _, p := range s.order {
+		if !p.confirmed {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// expired removes and returns the pending messages published before the
+// given time, in the order they were published.
+func (s *pendingConfirms) expired(before time.Time) []*pendingConfirm {
+	var expired []*pendingConfirm
+	for _, p := range s.order {
+		if !p.sent.Before(before) {
+			break
+		}
+		if !p.confirmed {
+			delete(s.byTag, p.tag)
+			p.confirmed = true
+			expired = append(expired, p)
+		}
+	}
+	s.compact()
+	return expired
+}
+
+// removeAll removes and returns all pending messages in the order they were
+// published.
+func (s *pendingConfirms) removeAll() []*pendingConfirm {
+	all := make([]*pendingConfirm, 0, len(s.byTag))
+	for _, p := range s.order {
+		if !p.confirmed {
+			all = append(all, p)
+		}
+	}
+	s.byTag, s.order = nil, nil
+	return all
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPublishMessage(key, body string) *PublishMessage {
+	return &PublishMessage{
+		Routing:    NewRouting("exchange", key, nil),
+		Publishing: &amqp.Publishing{Body: []byte(body)},
+	}
+}
+
+func TestPendingConfirmsMatchesConfirmationsByDeliveryTag(t *testing.T) {
+	pending := &pendingConfirms{}
+	m1, m2 := testPublishMessage("k", "1"), testPublishMessage("k", "2")
+	pending.add(1, m1)
+	pending.add(2, m2)
+
+	p, ok := pending.confirmed(2)
+	require.True(t, ok)
+	assert.Same(t, m2, p.message)
+	assert.Equal(t, 1, pending.Len())
+
+	_, ok = pending.confirmed(2)
+	assert.False(t, ok)
+
+	p, ok = pending.confirmed(1)
+	require.True(t, ok)
+	assert.Same(t, m1, p.message)
+	assert.Equal(t, 0, pending.Len())
+}
+
+func TestPendingConfirmsMatchesReturnedMessageToOldestPendingMessage(t *testing.T) {
+	pending := &pendingConfirms{}
+	m1, m2, m3 := testPublishMessage("k", "a"), testPublishMessage("k", "b"), testPublishMessage("k", "b")
+	pending.add(1, m1)
+	pending.add(2, m2)
+	pending.add(3, m3)
+
+	returned := &PublishError{Reason: PublishErrorReturned,
+		ReturnedMessage: &amqp.Return{Exchange: "exchange", RoutingKey: "k", Body: []byte("b")}}
+	require.True(t, pending.returned(returned))
+	assert.Same(t, m2, returned.Message)
+
+	p, _ := pending.confirmed(2)
+	assert.Same(t, returned, p.returnErr)
+	p, _ = pending.confirmed(3)
+	assert.Nil(t, p.returnErr)
+}
+
+func TestPendingConfirmsIgnoresUnknownReturnedMessage(t *testing.T) {
+	pending := &pendingConfirms{}
+	pending.add(1, testPublishMessage("k", "a"))
+
+	returned := &PublishError{Reason: PublishErrorReturned,
+		ReturnedMessage: &amqp.Return{Exchange: "exchange", RoutingKey: "other", Body: []byte("a")}}
+	assert.False(t, pending.returned(returned))
+	assert.Nil(t, returned.Message)
+}
+
+func TestPendingConfirmsRemoveAllReturnsAllPendingMessages(t *testing.T) {
+	pending := &pendingConfirms{}
+	pending.add(1, testPublishMessage("k", "a"))
+	pending.add(2, testPublishMessage("k", "b"))
+
+	assert.Len(t, pending.removeAll(), 2)
+	assert.Equal(t, 0, pending.Len())
+}
+
+func TestPendingConfirmsRemoveAllKeepsOrderOfUnconfirmedMessages(t *testing.T) {
+	pending := &pendingConfirms{}
+	for tag := uint64(1); tag <= 100; tag++ {
+		pending.add(tag, testPublishMessage("k", "a"))
+	}
+	// confirm all but the first and the last message out of order
+	for tag := uint64(99); tag >= 2; tag-- {
+		_, ok := pending.confirmed(tag)
+		require.True(t, ok)
+	}
+	assert.Equal(t, 2, pending.Len())
+	assert.LessOrEqual(t, len(pending.order), 2*2+16)
+
+	all := pending.removeAll()
+	require.Len(t, all, 2)
+	assert.Equal(t, uint64(1), all[0].tag)
+	assert.Equal(t, uint64(100), all[1].tag)
+}
+
+func TestPendingConfirmsExpiredRemovesOnlyMessagesPublishedBeforeDeadline(t *testing.T) {
+	pending := &pendingConfirms{}
+	start := time.Now()
+	for tag := uint64(1); tag <= 3; tag++ {
+		pending.add(tag, testPublishMessage("k", "a"))
+		pending.byTag[tag].sent = start.Add(time.Duration(tag) * time.Second)
+	}
+	_, ok := pending.confirmed(1)
+	require.True(t, ok)
+
+	expired := pending.expired(start.Add(3 * time.Second))
+
+	require.Len(t, expired, 1)
+	assert.Equal(t, uint64(2), expired[0].tag)
+	oldest, ok := pending.oldest()
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), oldest.tag)
+	_, ok = pending.confirmed(3)
+	assert.True(t, ok)
+	_, ok = pending.oldest()
+	assert.False(t, ok)
+}
+
+func TestPublishErrorIncludesPositionOfMessage(t *testing.T) {
+	message := testPublishMessage("k", "a")
+	message.Position = 42
+
+	err := &PublishError{Reason: PublishErrorNack, Message: message}
+
+	assert.Equal(t, "publish of message #42 to exchange: 'exchange', routingkey: 'k' failed: NACK", err.Error())
+}
@@ -13,6 +13,7 @@ import (
 	"crypto/tls"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/jandelgado/rabtap/pkg/testcommon"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -29,9 +30,8 @@ func TestIntegrationAmqpPublishDirectExchange(t *testing.T) {
 	defer conn.Close()
 
 	logger := slog.New(slog.DiscardHandler)
-	mandatory := true
-	confirms := true
-	publisher := NewAmqpPublish(testcommon.IntegrationURIFromEnv(), &tls.Config{}, mandatory, confirms, logger)
+	config := AmqpPublishConfig{Mandatory: true, Confirms: true}
+	publisher := NewAmqpPublish(config, testcommon.IntegrationURIFromEnv(), &tls.Config{}, logger)
 	publishChannel := make(PublishChannel)
 	errorChannel := make(PublishErrorChannel)
 	ctx := context.Background()
@@ -54,3 +54,73 @@ func TestIntegrationAmqpPublishDirectExchange(t *testing.T) {
 	numReceivedOriginal := <-doneChan
 	assert.Equal(t, numPublishingMessages, numReceivedOriginal)
 }
+
+func TestIntegrationAmqpPublishReportsResultOfEachMessage(t *testing.T) {
+	// creates exchange "direct-exchange" and queues "queue-0" and "queue-1"
+	conn, _ := testcommon.IntegrationTestConnection(t, "direct-exchange", "direct", 2, false)
+	defer conn.Close()
+
+	logger := slog.New(slog.DiscardHandler)
+	config := AmqpPublishConfig{Mandatory: true, Confirms: true, ConfirmWindow: 10}
+	publisher := NewAmqpPublish(config, testcommon.IntegrationURIFromEnv(), &tls.Config{}, logger)
+	publishChannel := make(PublishChannel)
+	errorChannel := make(PublishErrorChannel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go publisher.EstablishConnection(ctx, publishChannel, errorChannel)
+	go func() {
+		for range errorChannel {
+		}
+	}()
+
+	publish := func(key string) error {
+		done := make(chan error, 1)
+		publishChannel <- &PublishMessage{
+			Routing:    NewRouting("direct-exchange", key, amqp.Table{}),
+			Publishing: &amqp.Publishing{Body: []byte("Hello")},
+			Done:       func(err error) { done <- err },
+		}
+		return <-done
+	}
+
+	assert.NoError(t, publish("queue-1"))
+
+	err := publish("unroutable")
+	var publishErr *PublishError
+	assert.ErrorAs(t, err, &publishErr)
+	assert.Equal(t, PublishErrorReturned, publishErr.Reason)
+	assert.Equal(t, "unroutable", publishErr.Message.Routing.Key())
+}
+
+func TestIntegrationAmqpPublishRetriesFailedMessages(t *testing.T) {
+	// creates exchange "direct-exchange" and queues "queue-0" and "queue-1"
+	conn, _ := testcommon.IntegrationTestConnection(t, "direct-exchange", "direct", 2, false)
+	defer conn.Close()
+
+	logger := slog.New(slog.DiscardHandler)
+	config := AmqpPublishConfig{Mandatory: true, Confirms: true, Retries: 2, RetryBackoff: 10 * time.Millisecond}
+	publisher := NewAmqpPublish(config, testcommon.IntegrationURIFromEnv(), &tls.Config{}, logger)
+	publishChannel := make(PublishChannel)
+	errorChannel := make(PublishErrorChannel, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go publisher.EstablishConnection(ctx, publishChannel, errorChannel)
+
+	done := make(chan error, 1)
+	message := &PublishMessage{
+		Routing:    NewRouting("direct-exchange", "unroutable", amqp.Table{}),
+		Publishing: &amqp.Publishing{Body: []byte("Hello")},
+		Done:       func(err error) { done <- err },
+	}
+	publishChannel <- message
+
+	err := <-done
+	var publishErr *PublishError
+	assert.ErrorAs(t, err, &publishErr)
+	assert.Equal(t, PublishErrorReturned, publishErr.Reason)
+	assert.Equal(t, 2, message.retries)
+	// the error is reported only once, after all retries failed
+	assert.Len(t, errorChannel, 1)
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublishingChannel records the published messages. Messages are neither
+// confirmed nor returned, unless done by the test.
+type fakePublishingChannel struct {
+	mu        sync.Mutex
+	published []amqp.Publishing
+	seqNo     uint64
+	closeCh   chan *amqp.Error
+	confirmCh chan amqp.Confirmation
+}
+
+func (s *fakePublishingChannel) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	s.closeCh = c
+	return c
+}
+
+func (s *fakePublishingChannel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	return c
+}
+
+func (s *fakePublishingChannel) NotifyPublish(c chan amqp.Confirmation) chan amqp.Confirmation {
+	s.confirmCh = c
+	return c
+}
+
+func (s *fakePublishingChannel) Confirm(bool) error { return nil }
+
+func (s *fakePublishingChannel) GetNextPublishSeqNo() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seqNo + 1
+}
+
+func (s *fakePublishingChannel) PublishWithContext(_ context.Context, _, _ string,
+	_, _ bool, msg amqp.Publishing,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqNo++
+	s.published = append(s.published, msg)
+	return nil
+}
+
+func (s *fakePublishingChannel) numPublished() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.published)
+}
+
+// publishOnClosingChannel publishes two messages on a channel, which is then
+// closed with closeErr before the messages are confirmed. Returns the
+// messages.
+func publishOnClosingChannel(t *testing.T, publisher *AmqpPublish,
+	errorCh PublishErrorChannel, closeErr *amqp.Error,
+) []*PublishMessage {
+	t.Helper()
+	messages := []*PublishMessage{
+		{Routing: NewRouting("exchange", "key", nil), Publishing: &amqp.Publishing{Body: []byte("1")}},
+		{Routing: NewRouting("exchange", "key", nil), Publishing: &amqp.Publishing{Body: []byte("2")}},
+	}
+	publishCh := make(PublishChannel)
+	channel := &fakePublishingChannel{}
+	result := make(chan ReconnectAction, 1)
+	go func() {
+		action, _ := publisher.publishSession(context.Background(), channel, publishCh, errorCh)
+		result <- action
+	}()
+	for _, m := range messages {
+		publishCh <- m
+	}
+	require.Eventually(t, func() bool { return channel.numPublished() == 2 }, time.Second, time.Millisecond)
+	channel.closeCh <- closeErr
+	require.Equal(t, doReconnect, <-result)
+	return messages
+}
+
+func TestPublishSessionResendsUnconfirmedMessagesOnceAfterConnectionLoss(t *testing.T) {
+	publisher := NewAmqpPublish(AmqpPublishConfig{Confirms: true, ConfirmWindow: 10},
+		nil, nil, slog.New(slog.DiscardHandler))
+	errorCh := make(PublishErrorChannel, 10)
+
+	messages := publishOnClosingChannel(t, publisher, errorCh,
+		&amqp.Error{Code: amqp.FrameError, Reason: "connection lost"})
+
+	// the new session re-sends the pending messages, which are confirmed
+	publishCh := make(PublishChannel)
+	channel := &fakePublishingChannel{}
+	result := make(chan ReconnectAction, 1)
+	go func() {
+		action, _ := publisher.publishSession(context.Background(), channel, publishCh, errorCh)
+		result <- action
+	}()
+	require.Eventually(t, func() bool { return channel.numPublished() == 2 }, time.Second, time.Millisecond)
+	channel.confirmCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	channel.confirmCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	close(publishCh)
+	require.Equal(t, doNotReconnect, <-result)
+
+	assert.Equal(t, 2, channel.numPublished())
+	for _, m := range messages {
+		assert.Equal(t, 2, m.sent)
+	}
+	assert.Equal(t, PublishStats{Published: 2, Confirmed: 2, Resent: 2}, publisher.Stats())
+	close(errorCh)
+	for err := range errorCh {
+		assert.False(t, err.IsMessageError(), err)
+	}
+}
+
+func TestPublishSessionFailsUnconfirmedMessagesWhenServerClosedChannel(t *testing.T) {
+	publisher := NewAmqpPublish(AmqpPublishConfig{Confirms: true, ConfirmWindow: 10},
+		nil, nil, slog.New(slog.DiscardHandler))
+	errorCh := make(PublishErrorChannel, 10)
+
+	messages := publishOnClosingChannel(t, publisher, errorCh,
+		&amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange", Server: true, Recover: true})
+
+	assert.Equal(t, 0, publisher.journal.Len())
+	assert.Equal(t, int64(2), publisher.Stats().Failed)
+	close(errorCh)
+	var failed []*PublishMessage
+	for err := range errorCh {
+		if err.IsMessageError() {
+			assert.Equal(t, PublishErrorChannelError, err.Reason)
+			failed = append(failed, err.Message)
+		}
+	}
+	assert.Equal(t, messages, failed)
+}
+
+func TestRetryBackoffIsDoubledWithEveryRetryUpToLimit(t *testing.T) {
+	publisher := &AmqpPublish{config: AmqpPublishConfig{RetryBackoff: time.Second}}
+
+	assert.Equal(t, time.Second, publisher.retryBackoff(1))
+	assert.Equal(t, 2*time.Second, publisher.retryBackoff(2))
+	assert.Equal(t, 4*time.Second, publisher.retryBackoff(3))
+	assert.Equal(t, maxRetryBackoff, publisher.retryBackoff(10))
+	assert.Equal(t, maxRetryBackoff, publisher.retryBackoff(100))
+}
+
+func TestRetryBackoffKeepsBackoffGreaterThanLimit(t *testing.T) {
+	publisher := &AmqpPublish{config: AmqpPublishConfig{RetryBackoff: time.Hour}}
+
+	assert.Equal(t, time.Hour, publisher.retryBackoff(1))
+	assert.Equal(t, time.Hour, publisher.retryBackoff(64))
+}
+
+func TestRetryBackoffWithoutBackoffIsZero(t *testing.T) {
+	publisher := &AmqpPublish{}
+
+	assert.Equal(t, time.Duration(0), publisher.retryBackoff(1000000))
+}
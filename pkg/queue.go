@@ -3,7 +3,12 @@
 
 package rabtap
 
-import amqp "github.com/rabbitmq/amqp091-go"
+import (
+	"context"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
 
 // CreateQueue creates a new queue
 // TODO(JD) get rid of bool types
@@ -32,6 +37,52 @@ func PurgeQueue(session Session, queueName string) (int, error) {
 	return session.QueuePurge(queueName, false /* wait*/)
 }
 
+// QueueMessageCount returns the number of messages ready for delivery in the
+// given queue.
+func QueueMessageCount(session Session, queueName string) (int, error) {
+	queue, err := session.QueueDeclarePassive(queueName,
+		false, false, false, false /* wait */, nil)
+	return queue.Messages, err
+}
+
+// PeekQueue gets up to limit messages from the queue with basic.get and
+// passes them to the peek function, without acknowledging them. When limit is
+// 0, the messages in the queue at the time PeekQueue is called are peeked, so
+// messages published in the meantime do not keep it running. The messages
+// are returned to the queue in their original order when the channel of the
+// session is closed. Returns the number of messages peeked.
+func PeekQueue(ctx context.Context, session Session, queueName string,
+	limit int64, peek func(TapMessage) error,
+) (int64, error) {
+	if limit == 0 {
+		numMessages, err := QueueMessageCount(session, queueName)
+		if err != nil {
+			return 0, err
+		}
+		limit = int64(numMessages)
+	}
+	var count int64
+	for count < limit {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		msg, ok, err := session.Get(queueName, false /* autoAck */)
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			break // queue is empty
+		}
+		count++
+		message := NewTapMessage(&msg, time.Now())
+		message.Queue = queueName
+		if err := peek(message); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
 // BindQueueToExchange binds the given queue to the given exchange.
 func BindQueueToExchange(session Session,
 	queueName, key, exchangeName string, args amqp.Table) error {
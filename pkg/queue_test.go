@@ -11,11 +11,14 @@ package rabtap
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/jandelgado/rabtap/pkg/testcommon"
 )
@@ -64,6 +67,85 @@ func TestIntegrationAmqpPurgeQueue(t *testing.T) {
 	// TODO remove queue
 }
 
+func TestIntegrationAmqpPeekQueueKeepsMessagesInOrder(t *testing.T) {
+	const queueTestName = "peektestqueue"
+
+	conn, ch := testcommon.IntegrationTestConnection(t, "", "", 0, false)
+	session := Session{conn, ch}
+	defer conn.Close()
+	require.NoError(t, CreateQueue(session, queueTestName, false, false, false, nil))
+	defer func() { _ = RemoveQueue(session, queueTestName, false, false) }()
+
+	for i := range 3 {
+		err := ch.PublishWithContext(context.TODO(), "", queueTestName, false, false,
+			amqp.Publishing{Body: []byte(fmt.Sprintf("msg-%d", i))})
+		require.NoError(t, err)
+	}
+
+	// peek first 2 messages on a separate channel, which is then closed
+	peekCh, err := conn.Channel()
+	require.NoError(t, err)
+	var peeked []string
+	num, err := PeekQueue(context.TODO(), Session{conn, peekCh}, queueTestName, 2,
+		func(m TapMessage) error {
+			assert.Equal(t, queueTestName, m.Queue)
+			peeked = append(peeked, string(m.AmqpMessage.Body))
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), num)
+	assert.Equal(t, []string{"msg-0", "msg-1"}, peeked)
+	require.NoError(t, peekCh.Close())
+
+	// all messages are still in the queue in the original order
+	var all []string
+	num, err = PeekQueue(context.TODO(), session, queueTestName, 0,
+		func(m TapMessage) error {
+			all = append(all, string(m.AmqpMessage.Body))
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), num)
+	assert.Equal(t, []string{"msg-0", "msg-1", "msg-2"}, all)
+}
+
+func TestIntegrationAmqpPeekQueueWithoutLimitStopsAfterInitialMessages(t *testing.T) {
+	const queueTestName = "peektestqueue-nolimit"
+
+	conn, ch := testcommon.IntegrationTestConnection(t, "", "", 0, false)
+	session := Session{conn, ch}
+	defer conn.Close()
+	require.NoError(t, CreateQueue(session, queueTestName, false, false, false, nil))
+	defer func() { _ = RemoveQueue(session, queueTestName, false, false) }()
+
+	publish := func(body string) {
+		err := ch.PublishWithContext(context.TODO(), "", queueTestName, false, false,
+			amqp.Publishing{Body: []byte(body)})
+		require.NoError(t, err)
+	}
+	publish("msg-0")
+	publish("msg-1")
+	require.Eventually(t, func() bool {
+		num, err := QueueMessageCount(session, queueTestName)
+		return err == nil && num == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// messages published while peeking are not peeked
+	peekCh, err := conn.Channel()
+	require.NoError(t, err)
+	defer peekCh.Close()
+	var peeked []string
+	num, err := PeekQueue(context.TODO(), Session{conn, peekCh}, queueTestName, 0,
+		func(m TapMessage) error {
+			peeked = append(peeked, string(m.AmqpMessage.Body))
+			publish("new")
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), num)
+	assert.Equal(t, []string{"msg-0", "msg-1"}, peeked)
+}
+
 func TestIntegrationAmqpQueueCreateBindUnbindAndRemove(t *testing.T) {
 	// since in order to remove and unbind a  queue we must create it first, we
 	// tests these functions together in one test case.
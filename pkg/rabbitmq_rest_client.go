@@ -4,14 +4,17 @@
 package rabtap
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"golang.org/x/net/context/ctxhttp"
@@ -101,6 +104,31 @@ func (s *RabbitHTTPClient) delResource(ctx context.Context, path string) error {
 	return nil
 }
 
+// putResource make PUT request with the given body, encoded as JSON, to the
+// given relative path
+func (s *RabbitHTTPClient) putResource(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := s.url.String() + "/" + path
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ctxhttp.Do(ctx, s.client, req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != 200 && resp.StatusCode != 201 && resp.StatusCode != 204 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
 // BrokerInfo represents the state of various RabbitMQ ressources as
 // returned by the RabbitMQ REST API
 type BrokerInfo struct {
@@ -185,6 +213,48 @@ func (s *RabbitHTTPClient) CloseConnection(ctx context.Context, conn, reason str
 	return s.delResource(ctx, "connections/"+conn)
 }
 
+// DeleteExchange deletes an exchange by DELETEing the exchanges/vhost/name
+// resource
+func (s *RabbitHTTPClient) DeleteExchange(ctx context.Context, vhost, name string) error {
+	return s.delResource(ctx, "exchanges/"+url.PathEscape(vhost)+"/"+url.PathEscape(name))
+}
+
+// DeleteQueue deletes a queue by DELETEing the queues/vhost/name resource
+func (s *RabbitHTTPClient) DeleteQueue(ctx context.Context, vhost, name string) error {
+	return s.delResource(ctx, "queues/"+url.PathEscape(vhost)+"/"+url.PathEscape(name))
+}
+
+// vhostSettings are the attributes of a vhost, which are kept when the
+// vhosts/vhost resource is PUT
+var vhostSettings = []string{"description", "tags", "default_queue_type", "metadata", "protected_from_deletion"}
+
+// SetVhostTracing enables or disables the FireHose tracer on the given vhost.
+// Since PUTting the vhosts/vhost resource replaces the vhost on some brokers,
+// the vhost is read first and PUT with only the tracing attribute changed.
+func (s *RabbitHTTPClient) SetVhostTracing(ctx context.Context, vhost string, tracing bool) error {
+	path := "vhosts/" + url.PathEscape(vhost)
+	res, err := s.getResource(ctx, httpRequest{path, reflect.TypeOf(map[string]interface{}{})})
+	if err != nil {
+		return err
+	}
+	current := *res.(*map[string]interface{})
+	body := map[string]interface{}{"tracing": tracing}
+	for _, key := range vhostSettings {
+		if value, ok := current[key]; ok && value != nil {
+			body[key] = value
+		}
+	}
+	// tags are returned as list, but expected as comma separated string
+	if tags, ok := body["tags"].([]interface{}); ok {
+		names := make([]string, len(tags))
+		for i, tag := range tags {
+			names[i] = fmt.Sprint(tag)
+		}
+		body["tags"] = strings.Join(names, ",")
+	}
+	return s.putResource(ctx, path, body)
+}
+
 // UnmarshalJSON is a workaround to deserialize int attributes in the
 // RabbitMQ API which are sometimes returned as strings, (i.e. the
 // value "undefined").
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/jandelgado/rabtap/pkg/testcommon"
 )
@@ -326,3 +327,93 @@ func TestRabbitClientCloseNonExistingConnectionRaisesError(t *testing.T) {
 	err := client.CloseConnection(context.TODO(), "DOES NOT EXIST", "reason")
 	assert.NotNil(t, err)
 }
+
+// test of PUT /vhosts/vhost to enable tracing
+func TestRabbitClientSetVhostTracing(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.SetVhostTracing(context.TODO(), "/", true)
+	assert.Nil(t, err)
+}
+
+func TestRabbitClientSetVhostTracingKeepsVhostSettings(t *testing.T) {
+	var put map[string]interface{}
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			_, _ = w.Write([]byte(`{"name":"/","description":"default vhost","tags":["prod","eu"],` +
+				`"default_queue_type":"quorum","tracing":false,"messages":4}`))
+		case "PUT":
+			_ = json.NewDecoder(r.Body).Decode(&put)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.SetVhostTracing(context.TODO(), "/", true)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"description":        "default vhost",
+		"tags":               "prod,eu",
+		"default_queue_type": "quorum",
+		"tracing":            true,
+	}, put)
+}
+
+func TestRabbitClientSetVhostTracingOfNonExistingVhostRaisesError(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.SetVhostTracing(context.TODO(), "DOES NOT EXIST", true)
+	assert.NotNil(t, err)
+}
+
+// test of DELETE /exchanges/vhost/exchange to delete an exchange
+func TestRabbitClientDeleteExchange(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.DeleteExchange(context.TODO(), "/", "test-topic")
+	assert.Nil(t, err)
+}
+
+func TestRabbitClientDeleteNonExistingExchangeRaisesError(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.DeleteExchange(context.TODO(), "/", "DOES NOT EXIST")
+	assert.NotNil(t, err)
+}
+
+// test of DELETE /queues/vhost/queue to delete a queue
+func TestRabbitClientDeleteQueue(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.DeleteQueue(context.TODO(), "/", "topic-q1")
+	assert.Nil(t, err)
+}
+
+func TestRabbitClientDeleteNonExistingQueueRaisesError(t *testing.T) {
+	mock := testcommon.NewRabbitAPIMock(testcommon.MockModeStd)
+	defer mock.Close()
+	url, _ := url.Parse(mock.URL)
+	client := NewRabbitHTTPClient(url, &tls.Config{})
+
+	err := client.DeleteQueue(context.TODO(), "/", "DOES NOT EXIST")
+	assert.NotNil(t, err)
+}
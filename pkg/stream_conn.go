@@ -0,0 +1,397 @@
+// Copyright (C) 2026 Jan Delgado
+// Connection to a RabbitMQ broker using the stream protocol.
+
+package rabtap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultStreamPort      = 5552
+	defaultStreamTLSPort   = 5551
+	defaultStreamFrameMax  = 1048576
+	defaultStreamHeartbeat = 60 * time.Second
+	streamCloseTimeout     = 5 * time.Second
+)
+
+// ParseStreamURL returns the broker address, credentials and vhost to use
+// for a stream protocol connection. URLs with scheme rabbitmq-stream and
+// rabbitmq-stream+tls are used as given. For AMQP URLs (amqp, amqps), the
+// default stream port is used instead of the AMQP port. Returns the parsed
+// URI and true if TLS is to be used.
+func ParseStreamURL(u *url.URL) (amqp.URI, bool, error) {
+	var useTLS, keepPort bool
+	switch u.Scheme {
+	case "rabbitmq-stream":
+		keepPort = true
+	case "rabbitmq-stream+tls":
+		useTLS, keepPort = true, true
+	case "amqp":
+	case "amqps":
+		useTLS = true
+	default:
+		return amqp.URI{}, false, fmt.Errorf("unsupported scheme for stream connection: %q", u.Scheme)
+	}
+
+	amqpURL := *u
+	amqpURL.Scheme = "amqp"
+	if useTLS {
+		amqpURL.Scheme = "amqps"
+	}
+	uri, err := amqp.ParseURI(amqpURL.String())
+	if err != nil {
+		return uri, useTLS, err
+	}
+	if !keepPort || u.Port() == "" {
+		uri.Port = defaultStreamPort
+		if useTLS {
+			uri.Port = defaultStreamTLSPort
+		}
+	}
+	return uri, useTLS, nil
+}
+
+// streamConn is a connection to a RabbitMQ broker using the stream protocol.
+// Responses are dispatched to the pending requests by their correlation id,
+// all other frames sent by the broker (e.g. deliveries) are passed to the
+// events channel.
+type streamConn struct {
+	conn      net.Conn
+	logger    *slog.Logger
+	writeMu   sync.Mutex
+	nextID    atomic.Uint32
+	heartbeat atomic.Int64 // negotiated heartbeat as time.Duration
+
+	mu      sync.Mutex
+	pending map[uint32]chan streamFrame
+
+	tune   chan streamFrame
+	events chan streamFrame
+
+	closeOnce sync.Once
+	done      chan struct{}
+	closeErr  error
+}
+
+// dialStream connects to the broker denoted by u and opens the vhost.
+func dialStream(ctx context.Context, u *url.URL, tlsConfig *tls.Config, logger *slog.Logger) (*streamConn, error) {
+	uri, useTLS, err := ParseStreamURL(u)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))
+	conn, err := Dialer("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if useTLS {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = uri.Host
+		}
+		conn = tls.Client(conn, config)
+	}
+
+	s := newStreamConn(conn, logger)
+	mechanism, opaque := saslForURI(u, uri, tlsConfig)
+	if err := s.handshake(ctx, uri.Vhost, mechanism, opaque); err != nil {
+		s.shutdown(err)
+		return nil, fmt.Errorf("stream connection to %s: %w", addr, err)
+	}
+	return s, nil
+}
+
+func newStreamConn(conn net.Conn, logger *slog.Logger) *streamConn {
+	s := &streamConn{
+		conn:    conn,
+		logger:  logger,
+		pending: map[uint32]chan streamFrame{},
+		tune:    make(chan streamFrame, 1),
+		events:  make(chan streamFrame),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// saslForURI returns the SASL mechanism and opaque data to authenticate
+// with. Like with AMQP, EXTERNAL auth is requested when client certificates
+// are used and no explicit credentials are given.
+func saslForURI(u *url.URL, uri amqp.URI, tlsConfig *tls.Config) (string, []byte) {
+	if tlsConfig != nil && tlsConfig.Certificates != nil && u.User == nil {
+		return "EXTERNAL", []byte{}
+	}
+	return "PLAIN", []byte("\x00" + uri.Username + "\x00" + uri.Password)
+}
+
+// negotiate returns the smaller of the two values, where 0 means unlimited.
+func negotiate(server, client uint32) uint32 {
+	if server == 0 || (client != 0 && client < server) {
+		return client
+	}
+	return server
+}
+
+// handshake authenticates with the given SASL mechanism, negotiates frame
+// size and heartbeat and finally opens the vhost.
+func (s *streamConn) handshake(ctx context.Context, vhost, mechanism string, opaque []byte) error {
+	_, err := s.request(ctx, streamCmdPeerProperties, func(e *streamEncoder) {
+		e.stringMap(map[string]string{"product": "rabtap", "platform": "Go"})
+	})
+	if err != nil {
+		return fmt.Errorf("peer properties: %w", err)
+	}
+
+	resp, err := s.request(ctx, streamCmdSaslHandshake, nil)
+	if err != nil {
+		return fmt.Errorf("sasl handshake: %w", err)
+	}
+	supported := false
+	for _, m := range resp.strings() {
+		supported = supported || m == mechanism
+	}
+	if !supported {
+		return fmt.Errorf("sasl mechanism %s not supported by broker", mechanism)
+	}
+	if _, err = s.request(ctx, streamCmdSaslAuthenticate, func(e *streamEncoder) {
+		e.string(mechanism).bytes(opaque)
+	}); err != nil {
+		return fmt.Errorf("authenticate: %w", err)
+	}
+
+	// after successful authentication, the broker proposes the frame size
+	// and the heartbeat interval.
+	var tune streamFrame
+	select {
+	case tune = <-s.tune:
+	case <-s.done:
+		return s.err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	frameMax := negotiate(tune.content.uint32(), defaultStreamFrameMax)
+	heartbeat := negotiate(tune.content.uint32(), uint32(defaultStreamHeartbeat/time.Second))
+	if err := tune.content.err(); err != nil {
+		return fmt.Errorf("tune: %w", err)
+	}
+	if err := s.write(newStreamEncoder(streamCmdTune, streamProtocolVersion).
+		uint32(frameMax).uint32(heartbeat)); err != nil {
+		return err
+	}
+	s.heartbeat.Store(int64(time.Duration(heartbeat) * time.Second))
+	if heartbeat > 0 {
+		go s.heartbeatLoop(time.Duration(heartbeat) * time.Second)
+	}
+
+	if _, err := s.request(ctx, streamCmdOpen, func(e *streamEncoder) {
+		e.string(vhost)
+	}); err != nil {
+		return fmt.Errorf("open vhost %s: %w", vhost, err)
+	}
+	return nil
+}
+
+func (s *streamConn) heartbeatLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.write(newStreamEncoder(streamCmdHeartbeat, streamProtocolVersion)); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// readLoop reads frames until the connection is closed. When heartbeats are
+// enabled, the connection is considered dead after two missed heartbeats.
+func (s *streamConn) readLoop() {
+	for {
+		if heartbeat := time.Duration(s.heartbeat.Load()); heartbeat > 0 {
+			_ = s.conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		}
+		frame, err := readStreamFrame(s.conn, 0)
+		if err != nil {
+			s.shutdown(err)
+			return
+		}
+
+		switch {
+		case frame.key == streamCmdHeartbeat:
+		case frame.key == streamCmdTune:
+			select {
+			case s.tune <- frame:
+			default:
+			}
+		case frame.key == streamCmdClose:
+			id := frame.content.uint32()
+			code := frame.content.uint16()
+			reason := frame.content.string()
+			_ = s.write(newStreamEncoder(streamCmdClose|streamResponseFlag, streamProtocolVersion).
+				uint32(id).uint16(streamResponseOK))
+			s.shutdown(fmt.Errorf("connection closed by broker: %s (0x%02x)", reason, code))
+			return
+		case frame.isResponse() && frame.key != streamCmdCredit|streamResponseFlag:
+			id := frame.content.uint32()
+			s.mu.Lock()
+			ch := s.pending[id]
+			delete(s.pending, id)
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- frame
+			}
+		default:
+			select {
+			case s.events <- frame:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+var errStreamConnClosed = errors.New("stream connection closed")
+
+// shutdown closes the connection, recording the error which caused it.
+func (s *streamConn) shutdown(err error) {
+	s.closeOnce.Do(func() {
+		if err == nil {
+			err = errStreamConnClosed
+		}
+		s.closeErr = err
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+func (s *streamConn) err() error {
+	<-s.done
+	return s.closeErr
+}
+
+func (s *streamConn) write(e *streamEncoder) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.conn.Write(e.frame())
+	return err
+}
+
+// request sends a request and waits for its response. The content of the
+// response is returned positioned after the response code. A response
+// code other than OK is returned as StreamResponseError.
+func (s *streamConn) request(ctx context.Context, key uint16, build func(*streamEncoder)) (*streamDecoder, error) {
+	id := s.nextID.Add(1)
+	ch := make(chan streamFrame, 1)
+	s.mu.Lock()
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	e := newStreamEncoder(key, streamProtocolVersion).uint32(id)
+	if build != nil {
+		build(e)
+	}
+	if err := s.write(e); err != nil {
+		return nil, err
+	}
+
+	select {
+	case frame := <-ch:
+		code := frame.content.uint16()
+		if err := frame.content.err(); err != nil {
+			return nil, err
+		}
+		if code != streamResponseOK {
+			return frame.content, &StreamResponseError{Key: key, Code: code}
+		}
+		return frame.content, nil
+	case <-s.done:
+		return nil, s.err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// close closes the connection gracefully. Events still sent by the broker
+// are discarded while waiting for the response.
+func (s *streamConn) close() error {
+	go func() {
+		for {
+			select {
+			case <-s.events:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), streamCloseTimeout)
+	defer cancel()
+	_, err := s.request(ctx, streamCmdClose, func(e *streamEncoder) {
+		e.uint16(streamResponseOK).string("closed by rabtap")
+	})
+	s.shutdown(errStreamConnClosed)
+	return err
+}
+
+// subscribe subscribes to a stream. The broker may then send up to credit
+// chunks before further credit is granted.
+func (s *streamConn) subscribe(ctx context.Context, subscriptionID uint8, stream string,
+	offset StreamOffset, credit uint16, properties map[string]string,
+) error {
+	_, err := s.request(ctx, streamCmdSubscribe, func(e *streamEncoder) {
+		e.uint8(subscriptionID).string(stream)
+		offset.encode(e)
+		e.uint16(credit).stringMap(properties)
+	})
+	return err
+}
+
+// credit grants the broker to send further chunks.
+func (s *streamConn) credit(subscriptionID uint8, credit uint16) error {
+	return s.write(newStreamEncoder(streamCmdCredit, streamProtocolVersion).
+		uint8(subscriptionID).uint16(credit))
+}
+
+// storeOffset stores the offset for the given reference on the broker. There
+// is no response to this command.
+func (s *streamConn) storeOffset(reference, stream string, offset uint64) error {
+	return s.write(newStreamEncoder(streamCmdStoreOffset, streamProtocolVersion).
+		string(reference).string(stream).uint64(offset))
+}
+
+// queryOffset returns the offset stored on the broker for the given
+// reference. Returns false if no offset was stored yet.
+func (s *streamConn) queryOffset(ctx context.Context, reference, stream string) (uint64, bool, error) {
+	resp, err := s.request(ctx, streamCmdQueryOffset, func(e *streamEncoder) {
+		e.string(reference).string(stream)
+	})
+	var respErr *StreamResponseError
+	if errors.As(err, &respErr) && respErr.Code == streamResponseNoOffset {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	offset := resp.uint64()
+	return offset, true, resp.err()
+}
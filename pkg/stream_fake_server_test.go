@@ -0,0 +1,287 @@
+// Copyright (C) 2026 Jan Delgado
+// A small in-process fake of a RabbitMQ broker speaking the stream protocol,
+// used to test the StreamSubscriber.
+
+package rabtap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSubscription records a subscribe request received by the fake.
+type fakeSubscription struct {
+	stream     string
+	offsetType StreamOffsetType
+	offset     uint64
+	credit     uint16
+	properties map[string]string
+}
+
+// fakeStreamServer serves a single stream with the given messages, which
+// are delivered in chunks of chunkSize messages.
+type fakeStreamServer struct {
+	t         *testing.T
+	listener  net.Listener
+	stream    string
+	messages  [][]byte
+	chunkSize int
+
+	mu            sync.Mutex
+	conns         []net.Conn
+	subscriptions []fakeSubscription
+	storedOffsets map[string]uint64
+}
+
+func newFakeStreamServer(t *testing.T, stream string, messages [][]byte, chunkSize int) *fakeStreamServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeStreamServer{
+		t:             t,
+		listener:      listener,
+		stream:        stream,
+		messages:      messages,
+		chunkSize:     chunkSize,
+		storedOffsets: map[string]uint64{},
+	}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *fakeStreamServer) url(userinfo string) *url.URL {
+	u, _ := url.Parse("rabbitmq-stream://" + userinfo + "@" + s.listener.Addr().String() + "/")
+	return u
+}
+
+func (s *fakeStreamServer) close() {
+	_ = s.listener.Close()
+	s.dropConnections()
+}
+
+// dropConnections closes all client connections, simulating a network
+// failure.
+func (s *fakeStreamServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeStreamServer) getSubscriptions() []fakeSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSubscription{}, s.subscriptions...)
+}
+
+func (s *fakeStreamServer) storedOffset(reference string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset, ok := s.storedOffsets[reference]
+	return offset, ok
+}
+
+func (s *fakeStreamServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// handle serves a client connection. Chunks are sent as long as the client
+// has credit.
+func (s *fakeStreamServer) handle(conn net.Conn) {
+	defer conn.Close()
+	write := func(e *streamEncoder) { _, _ = conn.Write(e.frame()) }
+	respond := func(key uint16, id uint32, code uint16) *streamEncoder {
+		return newStreamEncoder(key|streamResponseFlag, streamProtocolVersion).uint32(id).uint16(code)
+	}
+
+	var credit uint16
+	nextChunk := -1
+	sendChunks := func() {
+		for ; nextChunk >= 0 && credit > 0 && nextChunk*s.chunkSize < len(s.messages); nextChunk++ {
+			first := nextChunk * s.chunkSize
+			last := min(first+s.chunkSize, len(s.messages))
+			write(newStreamEncoder(streamCmdDeliver, streamProtocolVersion).
+				uint8(streamSubscriptionID).
+				raw(encodeTestChunk(uint64(first), s.messages[first:last])))
+			credit--
+		}
+	}
+
+	for {
+		frame, err := readStreamFrame(conn, 0)
+		if err != nil {
+			return
+		}
+		d := frame.content
+		switch frame.key {
+		case streamCmdPeerProperties:
+			write(respond(frame.key, d.uint32(), streamResponseOK).stringMap(nil))
+		case streamCmdSaslHandshake:
+			write(respond(frame.key, d.uint32(), streamResponseOK).strings([]string{"PLAIN"}))
+		case streamCmdSaslAuthenticate:
+			id := d.uint32()
+			_ = d.string() // mechanism
+			if string(d.bytes()) != "\x00guest\x00guest" {
+				write(respond(frame.key, id, 0x08))
+				continue
+			}
+			write(respond(frame.key, id, streamResponseOK))
+			write(newStreamEncoder(streamCmdTune, streamProtocolVersion).uint32(131072).uint32(60))
+		case streamCmdOpen:
+			write(respond(frame.key, d.uint32(), streamResponseOK).stringMap(nil))
+		case streamCmdSubscribe:
+			id := d.uint32()
+			_ = d.uint8()
+			sub := fakeSubscription{stream: d.string(), offsetType: StreamOffsetType(d.uint16())}
+			switch sub.offsetType {
+			case StreamOffsetAbsolute, StreamOffsetTimestamp:
+				sub.offset = d.uint64()
+			}
+			sub.credit = d.uint16()
+			sub.properties = d.stringMap()
+			s.mu.Lock()
+			s.subscriptions = append(s.subscriptions, sub)
+			s.mu.Unlock()
+			if sub.stream != s.stream {
+				write(respond(frame.key, id, streamResponseStreamDoesNotExist))
+				continue
+			}
+			write(respond(frame.key, id, streamResponseOK))
+			credit = sub.credit
+			switch sub.offsetType {
+			case StreamOffsetAbsolute:
+				nextChunk = int(sub.offset) / s.chunkSize
+			case StreamOffsetNext, StreamOffsetLast:
+				nextChunk = len(s.messages) / s.chunkSize
+			default:
+				nextChunk = 0
+			}
+			sendChunks()
+		case streamCmdCredit:
+			_ = d.uint8()
+			credit += d.uint16()
+			sendChunks()
+		case streamCmdStoreOffset:
+			reference := d.string()
+			_ = d.string() // stream
+			offset := d.uint64()
+			s.mu.Lock()
+			s.storedOffsets[reference] = offset
+			s.mu.Unlock()
+		case streamCmdQueryOffset:
+			id := d.uint32()
+			offset, ok := s.storedOffset(d.string())
+			if !ok {
+				write(respond(frame.key, id, streamResponseNoOffset).uint64(0))
+				continue
+			}
+			write(respond(frame.key, id, streamResponseOK).uint64(offset))
+		case streamCmdClose:
+			write(respond(frame.key, d.uint32(), streamResponseOK))
+			return
+		case streamCmdTune, streamCmdHeartbeat:
+		default:
+			s.t.Errorf("fake stream server: unexpected frame 0x%04x", frame.key)
+		}
+	}
+}
+
+// encodeTestChunk encodes the given records as chunk of simple entries.
+func encodeTestChunk(firstOffset uint64, records [][]byte) []byte {
+	data := &streamEncoder{}
+	for _, r := range records {
+		data.bytes(r)
+	}
+	return encodeTestChunkData(firstOffset, uint16(len(records)), uint32(len(records)), data.buf.Bytes())
+}
+
+func encodeTestChunkData(firstOffset uint64, numEntries uint16, numRecords uint32, data []byte) []byte {
+	e := &streamEncoder{}
+	e.uint8(streamChunkMagicVersion).
+		uint8(streamChunkTypeUser).
+		uint16(numEntries).
+		uint32(numRecords).
+		uint64(uint64(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli())).
+		uint64(1). // epoch
+		uint64(firstOffset).
+		uint32(0). // crc
+		uint32(uint32(len(data))).
+		uint32(0). // trailer length
+		raw([]byte{0, 0, 0, 0}).
+		raw(data)
+	return e.buf.Bytes()
+}
+
+// helpers to encode AMQP 1.0 values in tests
+
+func amqp10TestStr(s string) []byte {
+	return append([]byte{0xa1, byte(len(s))}, s...)
+}
+
+func amqp10TestSym(s string) []byte {
+	return append([]byte{0xa3, byte(len(s))}, s...)
+}
+
+func amqp10TestBin(b []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{0xb0}, uint32(len(b))), b...)
+}
+
+func amqp10TestList(elems ...[]byte) []byte {
+	body := bytes.Join(elems, nil)
+	res := binary.BigEndian.AppendUint32([]byte{0xd0}, uint32(len(body)+4))
+	res = binary.BigEndian.AppendUint32(res, uint32(len(elems)))
+	return append(res, body...)
+}
+
+func amqp10TestMap(m map[string][]byte, key func(string) []byte) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var elems [][]byte
+	for _, k := range keys {
+		elems = append(elems, key(k), m[k])
+	}
+	body := bytes.Join(elems, nil)
+	res := binary.BigEndian.AppendUint32([]byte{0xd1}, uint32(len(body)+4))
+	res = binary.BigEndian.AppendUint32(res, uint32(len(elems)))
+	return append(res, body...)
+}
+
+func amqp10TestSection(descriptor byte, value []byte) []byte {
+	return append([]byte{0x00, 0x53, descriptor}, value...)
+}
+
+// encodeTestAmqp10Message encodes a message with the given message id,
+// application properties and body.
+func encodeTestAmqp10Message(messageID string, appProps map[string][]byte, body []byte) []byte {
+	var msg []byte
+	msg = append(msg, amqp10TestSection(amqp10SectionProperties,
+		amqp10TestList(amqp10TestStr(messageID), []byte{0x40}, []byte{0x40}, []byte{0x40},
+			[]byte{0x40}, []byte{0x40}, amqp10TestSym("text/plain")))...)
+	if appProps != nil {
+		msg = append(msg, amqp10TestSection(amqp10SectionApplicationProperties,
+			amqp10TestMap(appProps, amqp10TestStr))...)
+	}
+	return append(msg, amqp10TestSection(amqp10SectionData, amqp10TestBin(body))...)
+}
@@ -0,0 +1,504 @@
+// Copyright (C) 2026 Jan Delgado
+// Decoding of stream chunks and of the AMQP 1.0 encoded messages they
+// contain. Messages are converted to amqp.Delivery objects, so they can be
+// processed like messages received using AMQP 0.9.1.
+
+package rabtap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	streamChunkMagicVersion = 0x50
+	streamChunkTypeUser     = 0
+)
+
+// compression types of sub-batch entries
+const (
+	streamCompressionNone = 0
+	streamCompressionGzip = 1
+	streamCompressionZstd = 4
+)
+
+// streamChunk is a decoded chunk of a stream, as delivered by the broker.
+type streamChunk struct {
+	chunkType   uint8
+	timestamp   time.Time
+	firstOffset uint64
+	records     [][]byte // AMQP 1.0 encoded messages
+}
+
+// decodeStreamChunk decodes an osiris chunk. Sub-batch entries are
+// decompressed if they use gzip or zstd compression.
+func decodeStreamChunk(d *streamDecoder) (streamChunk, error) {
+	magicVersion := d.uint8()
+	chunk := streamChunk{chunkType: d.uint8()}
+	numEntries := d.uint16()
+	numRecords := d.uint32()
+	chunk.timestamp = time.UnixMilli(int64(d.uint64()))
+	_ = d.uint64() // epoch
+	chunk.firstOffset = d.uint64()
+	_ = d.uint32() // crc
+	dataLength := d.uint32()
+	_ = d.uint32() // trailer length
+	_ = d.next(4)  // bloom filter size and reserved bytes
+	data := newStreamDecoder(d.next(int(dataLength)))
+	if err := d.err(); err != nil {
+		return chunk, fmt.Errorf("decode chunk header: %w", err)
+	}
+	if magicVersion != streamChunkMagicVersion {
+		return chunk, fmt.Errorf("unsupported chunk format 0x%02x", magicVersion)
+	}
+
+	for i := 0; i < int(numEntries) && data.remaining() > 0; i++ {
+		entryType := data.data[0]
+		if entryType&0x80 == 0 {
+			chunk.records = append(chunk.records, data.bytes())
+			continue
+		}
+		_ = data.uint8()
+		numBatchRecords := int(data.uint16())
+		_ = data.uint32() // uncompressed length
+		batch, err := decompressStreamBatch((entryType&0x70)>>4, data.bytes())
+		if err != nil {
+			return chunk, err
+		}
+		batchDecoder := newStreamDecoder(batch)
+		for j := 0; j < numBatchRecords; j++ {
+			chunk.records = append(chunk.records, batchDecoder.bytes())
+		}
+		if err := batchDecoder.err(); err != nil {
+			return chunk, fmt.Errorf("decode sub-batch entry: %w", err)
+		}
+	}
+	if err := data.err(); err != nil {
+		return chunk, fmt.Errorf("decode chunk entries: %w", err)
+	}
+	if len(chunk.records) != int(numRecords) {
+		return chunk, fmt.Errorf("chunk contains %d records, expected %d", len(chunk.records), numRecords)
+	}
+	return chunk, nil
+}
+
+func decompressStreamBatch(compression uint8, data []byte) ([]byte, error) {
+	switch compression {
+	case streamCompressionNone:
+		return data, nil
+	case streamCompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case streamCompressionZstd:
+		r, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported sub-batch compression %d", compression)
+	}
+}
+
+// AMQP 1.0 message section descriptors
+const (
+	amqp10SectionHeader                     = 0x70
+	amqp10SectionDeliveryAnnotations        = 0x71
+	amqp10SectionMessageAnnotations         = 0x72
+	amqp10SectionProperties                 = 0x73
+	amqp10SectionApplicationProperties      = 0x74
+	amqp10SectionData                       = 0x75
+	amqp10SectionAmqpSequence               = 0x76
+	amqp10SectionAmqpValue                  = 0x77
+	amqp10SectionFooter                     = 0x78
+	amqp10DescribedTypeConstructor     byte = 0x00
+)
+
+// amqp10Symbol is an AMQP 1.0 symbol, which is kept apart from strings so
+// that symbolic descriptors can be distinguished.
+type amqp10Symbol string
+
+// amqp10Described is a described value with a descriptor not known to the
+// decoder.
+type amqp10Described struct {
+	Descriptor any
+	Value      any
+}
+
+var errAmqp10Decode = errors.New("invalid AMQP 1.0 encoding")
+
+// amqp10Decoder decodes values of the AMQP 1.0 type system.
+type amqp10Decoder struct {
+	*streamDecoder
+}
+
+func (d amqp10Decoder) fail(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errAmqp10Decode, fmt.Sprintf(format, args...))
+}
+
+// value decodes the next value.
+func (d amqp10Decoder) value() (any, error) {
+	code := d.uint8()
+	if err := d.err(); err != nil {
+		return nil, err
+	}
+	if code == amqp10DescribedTypeConstructor {
+		descriptor, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		return amqp10Described{Descriptor: descriptor, Value: value}, nil
+	}
+	return d.valueOfType(code)
+}
+
+func (d amqp10Decoder) valueOfType(code byte) (any, error) {
+	var v any
+	switch code {
+	case 0x40:
+		v = nil
+	case 0x41:
+		v = true
+	case 0x42:
+		v = false
+	case 0x56:
+		v = d.uint8() != 0
+	case 0x43:
+		v = uint32(0)
+	case 0x44:
+		v = uint64(0)
+	case 0x50:
+		v = d.uint8()
+	case 0x51:
+		v = int8(d.uint8())
+	case 0x52:
+		v = uint32(d.uint8())
+	case 0x53:
+		v = uint64(d.uint8())
+	case 0x54:
+		v = int32(int8(d.uint8()))
+	case 0x55:
+		v = int64(int8(d.uint8()))
+	case 0x60:
+		v = d.uint16()
+	case 0x61:
+		v = int16(d.uint16())
+	case 0x70:
+		v = d.uint32()
+	case 0x71:
+		v = int32(d.uint32())
+	case 0x72:
+		v = math.Float32frombits(d.uint32())
+	case 0x73:
+		v = string(rune(d.uint32()))
+	case 0x80:
+		v = d.uint64()
+	case 0x81:
+		v = int64(d.uint64())
+	case 0x82:
+		v = math.Float64frombits(d.uint64())
+	case 0x83:
+		v = time.UnixMilli(int64(d.uint64())).UTC()
+	case 0x74:
+		v = d.next(4) // decimal32, kept as raw bytes
+	case 0x84:
+		v = d.next(8) // decimal64
+	case 0x94:
+		v = d.next(16) // decimal128
+	case 0x98:
+		v = formatUUID(d.next(16))
+	case 0xa0:
+		v = d.next(int(d.uint8()))
+	case 0xb0:
+		v = d.next(int(d.uint32()))
+	case 0xa1:
+		v = string(d.next(int(d.uint8())))
+	case 0xb1:
+		v = string(d.next(int(d.uint32())))
+	case 0xa3:
+		v = amqp10Symbol(d.next(int(d.uint8())))
+	case 0xb3:
+		v = amqp10Symbol(d.next(int(d.uint32())))
+	case 0x45:
+		v = []any{}
+	case 0xc0:
+		_ = d.uint8() // size
+		return d.list(int(d.uint8()))
+	case 0xd0:
+		_ = d.uint32() // size
+		return d.list(int(d.uint32()))
+	case 0xc1:
+		_ = d.uint8() // size
+		return d.amqpMap(int(d.uint8()))
+	case 0xd1:
+		_ = d.uint32() // size
+		return d.amqpMap(int(d.uint32()))
+	case 0xe0:
+		_ = d.uint8() // size
+		return d.array(int(d.uint8()))
+	case 0xf0:
+		_ = d.uint32() // size
+		return d.array(int(d.uint32()))
+	default:
+		return nil, d.fail("unknown type code 0x%02x", code)
+	}
+	return v, d.err()
+}
+
+func (d amqp10Decoder) list(count int) (any, error) {
+	res := make([]any, 0, min(count, d.remaining()))
+	for i := 0; i < count; i++ {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (d amqp10Decoder) amqpMap(count int) (any, error) {
+	if count%2 != 0 {
+		return nil, d.fail("map with odd number of elements")
+	}
+	res := make(map[any]any, min(count/2, d.remaining()))
+	for i := 0; i < count; i += 2 {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		res[amqp10MapKey(k)] = v
+	}
+	return res, nil
+}
+
+func (d amqp10Decoder) array(count int) (any, error) {
+	code := d.uint8()
+	if code == amqp10DescribedTypeConstructor {
+		if _, err := d.value(); err != nil {
+			return nil, err
+		}
+		code = d.uint8()
+	}
+	res := make([]any, 0, min(count, d.remaining()))
+	for i := 0; i < count; i++ {
+		// in arrays, the elements share the constructor
+		v, err := d.valueOfType(code)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// amqp10MapKey makes sure map keys are comparable
+func amqp10MapKey(k any) any {
+	switch key := k.(type) {
+	case []byte:
+		return string(key)
+	case []any, map[any]any, amqp10Described:
+		return fmt.Sprint(key)
+	}
+	return k
+}
+
+func formatUUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// amqp10ToTableValue converts a decoded AMQP 1.0 value to a value which can
+// be stored in an amqp.Table.
+func amqp10ToTableValue(v any) any {
+	switch val := v.(type) {
+	case amqp10Symbol:
+		return string(val)
+	case uint8:
+		return int16(val)
+	case uint16:
+		return int32(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		if val > math.MaxInt64 {
+			return strconv.FormatUint(val, 10)
+		}
+		return int64(val)
+	case []any:
+		res := make([]any, len(val))
+		for i, e := range val {
+			res[i] = amqp10ToTableValue(e)
+		}
+		return res
+	case map[any]any:
+		return amqp10ToTable(val)
+	case amqp10Described:
+		return amqp10ToTableValue(val.Value)
+	}
+	return v
+}
+
+func amqp10ToTable(m map[any]any) amqp.Table {
+	res := amqp.Table{}
+	for k, v := range m {
+		res[fmt.Sprint(amqp10ToTableValue(k))] = amqp10ToTableValue(v)
+	}
+	return res
+}
+
+func amqp10String(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case amqp10Symbol:
+		return string(val)
+	case []byte:
+		return string(val)
+	}
+	return fmt.Sprint(amqp10ToTableValue(v))
+}
+
+// listField returns the i-th element of a list, or nil.
+func listField(l []any, i int) any {
+	if i < len(l) {
+		return l[i]
+	}
+	return nil
+}
+
+// decodeAmqp10Message decodes an AMQP 1.0 message into an amqp.Delivery.
+// The body is taken from the data sections or from an amqp-value section.
+// Application properties become headers, message annotations set by
+// RabbitMQ (x-exchange, x-routing-key, ...) are mapped to the respective
+// fields of the delivery.
+func decodeAmqp10Message(data []byte) (amqp.Delivery, error) {
+	d := amqp10Decoder{newStreamDecoder(data)}
+	msg := amqp.Delivery{Headers: amqp.Table{}}
+	var body bytes.Buffer
+
+	for d.remaining() > 0 {
+		section, err := d.value()
+		if err != nil {
+			return msg, err
+		}
+		described, ok := section.(amqp10Described)
+		if !ok {
+			return msg, d.fail("expected described section, got %T", section)
+		}
+		descriptor, ok := described.Descriptor.(uint64)
+		if !ok {
+			return msg, d.fail("unsupported section descriptor %v", described.Descriptor)
+		}
+
+		switch descriptor {
+		case amqp10SectionHeader:
+			header, _ := described.Value.([]any)
+			if durable, _ := listField(header, 0).(bool); durable {
+				msg.DeliveryMode = amqp.Persistent
+			}
+			if priority, ok := listField(header, 1).(uint8); ok {
+				msg.Priority = priority
+			}
+			if ttl, ok := listField(header, 2).(uint32); ok {
+				msg.Expiration = strconv.FormatUint(uint64(ttl), 10)
+			}
+		case amqp10SectionMessageAnnotations:
+			annotations, _ := described.Value.(map[any]any)
+			applyAmqp10MessageAnnotations(&msg, annotations)
+		case amqp10SectionProperties:
+			props, _ := described.Value.([]any)
+			msg.MessageId = amqp10String(listField(props, 0))
+			msg.UserId = amqp10String(listField(props, 1))
+			msg.ReplyTo = amqp10String(listField(props, 4))
+			msg.CorrelationId = amqp10String(listField(props, 5))
+			msg.ContentType = amqp10String(listField(props, 6))
+			msg.ContentEncoding = amqp10String(listField(props, 7))
+			if ts, ok := listField(props, 9).(time.Time); ok {
+				msg.Timestamp = ts
+			}
+		case amqp10SectionApplicationProperties:
+			props, _ := described.Value.(map[any]any)
+			for k, v := range amqp10ToTable(props) {
+				msg.Headers[k] = v
+			}
+		case amqp10SectionData:
+			b, _ := described.Value.([]byte)
+			body.Write(b)
+		case amqp10SectionAmqpValue:
+			switch v := described.Value.(type) {
+			case []byte:
+				body.Write(v)
+			default:
+				body.WriteString(amqp10String(v))
+			}
+		case amqp10SectionDeliveryAnnotations, amqp10SectionAmqpSequence, amqp10SectionFooter:
+			// not mapped
+		default:
+			return msg, d.fail("unknown section 0x%02x", descriptor)
+		}
+	}
+	msg.Body = body.Bytes()
+	return msg, nil
+}
+
+// applyAmqp10MessageAnnotations maps the annotations RabbitMQ uses to store
+// AMQP 0.9.1 attributes of messages published with AMQP 0.9.1.
+func applyAmqp10MessageAnnotations(msg *amqp.Delivery, annotations map[any]any) {
+	for k, v := range annotations {
+		switch amqp10String(k) {
+		case "x-exchange":
+			msg.Exchange = amqp10String(v)
+		case "x-routing-key":
+			msg.RoutingKey = amqp10String(v)
+		case "x-basic-type":
+			msg.Type = amqp10String(v)
+		case "x-basic-app-id":
+			msg.AppId = amqp10String(v)
+		case "x-basic-expiration":
+			msg.Expiration = amqp10String(v)
+		}
+	}
+}
+
+// StreamOffsetHeader is the header containing the offset of a message,
+// like it is set by RabbitMQ for messages consumed with AMQP 0.9.1.
+const StreamOffsetHeader = "x-stream-offset"
+
+// newStreamDelivery returns the delivery for the message at the given
+// offset.
+func newStreamDelivery(data []byte, offset uint64, acknowledger amqp.Acknowledger) (amqp.Delivery, error) {
+	msg, err := decodeAmqp10Message(data)
+	if err != nil {
+		return msg, fmt.Errorf("decode message at offset %d: %w", offset, err)
+	}
+	msg.Headers[StreamOffsetHeader] = int64(offset)
+	msg.DeliveryTag = offset
+	msg.Acknowledger = acknowledger
+	return msg, nil
+}
@@ -0,0 +1,195 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAmqp10MessageMapsAllSections(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var msg []byte
+	msg = append(msg, amqp10TestSection(amqp10SectionHeader,
+		amqp10TestList([]byte{0x41}, []byte{0x50, 7}, binary.BigEndian.AppendUint32([]byte{0x70}, 60000)))...)
+	msg = append(msg, amqp10TestSection(amqp10SectionMessageAnnotations,
+		amqp10TestMap(map[string][]byte{
+			"x-exchange":    amqp10TestStr("amq.topic"),
+			"x-routing-key": amqp10TestStr("key"),
+			"x-basic-type":  amqp10TestStr("type"),
+		}, amqp10TestSym))...)
+	msg = append(msg, amqp10TestSection(amqp10SectionProperties,
+		amqp10TestList(
+			amqp10TestStr("msg-1"),
+			amqp10TestBin([]byte("user")),
+			[]byte{0x40}, // to
+			[]byte{0x40}, // subject
+			amqp10TestStr("reply"),
+			amqp10TestStr("corr-1"),
+			amqp10TestSym("application/json"),
+			amqp10TestSym("gzip"),
+			[]byte{0x40}, // absolute expiry time
+			binary.BigEndian.AppendUint64([]byte{0x83}, uint64(ts.UnixMilli())),
+		))...)
+	msg = append(msg, amqp10TestSection(amqp10SectionApplicationProperties,
+		amqp10TestMap(map[string][]byte{
+			"str":  amqp10TestStr("value"),
+			"int":  {0x54, 0xff},
+			"long": binary.BigEndian.AppendUint64([]byte{0x81}, 42),
+			"bool": {0x42},
+		}, amqp10TestStr))...)
+	msg = append(msg, amqp10TestSection(amqp10SectionData, amqp10TestBin([]byte("hello ")))...)
+	msg = append(msg, amqp10TestSection(amqp10SectionData, amqp10TestBin([]byte("world")))...)
+
+	delivery, err := decodeAmqp10Message(msg)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), delivery.Body)
+	assert.Equal(t, amqp.Persistent, delivery.DeliveryMode)
+	assert.Equal(t, uint8(7), delivery.Priority)
+	assert.Equal(t, "60000", delivery.Expiration)
+	assert.Equal(t, "amq.topic", delivery.Exchange)
+	assert.Equal(t, "key", delivery.RoutingKey)
+	assert.Equal(t, "type", delivery.Type)
+	assert.Equal(t, "msg-1", delivery.MessageId)
+	assert.Equal(t, "user", delivery.UserId)
+	assert.Equal(t, "reply", delivery.ReplyTo)
+	assert.Equal(t, "corr-1", delivery.CorrelationId)
+	assert.Equal(t, "application/json", delivery.ContentType)
+	assert.Equal(t, "gzip", delivery.ContentEncoding)
+	assert.Equal(t, ts, delivery.Timestamp)
+	assert.Equal(t, amqp.Table{
+		"str": "value", "int": int32(-1), "long": int64(42), "bool": false,
+	}, delivery.Headers)
+}
+
+func TestDecodeAmqp10MessageWithAmqpValueBody(t *testing.T) {
+	msg := amqp10TestSection(amqp10SectionAmqpValue, amqp10TestStr("hello"))
+
+	delivery, err := decodeAmqp10Message(msg)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), delivery.Body)
+}
+
+func TestDecodeAmqp10MessageDecodesArraysAndNestedMaps(t *testing.T) {
+	array := []byte{0xe0, 10, 2, 0x71} // array8 of 2 ints
+	array = binary.BigEndian.AppendUint32(array, 1)
+	array = binary.BigEndian.AppendUint32(array, 2)
+	msg := amqp10TestSection(amqp10SectionApplicationProperties,
+		amqp10TestMap(map[string][]byte{
+			"array":  array,
+			"nested": amqp10TestMap(map[string][]byte{"k": amqp10TestStr("v")}, amqp10TestStr),
+		}, amqp10TestStr))
+
+	delivery, err := decodeAmqp10Message(msg)
+
+	require.NoError(t, err)
+	assert.Equal(t, []any{int32(1), int32(2)}, delivery.Headers["array"])
+	assert.Equal(t, amqp.Table{"k": "v"}, delivery.Headers["nested"])
+}
+
+func TestDecodeAmqp10MessageFailsOnInvalidInput(t *testing.T) {
+	_, err := decodeAmqp10Message([]byte{0x00, 0x53, amqp10SectionData, 0xff})
+	assert.ErrorIs(t, err, errAmqp10Decode)
+
+	_, err = decodeAmqp10Message(amqp10TestStr("not a section"))
+	assert.ErrorIs(t, err, errAmqp10Decode)
+
+	_, err = decodeAmqp10Message([]byte{0x00, 0x53, amqp10SectionData, 0xb0, 0, 0, 0, 10})
+	assert.Error(t, err)
+}
+
+func TestDecodeStreamChunkWithSimpleEntries(t *testing.T) {
+	data := encodeTestChunk(100, [][]byte{[]byte("a"), []byte("bc")})
+
+	chunk, err := decodeStreamChunk(newStreamDecoder(data))
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), chunk.firstOffset)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), chunk.timestamp.UTC())
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("bc")}, chunk.records)
+}
+
+func TestDecodeStreamChunkWithCompressedSubBatchEntry(t *testing.T) {
+	records := &streamEncoder{}
+	records.bytes([]byte("a")).bytes([]byte("bc"))
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, _ = w.Write(records.buf.Bytes())
+	require.NoError(t, w.Close())
+
+	entry := &streamEncoder{}
+	entry.uint8(0x80 | streamCompressionGzip<<4).
+		uint16(2).
+		uint32(uint32(records.buf.Len())).
+		bytes(compressed.Bytes())
+	data := encodeTestChunkData(0, 1, 2, entry.buf.Bytes())
+
+	chunk, err := decodeStreamChunk(newStreamDecoder(data))
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("bc")}, chunk.records)
+}
+
+func TestDecodeStreamChunkFailsOnUnsupportedCompression(t *testing.T) {
+	entry := &streamEncoder{}
+	entry.uint8(0x80 | 2<<4).uint16(1).uint32(1).bytes([]byte("x"))
+	data := encodeTestChunkData(0, 1, 1, entry.buf.Bytes())
+
+	_, err := decodeStreamChunk(newStreamDecoder(data))
+
+	assert.ErrorContains(t, err, "unsupported sub-batch compression 2")
+}
+
+func TestDecodeStreamChunkFailsOnInvalidChunks(t *testing.T) {
+	data := encodeTestChunk(0, [][]byte{[]byte("a")})
+	data[0] = 0x42
+	_, err := decodeStreamChunk(newStreamDecoder(data))
+	assert.ErrorContains(t, err, "unsupported chunk format")
+
+	data = encodeTestChunkData(0, 1, 2, (&streamEncoder{}).bytes([]byte("a")).buf.Bytes())
+	_, err = decodeStreamChunk(newStreamDecoder(data))
+	assert.ErrorContains(t, err, "expected 2")
+
+	_, err = decodeStreamChunk(newStreamDecoder(data[:10]))
+	assert.Error(t, err)
+}
+
+func TestNewStreamDeliverySetsOffsetHeaderAndAcknowledger(t *testing.T) {
+	acknowledger := streamAcknowledger{offsets: &streamOffsetTracker{}}
+
+	delivery, err := newStreamDelivery(encodeTestAmqp10Message("id", nil, []byte("body")), 42, acknowledger)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), delivery.Headers["x-stream-offset"])
+	assert.Equal(t, uint64(42), delivery.DeliveryTag)
+	assert.Equal(t, "id", delivery.MessageId)
+	assert.Equal(t, "text/plain", delivery.ContentType)
+	assert.NoError(t, delivery.Ack(false))
+	offset, ok := acknowledger.offsets.toStore()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(42), offset)
+}
+
+func TestStreamOffsetTrackerReturnsOffsetToStoreUntilItWasStored(t *testing.T) {
+	offsets := &streamOffsetTracker{}
+	require.NoError(t, streamAcknowledger{offsets: offsets}.Ack(42, false))
+
+	// e.g. storing the offset failed
+	offset, ok := offsets.toStore()
+	require.True(t, ok)
+	offset, ok = offsets.toStore()
+	require.True(t, ok)
+
+	offsets.setStored(offset)
+	_, ok = offsets.toStore()
+	assert.False(t, ok)
+}
@@ -0,0 +1,286 @@
+// Copyright (C) 2026 Jan Delgado
+// Minimal implementation of the RabbitMQ stream protocol, as far as needed to
+// consume messages from a stream. See
+// https://github.com/rabbitmq/rabbitmq-server/blob/main/deps/rabbitmq_stream/docs/PROTOCOL.adoc
+
+package rabtap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// keys of the stream protocol commands used. Responses have the
+// streamResponseFlag set.
+const (
+	streamCmdSubscribe        uint16 = 0x0007
+	streamCmdDeliver          uint16 = 0x0008
+	streamCmdCredit           uint16 = 0x0009
+	streamCmdStoreOffset      uint16 = 0x000a
+	streamCmdQueryOffset      uint16 = 0x000b
+	streamCmdUnsubscribe      uint16 = 0x000c
+	streamCmdMetadataUpdate   uint16 = 0x0010
+	streamCmdPeerProperties   uint16 = 0x0011
+	streamCmdSaslHandshake    uint16 = 0x0012
+	streamCmdSaslAuthenticate uint16 = 0x0013
+	streamCmdTune             uint16 = 0x0014
+	streamCmdOpen             uint16 = 0x0015
+	streamCmdClose            uint16 = 0x0016
+	streamCmdHeartbeat        uint16 = 0x0017
+
+	streamResponseFlag    uint16 = 0x8000
+	streamProtocolVersion uint16 = 1
+)
+
+// response codes of the stream protocol
+const (
+	streamResponseOK                 uint16 = 0x01
+	streamResponseStreamDoesNotExist uint16 = 0x02
+	streamResponseNoOffset           uint16 = 0x13
+)
+
+var streamResponseCodeNames = map[uint16]string{
+	0x01: "ok",
+	0x02: "stream does not exist",
+	0x03: "subscription id already exists",
+	0x04: "subscription id does not exist",
+	0x05: "stream already exists",
+	0x06: "stream not available",
+	0x07: "sasl mechanism not supported",
+	0x08: "authentication failure",
+	0x09: "sasl error",
+	0x0a: "sasl challenge",
+	0x0b: "sasl authentication failure loopback",
+	0x0c: "virtual host access failure",
+	0x0d: "unknown frame",
+	0x0e: "frame too large",
+	0x0f: "internal error",
+	0x10: "access refused",
+	0x11: "precondition failed",
+	0x12: "publisher does not exist",
+	0x13: "no offset",
+}
+
+// StreamResponseError is returned when the broker answers a request of the
+// stream protocol with an error code.
+type StreamResponseError struct {
+	Key  uint16
+	Code uint16
+}
+
+func (s *StreamResponseError) Error() string {
+	name, ok := streamResponseCodeNames[s.Code]
+	if !ok {
+		name = "unknown error"
+	}
+	return fmt.Sprintf("stream command 0x%04x failed: %s (0x%02x)", s.Key, name, s.Code)
+}
+
+// streamEncoder builds a frame of the stream protocol. All integers are
+// encoded in network byte order.
+type streamEncoder struct {
+	buf bytes.Buffer
+}
+
+func newStreamEncoder(key, version uint16) *streamEncoder {
+	e := &streamEncoder{}
+	return e.uint16(key).uint16(version)
+}
+
+func (e *streamEncoder) uint8(v uint8) *streamEncoder {
+	e.buf.WriteByte(v)
+	return e
+}
+
+func (e *streamEncoder) uint16(v uint16) *streamEncoder {
+	e.buf.Write(binary.BigEndian.AppendUint16(nil, v))
+	return e
+}
+
+func (e *streamEncoder) uint32(v uint32) *streamEncoder {
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, v))
+	return e
+}
+
+func (e *streamEncoder) uint64(v uint64) *streamEncoder {
+	e.buf.Write(binary.BigEndian.AppendUint64(nil, v))
+	return e
+}
+
+func (e *streamEncoder) raw(v []byte) *streamEncoder {
+	e.buf.Write(v)
+	return e
+}
+
+func (e *streamEncoder) string(v string) *streamEncoder {
+	e.uint16(uint16(len(v)))
+	e.buf.WriteString(v)
+	return e
+}
+
+func (e *streamEncoder) bytes(v []byte) *streamEncoder {
+	e.uint32(uint32(len(v)))
+	e.buf.Write(v)
+	return e
+}
+
+func (e *streamEncoder) strings(v []string) *streamEncoder {
+	e.uint32(uint32(len(v)))
+	for _, s := range v {
+		e.string(s)
+	}
+	return e
+}
+
+// stringMap encodes a map sorted by key, so frames are reproducible.
+func (e *streamEncoder) stringMap(m map[string]string) *streamEncoder {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.uint32(uint32(len(keys)))
+	for _, k := range keys {
+		e.string(k).string(m[k])
+	}
+	return e
+}
+
+// frame returns the encoded frame, prefixed with its size.
+func (e *streamEncoder) frame() []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(e.buf.Len())), e.buf.Bytes()...)
+}
+
+// streamDecoder decodes the content of a frame. The first error is
+// recorded and subsequent reads return zero values, so a sequence of reads
+// can be checked once with err().
+type streamDecoder struct {
+	data  []byte
+	error error
+}
+
+func newStreamDecoder(data []byte) *streamDecoder {
+	return &streamDecoder{data: data}
+}
+
+func (d *streamDecoder) next(n int) []byte {
+	if d.error != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.error = io.ErrUnexpectedEOF
+		d.data = nil
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *streamDecoder) uint8() uint8 {
+	if v := d.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (d *streamDecoder) uint16() uint16 {
+	if v := d.next(2); v != nil {
+		return binary.BigEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (d *streamDecoder) uint32() uint32 {
+	if v := d.next(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (d *streamDecoder) uint64() uint64 {
+	if v := d.next(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (d *streamDecoder) string() string {
+	n := int16(d.uint16())
+	if n <= 0 {
+		return "" // -1 encodes null
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *streamDecoder) bytes() []byte {
+	n := int32(d.uint32())
+	if n <= 0 {
+		return nil // -1 encodes null
+	}
+	return d.next(int(n))
+}
+
+func (d *streamDecoder) strings() []string {
+	n := int(d.uint32())
+	var res []string
+	for i := 0; i < n && d.error == nil; i++ {
+		res = append(res, d.string())
+	}
+	return res
+}
+
+func (d *streamDecoder) stringMap() map[string]string {
+	n := int(d.uint32())
+	res := map[string]string{}
+	for i := 0; i < n && d.error == nil; i++ {
+		k := d.string()
+		res[k] = d.string()
+	}
+	return res
+}
+
+func (d *streamDecoder) remaining() int {
+	return len(d.data)
+}
+
+func (d *streamDecoder) err() error {
+	return d.error
+}
+
+// streamFrame is a decoded frame header with the remaining content.
+type streamFrame struct {
+	key     uint16
+	version uint16
+	content *streamDecoder
+}
+
+func (s streamFrame) isResponse() bool {
+	return s.key&streamResponseFlag != 0
+}
+
+var errStreamFrameTooLarge = errors.New("stream frame too large")
+
+// readStreamFrame reads the next frame from r. Frames larger than maxSize
+// are rejected, if maxSize is not 0.
+func readStreamFrame(r io.Reader, maxSize uint32) (streamFrame, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return streamFrame{}, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if maxSize != 0 && n > maxSize {
+		return streamFrame{}, fmt.Errorf("%w: %d bytes", errStreamFrameTooLarge, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return streamFrame{}, err
+	}
+	d := newStreamDecoder(data)
+	frame := streamFrame{key: d.uint16(), version: d.uint16(), content: d}
+	return frame, d.err()
+}
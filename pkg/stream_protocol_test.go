@@ -0,0 +1,78 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamEncoderAndDecoderRoundTrip(t *testing.T) {
+	e := newStreamEncoder(streamCmdSubscribe, streamProtocolVersion).
+		uint8(1).uint16(2).uint32(3).uint64(4).
+		string("stream").bytes([]byte("data")).
+		strings([]string{"a", "b"}).
+		stringMap(map[string]string{"k2": "v2", "k1": "v1"})
+
+	frame, err := readStreamFrame(bytes.NewReader(e.frame()), 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, streamCmdSubscribe, frame.key)
+	assert.Equal(t, streamProtocolVersion, frame.version)
+	assert.False(t, frame.isResponse())
+	d := frame.content
+	assert.Equal(t, uint8(1), d.uint8())
+	assert.Equal(t, uint16(2), d.uint16())
+	assert.Equal(t, uint32(3), d.uint32())
+	assert.Equal(t, uint64(4), d.uint64())
+	assert.Equal(t, "stream", d.string())
+	assert.Equal(t, []byte("data"), d.bytes())
+	assert.Equal(t, []string{"a", "b"}, d.strings())
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, d.stringMap())
+	assert.Equal(t, 0, d.remaining())
+	assert.NoError(t, d.err())
+}
+
+func TestStreamDecoderRecordsFirstError(t *testing.T) {
+	d := newStreamDecoder([]byte{0, 5, 'a'})
+
+	assert.Equal(t, "", d.string())
+	assert.Equal(t, uint8(0), d.uint8())
+	assert.ErrorIs(t, d.err(), io.ErrUnexpectedEOF)
+}
+
+func TestStreamDecoderDecodesNullStringAndBytes(t *testing.T) {
+	d := newStreamDecoder([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	assert.Equal(t, "", d.string())
+	assert.Nil(t, d.bytes())
+	assert.NoError(t, d.err())
+}
+
+func TestReadStreamFrameRejectsTooLargeFrames(t *testing.T) {
+	frame := newStreamEncoder(streamCmdHeartbeat, streamProtocolVersion).raw(make([]byte, 100)).frame()
+
+	_, err := readStreamFrame(bytes.NewReader(frame), 10)
+
+	assert.ErrorIs(t, err, errStreamFrameTooLarge)
+}
+
+func TestReadStreamFrameFailsOnTruncatedFrame(t *testing.T) {
+	frame := newStreamEncoder(streamCmdHeartbeat, streamProtocolVersion).frame()
+
+	_, err := readStreamFrame(bytes.NewReader(frame[:len(frame)-1]), 0)
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestStreamResponseErrorMessage(t *testing.T) {
+	err := &StreamResponseError{Key: streamCmdSubscribe, Code: streamResponseStreamDoesNotExist}
+	assert.Equal(t, "stream command 0x0007 failed: stream does not exist (0x02)", err.Error())
+
+	err = &StreamResponseError{Key: streamCmdSubscribe, Code: 0xff}
+	assert.Equal(t, "stream command 0x0007 failed: unknown error (0xff)", err.Error())
+}
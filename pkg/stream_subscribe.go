@@ -0,0 +1,415 @@
+// Copyright (C) 2026 Jan Delgado
+// Subscribe to streams using the native RabbitMQ stream protocol.
+
+package rabtap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// StreamOffsetType specifies how a StreamOffset is interpreted.
+type StreamOffsetType uint16
+
+// offset specification types of the stream protocol
+const (
+	StreamOffsetFirst     StreamOffsetType = 1
+	StreamOffsetLast      StreamOffsetType = 2
+	StreamOffsetNext      StreamOffsetType = 3
+	StreamOffsetAbsolute  StreamOffsetType = 4
+	StreamOffsetTimestamp StreamOffsetType = 5
+)
+
+// StreamOffset specifies where consumption of a stream starts.
+type StreamOffset struct {
+	Type      StreamOffsetType
+	Offset    uint64    // used with StreamOffsetAbsolute
+	Timestamp time.Time // used with StreamOffsetTimestamp
+}
+
+func (s StreamOffset) encode(e *streamEncoder) {
+	e.uint16(uint16(s.Type))
+	switch s.Type {
+	case StreamOffsetAbsolute:
+		e.uint64(s.Offset)
+	case StreamOffsetTimestamp:
+		e.uint64(uint64(s.Timestamp.UnixMilli()))
+	}
+}
+
+func (s StreamOffset) String() string {
+	switch s.Type {
+	case StreamOffsetFirst:
+		return "first"
+	case StreamOffsetLast:
+		return "last"
+	case StreamOffsetNext:
+		return "next"
+	case StreamOffsetAbsolute:
+		return strconv.FormatUint(s.Offset, 10)
+	case StreamOffsetTimestamp:
+		return s.Timestamp.Format(time.RFC3339)
+	}
+	return "invalid"
+}
+
+// ParseStreamOffset parses an offset specification like it is used with
+// the x-stream-offset argument: 'first', 'last', 'next', an integer offset,
+// a RFC3339 timestamp or a duration like '10m', which is relative to now.
+func ParseStreamOffset(spec string, now time.Time) (StreamOffset, error) {
+	switch spec {
+	case "first":
+		return StreamOffset{Type: StreamOffsetFirst}, nil
+	case "last":
+		return StreamOffset{Type: StreamOffsetLast}, nil
+	case "next":
+		return StreamOffset{Type: StreamOffsetNext}, nil
+	}
+	if offset, err := strconv.ParseUint(spec, 10, 64); err == nil {
+		return StreamOffset{Type: StreamOffsetAbsolute, Offset: offset}, nil
+	}
+	if ts, err := time.Parse(time.RFC3339, spec); err == nil {
+		return StreamOffset{Type: StreamOffsetTimestamp, Timestamp: ts}, nil
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		return StreamOffset{Type: StreamOffsetTimestamp, Timestamp: now.Add(-d)}, nil
+	}
+	return StreamOffset{}, fmt.Errorf("invalid stream offset %q", spec)
+}
+
+const (
+	defaultStreamCredit  = 10
+	streamSubscriptionID = 0
+)
+
+// StreamSubscriberConfig stores the configuration of the StreamSubscriber
+type StreamSubscriberConfig struct {
+	// Offset where to start consuming the stream
+	Offset StreamOffset
+	// Filters are values used by the broker to skip chunks not containing
+	// messages with one of the filter values (RabbitMQ 3.13+). Since the
+	// broker uses a bloom filter, messages with other values may still be
+	// received.
+	Filters []string
+	// MatchUnfiltered also delivers messages published without filter value
+	MatchUnfiltered bool
+	// Reference is the name under which offsets of acknowledged messages
+	// are stored on the broker. If empty, no offsets are stored.
+	Reference string
+	// Resume starts consumption with the message following the offset stored
+	// for Reference. Offset is only used when no offset was stored yet.
+	Resume bool
+	// Credit is the number of chunks the broker may send in advance. If 0,
+	// a default is used.
+	Credit uint16
+}
+
+// StreamSubscriber consumes messages from a stream using the RabbitMQ
+// stream protocol, which is much faster than consuming a stream with AMQP.
+// Like the AmqpSubscriber, messages are sent to a TapChannel.
+type StreamSubscriber struct {
+	config     StreamSubscriberConfig
+	url        *url.URL
+	tlsConfig  *tls.Config
+	logger     *slog.Logger
+	offsets    *streamOffsetTracker
+	retryDelay time.Duration
+}
+
+// NewStreamSubscriber returns a new StreamSubscriber object associated with
+// the RabbitMQ broker denoted by the url parameter (see ParseStreamURL).
+func NewStreamSubscriber(config StreamSubscriberConfig, url *url.URL, tlsConfig *tls.Config, logger *slog.Logger) *StreamSubscriber {
+	if config.Credit == 0 {
+		config.Credit = defaultStreamCredit
+	}
+	return &StreamSubscriber{
+		config:     config,
+		url:        url,
+		tlsConfig:  tlsConfig,
+		logger:     logger,
+		offsets:    &streamOffsetTracker{},
+		retryDelay: retryDelay,
+	}
+}
+
+// streamOffsetTracker keeps track of the offsets of received and of
+// acknowledged messages.
+type streamOffsetTracker struct {
+	mu       sync.Mutex
+	received *uint64
+	acked    *uint64
+	stored   *uint64
+}
+
+func (s *streamOffsetTracker) setReceived(offset uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = &offset
+}
+
+// next returns the offset following the last received message.
+func (s *streamOffsetTracker) next() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.received == nil {
+		return 0, false
+	}
+	return *s.received + 1, true
+}
+
+// toStore returns the offset of the last acknowledged message, if it was
+// not already stored.
+func (s *streamOffsetTracker) toStore() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.acked == nil || (s.stored != nil && *s.stored == *s.acked) {
+		return 0, false
+	}
+	return *s.acked, true
+}
+
+// setStored records the offset which was successfully stored
+func (s *streamOffsetTracker) setStored(offset uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stored = &offset
+}
+
+// streamAcknowledger acknowledges stream messages. Since there is no
+// acknowledgement in the stream protocol, acknowledged offsets are only
+// recorded, so they can be stored on the broker.
+type streamAcknowledger struct {
+	offsets *streamOffsetTracker
+}
+
+func (s streamAcknowledger) Ack(tag uint64, multiple bool) error {
+	s.offsets.mu.Lock()
+	defer s.offsets.mu.Unlock()
+	if s.offsets.acked == nil || tag > *s.offsets.acked {
+		s.offsets.acked = &tag
+	}
+	return nil
+}
+
+func (s streamAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return nil
+}
+
+func (s streamAcknowledger) Reject(tag uint64, requeue bool) error {
+	return nil
+}
+
+// QueryOffset returns the offset stored on the broker for the configured
+// reference and the given stream. Returns false if no offset was stored.
+func (s *StreamSubscriber) QueryOffset(ctx context.Context, stream string) (uint64, bool, error) {
+	if s.config.Reference == "" {
+		return 0, false, errors.New("no reference configured")
+	}
+	conn, err := dialStream(ctx, s.url, s.tlsConfig, s.logger)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = conn.close() }()
+	return conn.queryOffset(ctx, s.config.Reference, stream)
+}
+
+// EstablishSubscription connects to the broker and subscribes to the given
+// stream. Received messages are sent to tapCh. After a connection loss, the
+// subscription is resumed with the message following the last message
+// received. Returns an error if the initial connection or the subscription
+// fails.
+func (s *StreamSubscriber) EstablishSubscription(
+	ctx context.Context,
+	stream string,
+	tapCh TapChannel,
+	errCh SubscribeErrorChannel,
+) error {
+	failEarly := true
+	for {
+		conn, err := dialStream(ctx, s.url, s.tlsConfig, s.logger)
+		if err == nil {
+			failEarly = false
+			var action ReconnectAction
+			action, err = s.consume(ctx, conn, stream, tapCh, errCh)
+			if !action.shouldReconnect() {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if failEarly {
+			return err
+		}
+		s.logger.Error("stream subscription failed, reconnecting", "stream", stream, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.retryDelay):
+		}
+	}
+}
+
+// subscriptionOffset returns the offset to subscribe with: the configured
+// offset (or the offset following the stored offset when resuming)
+// initially, the offset following the last received message after
+// reconnects.
+func (s *StreamSubscriber) subscriptionOffset(ctx context.Context, conn *streamConn, stream string) (StreamOffset, error) {
+	if next, ok := s.offsets.next(); ok {
+		return StreamOffset{Type: StreamOffsetAbsolute, Offset: next}, nil
+	}
+	if s.config.Resume && s.config.Reference != "" {
+		stored, ok, err := conn.queryOffset(ctx, s.config.Reference, stream)
+		if err != nil {
+			return StreamOffset{}, fmt.Errorf("query offset of %s: %w", s.config.Reference, err)
+		}
+		if ok {
+			return StreamOffset{Type: StreamOffsetAbsolute, Offset: stored + 1}, nil
+		}
+	}
+	return s.config.Offset, nil
+}
+
+func (s *StreamSubscriber) subscriptionProperties() map[string]string {
+	props := map[string]string{}
+	for i, filter := range s.config.Filters {
+		props["filter."+strconv.Itoa(i)] = filter
+	}
+	if len(s.config.Filters) > 0 {
+		props["match-unfiltered"] = strconv.FormatBool(s.config.MatchUnfiltered)
+	}
+	return props
+}
+
+// storeOffset stores the offset of the last acknowledged message on the
+// broker, if a reference is configured.
+func (s *StreamSubscriber) storeOffset(conn *streamConn, stream string) {
+	if s.config.Reference == "" {
+		return
+	}
+	if offset, ok := s.offsets.toStore(); ok {
+		if err := conn.storeOffset(s.config.Reference, stream, offset); err != nil {
+			// the offset is stored again with the next attempt
+			s.logger.Error("store offset failed", "stream", stream, "offset", offset, "error", err)
+			return
+		}
+		s.offsets.setStored(offset)
+	}
+}
+
+// consume subscribes to the stream and sends received messages to outCh,
+// until the context is cancelled or the connection fails.
+func (s *StreamSubscriber) consume(
+	ctx context.Context,
+	conn *streamConn,
+	stream string,
+	outCh TapChannel,
+	errOutCh SubscribeErrorChannel,
+) (ReconnectAction, error) {
+	offset, err := s.subscriptionOffset(ctx, conn, stream)
+	if err == nil {
+		s.logger.Debug("subscribing to stream", "stream", stream, "offset", offset.String())
+		err = conn.subscribe(ctx, streamSubscriptionID, stream, offset, s.config.Credit, s.subscriptionProperties())
+	}
+	if err != nil {
+		_ = conn.close()
+		var respErr *StreamResponseError
+		if errors.As(err, &respErr) {
+			return doNotReconnect, fmt.Errorf("subscribe to stream %s: %w", stream, err)
+		}
+		return doReconnect, err
+	}
+
+	acknowledger := streamAcknowledger{offsets: s.offsets}
+	shutdown := func() (ReconnectAction, error) {
+		s.storeOffset(conn, stream)
+		return doNotReconnect, conn.close()
+	}
+
+	for {
+		var frame streamFrame
+		select {
+		case <-ctx.Done():
+			return shutdown()
+		case <-conn.done:
+			return doReconnect, conn.err()
+		case frame = <-conn.events:
+		}
+
+		switch frame.key {
+		case streamCmdDeliver:
+			_ = frame.content.uint8() // subscription id
+			chunk, err := decodeStreamChunk(frame.content)
+			if err != nil {
+				conn.shutdown(err)
+				return doReconnect, err
+			}
+			if !s.deliverChunk(ctx, stream, chunk, offset, acknowledger, outCh) {
+				return shutdown()
+			}
+			s.storeOffset(conn, stream)
+			if err := conn.credit(streamSubscriptionID, 1); err != nil {
+				return doReconnect, err
+			}
+		case streamCmdCredit | streamResponseFlag:
+			code := frame.content.uint16()
+			err := &StreamResponseError{Key: streamCmdCredit, Code: code}
+			select {
+			case errOutCh <- &SubscribeError{Reason: SubscribeErrorChannelError, Cause: err}:
+			case <-ctx.Done():
+				return shutdown()
+			}
+		case streamCmdMetadataUpdate:
+			code := frame.content.uint16()
+			_ = conn.close()
+			return doNotReconnect, fmt.Errorf("stream %s no longer available: %w", stream,
+				&StreamResponseError{Key: streamCmdMetadataUpdate, Code: code})
+		default:
+			s.logger.Debug("ignoring stream frame", "key", frame.key)
+		}
+	}
+}
+
+// deliverChunk sends the messages of a chunk to outCh. Since chunks may
+// start before the requested offset, messages before an absolute offset
+// are skipped. Returns false if the context was cancelled.
+func (s *StreamSubscriber) deliverChunk(
+	ctx context.Context,
+	stream string,
+	chunk streamChunk,
+	offset StreamOffset,
+	acknowledger amqp.Acknowledger,
+	outCh TapChannel,
+) bool {
+	if chunk.chunkType != streamChunkTypeUser {
+		return true
+	}
+	for i, record := range chunk.records {
+		messageOffset := chunk.firstOffset + uint64(i)
+		if offset.Type == StreamOffsetAbsolute && messageOffset < offset.Offset {
+			continue
+		}
+		delivery, err := newStreamDelivery(record, messageOffset, acknowledger)
+		if err != nil {
+			s.logger.Error("skipping message", "error", err)
+			s.offsets.setReceived(messageOffset)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case outCh <- TapMessage{AmqpMessage: &delivery, ReceivedTimestamp: time.Now(), Queue: stream}:
+			s.offsets.setReceived(messageOffset)
+		}
+	}
+	return true
+}
@@ -0,0 +1,261 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamOffset(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	testcases := []struct {
+		spec     string
+		expected StreamOffset
+	}{
+		{"first", StreamOffset{Type: StreamOffsetFirst}},
+		{"last", StreamOffset{Type: StreamOffsetLast}},
+		{"next", StreamOffset{Type: StreamOffsetNext}},
+		{"123", StreamOffset{Type: StreamOffsetAbsolute, Offset: 123}},
+		{"2026-01-01T00:00:00Z", StreamOffset{Type: StreamOffsetTimestamp,
+			Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"10m", StreamOffset{Type: StreamOffsetTimestamp, Timestamp: now.Add(-10 * time.Minute)}},
+	}
+	for _, tc := range testcases {
+		offset, err := ParseStreamOffset(tc.spec, now)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.expected, offset, tc.spec)
+	}
+
+	_, err := ParseStreamOffset("invalid", now)
+	assert.Error(t, err)
+}
+
+func TestParseStreamURL(t *testing.T) {
+	testcases := []struct {
+		url, host string
+		port      int
+		tls       bool
+		vhost     string
+	}{
+		{"rabbitmq-stream://localhost", "localhost", 5552, false, "/"},
+		{"rabbitmq-stream://localhost:1234/vh", "localhost", 1234, false, "vh"},
+		{"rabbitmq-stream+tls://localhost", "localhost", 5551, true, "/"},
+		{"amqp://localhost:5672/%2F", "localhost", 5552, false, "/"},
+		{"amqps://localhost:5671/vh", "localhost", 5551, true, "vh"},
+	}
+	for _, tc := range testcases {
+		u, _ := url.Parse(tc.url)
+		uri, useTLS, err := ParseStreamURL(u)
+		require.NoError(t, err, tc.url)
+		assert.Equal(t, tc.host, uri.Host, tc.url)
+		assert.Equal(t, tc.port, uri.Port, tc.url)
+		assert.Equal(t, tc.tls, useTLS, tc.url)
+		assert.Equal(t, tc.vhost, uri.Vhost, tc.url)
+	}
+
+	u, _ := url.Parse("http://localhost")
+	_, _, err := ParseStreamURL(u)
+	assert.Error(t, err)
+}
+
+func testStreamMessages(n int) [][]byte {
+	var messages [][]byte
+	for i := 0; i < n; i++ {
+		messages = append(messages, encodeTestAmqp10Message(fmt.Sprintf("msg-%d", i),
+			map[string][]byte{"region": amqp10TestStr("eu")}, []byte(fmt.Sprintf("body %d", i))))
+	}
+	return messages
+}
+
+// receiveStreamMessages receives n messages and returns their bodies.
+func receiveStreamMessages(t *testing.T, ch TapChannel, n int) []string {
+	var bodies []string
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-ch:
+			bodies = append(bodies, string(msg.AmqpMessage.Body))
+			assert.NoError(t, msg.AmqpMessage.Ack(false))
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "did not receive message", "received %v", bodies)
+		}
+	}
+	return bodies
+}
+
+func TestStreamSubscriberReceivesMessagesFromFirstOffset(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(5), 2)
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetFirst}, Credit: 1}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tapCh := make(TapChannel)
+	done := make(chan error)
+	go func() { done <- subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	msg := <-tapCh
+	assert.Equal(t, "body 0", string(msg.AmqpMessage.Body))
+	assert.Equal(t, "msg-0", msg.AmqpMessage.MessageId)
+	assert.Equal(t, "stream", msg.Queue)
+	assert.Equal(t, "eu", msg.AmqpMessage.Headers["region"])
+	assert.Equal(t, int64(0), msg.AmqpMessage.Headers["x-stream-offset"])
+	// further chunks are only received after credit was granted
+	assert.Equal(t, []string{"body 1", "body 2", "body 3", "body 4"}, receiveStreamMessages(t, tapCh, 4))
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []fakeSubscription{{
+		stream: "stream", offsetType: StreamOffsetFirst, credit: 1, properties: map[string]string{},
+	}}, server.getSubscriptions())
+}
+
+func TestStreamSubscriberSkipsMessagesBeforeAbsoluteOffset(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(5), 2)
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetAbsolute, Offset: 3}}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tapCh := make(TapChannel)
+	go func() { _ = subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	// the chunk delivered starts at offset 2
+	assert.Equal(t, []string{"body 3", "body 4"}, receiveStreamMessages(t, tapCh, 2))
+}
+
+func TestStreamSubscriberPassesFilterValuesToBroker(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(1), 1)
+	config := StreamSubscriberConfig{
+		Offset:          StreamOffset{Type: StreamOffsetFirst},
+		Filters:         []string{"eu", "us"},
+		MatchUnfiltered: true,
+	}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tapCh := make(TapChannel)
+	go func() { _ = subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	receiveStreamMessages(t, tapCh, 1)
+	assert.Equal(t, map[string]string{
+		"filter.0": "eu", "filter.1": "us", "match-unfiltered": "true",
+	}, server.getSubscriptions()[0].properties)
+}
+
+func TestStreamSubscriberStoresOffsetOfAcknowledgedMessages(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(3), 10)
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetFirst}, Reference: "rabtap"}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tapCh := make(TapChannel)
+	done := make(chan error)
+	go func() { done <- subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	receiveStreamMessages(t, tapCh, 2)
+	cancel()
+	require.NoError(t, <-done)
+
+	// offset is stored on shutdown
+	require.Eventually(t, func() bool {
+		offset, ok := server.storedOffset("rabtap")
+		return ok && offset == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	offset, ok, err := subscriber.QueryOffset(context.Background(), "stream")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), offset)
+}
+
+func TestStreamSubscriberQueryOffsetReturnsFalseWithoutStoredOffset(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", nil, 1)
+	config := StreamSubscriberConfig{Reference: "rabtap"}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	_, ok, err := subscriber.QueryOffset(context.Background(), "stream")
+
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStreamSubscriberResumesAfterConnectionLoss(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(4), 2)
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetFirst}, Credit: 1}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+	subscriber.retryDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tapCh := make(TapChannel)
+	go func() { _ = subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	assert.Equal(t, []string{"body 0"}, receiveStreamMessages(t, tapCh, 1))
+	server.dropConnections()
+	assert.Equal(t, []string{"body 1", "body 2", "body 3"}, receiveStreamMessages(t, tapCh, 3))
+
+	subscriptions := server.getSubscriptions()
+	require.GreaterOrEqual(t, len(subscriptions), 2)
+	last := subscriptions[len(subscriptions)-1]
+	assert.Equal(t, StreamOffsetAbsolute, last.offsetType)
+}
+
+func TestStreamSubscriberFailsOnUnknownStream(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", nil, 1)
+	subscriber := NewStreamSubscriber(StreamSubscriberConfig{}, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	err := subscriber.EstablishSubscription(context.Background(), "unknown", make(TapChannel), make(SubscribeErrorChannel))
+
+	var respErr *StreamResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, streamResponseStreamDoesNotExist, respErr.Code)
+}
+
+func TestStreamSubscriberFailsOnAuthenticationFailure(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", nil, 1)
+	subscriber := NewStreamSubscriber(StreamSubscriberConfig{}, server.url("guest:wrong"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	err := subscriber.EstablishSubscription(context.Background(), "stream", make(TapChannel), make(SubscribeErrorChannel))
+
+	assert.ErrorContains(t, err, "authentication failure")
+}
+
+func TestStreamSubscriberResumesAfterStoredOffset(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(5), 1)
+	server.storedOffsets["rabtap"] = 2
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetFirst}, Reference: "rabtap", Resume: true}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tapCh := make(TapChannel)
+	go func() { _ = subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	assert.Equal(t, []string{"body 3", "body 4"}, receiveStreamMessages(t, tapCh, 2))
+	subscription := server.getSubscriptions()[0]
+	assert.Equal(t, StreamOffsetAbsolute, subscription.offsetType)
+	assert.Equal(t, uint64(3), subscription.offset)
+}
+
+func TestStreamSubscriberResumeUsesConfiguredOffsetWithoutStoredOffset(t *testing.T) {
+	server := newFakeStreamServer(t, "stream", testStreamMessages(2), 1)
+	config := StreamSubscriberConfig{Offset: StreamOffset{Type: StreamOffsetFirst}, Reference: "rabtap", Resume: true}
+	subscriber := NewStreamSubscriber(config, server.url("guest:guest"), &tls.Config{}, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tapCh := make(TapChannel)
+	go func() { _ = subscriber.EstablishSubscription(ctx, "stream", tapCh, make(SubscribeErrorChannel)) }()
+
+	assert.Equal(t, []string{"body 0", "body 1"}, receiveStreamMessages(t, tapCh, 2))
+	assert.Equal(t, StreamOffsetFirst, server.getSubscriptions()[0].offsetType)
+}
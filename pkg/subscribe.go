@@ -22,6 +22,13 @@ const PrefetchSize = 0
 type AmqpSubscriberConfig struct {
 	Exclusive bool
 	Args      amqp.Table
+	// ConsumerTag is the consumer tag to use. If empty, a random tag is
+	// generated.
+	ConsumerTag string
+	// PrefetchCount is the number of unacknowledged messages the broker
+	// delivers in advance. If 0, PrefetchCount is used. A negative value
+	// disables the limit.
+	PrefetchCount int
 }
 
 // AmqpSubscriber allows to tap to subscribe to queues
@@ -69,6 +76,9 @@ func NewAmqpSubscriber(config AmqpSubscriberConfig, url *url.URL, tlsConfig *tls
 type TapMessage struct {
 	AmqpMessage       *amqp.Delivery
 	ReceivedTimestamp time.Time
+	// Queue is the queue the message was consumed from. Empty for tapped
+	// messages.
+	Queue string
 }
 
 // NewTapMessage constructs a new TapMessage
@@ -107,21 +117,32 @@ func (s *AmqpSubscriber) createWorkerFunc(
 		// also subscribe to channel close notifications
 		amqpErrorCh := session.Channel.NotifyClose(make(chan *amqp.Error, 1))
 		fanin := Fanin(ctx, []<-chan interface{}{WrapChan(ch), WrapChan(amqpErrorCh)})
-		return amqpMessageLoop(ctx, outCh, errOutCh, fanin)
+		return amqpMessageLoop(ctx, outCh, errOutCh, fanin, queueName)
 	}
 }
 
 func (s *AmqpSubscriber) consumeMessages(session Session,
 	queueName string) (<-chan amqp.Delivery, error) {
 
-	err := session.Qos(PrefetchCount, PrefetchSize, false)
+	prefetchCount := s.config.PrefetchCount
+	switch {
+	case prefetchCount == 0:
+		prefetchCount = PrefetchCount
+	case prefetchCount < 0:
+		prefetchCount = 0 // unlimited
+	}
+	err := session.Qos(prefetchCount, PrefetchSize, false)
 	if err != nil {
 		return nil, err
 	}
 
+	consumerTag := s.config.ConsumerTag
+	if consumerTag == "" {
+		consumerTag = "__rabtap-consumer-" + uuid.Must(uuid.NewRandom()).String()[:8]
+	}
 	return session.Consume(
 		queueName,
-		"__rabtap-consumer-"+uuid.Must(uuid.NewRandom()).String()[:8], // TODO param
+		consumerTag,
 		false, // no auto-ack
 		s.config.Exclusive,
 		false, // no-local - unsupported
@@ -0,0 +1,74 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// QueueSelector selects a queue to subscribe to, either by name or by a
+// glob pattern or regular expression.
+type QueueSelector struct {
+	// Queue is the name of the queue, or the pattern as given
+	Queue string
+	// QueuePattern is set when Queue is a glob or regular expression
+	// selecting the queues to subscribe to. The matching queues must then be
+	// discovered using the management API (see discovery.go)
+	QueuePattern *regexp.Regexp
+}
+
+// NewQueueSelector returns a QueueSelector for the given queue name, which
+// can also be a glob pattern (e.g. "orders.*.dlq") or a regular expression
+// prefixed with "re:".
+func NewQueueSelector(queue string) (QueueSelector, error) {
+	if re, ok := strings.CutPrefix(queue, regexpSelectorPrefix); ok {
+		pattern, err := regexp.Compile(re)
+		if err != nil {
+			return QueueSelector{}, fmt.Errorf("invalid queue regexp `%s`: %w", re, err)
+		}
+		return QueueSelector{Queue: re, QueuePattern: pattern}, nil
+	}
+	if isGlobPattern(queue) {
+		return QueueSelector{Queue: queue, QueuePattern: globToRegexp(queue)}, nil
+	}
+	return QueueSelector{Queue: queue}, nil
+}
+
+// SubscribeConfiguration holds the queues to subscribe to on a single
+// RabbitMQ host
+type SubscribeConfiguration struct {
+	AMQPURL *url.URL
+	Queues  []QueueSelector
+}
+
+// NeedsDiscovery returns true if any of the queues of the configuration is
+// selected by a pattern.
+func (s SubscribeConfiguration) NeedsDiscovery() bool {
+	for _, queue := range s.Queues {
+		if queue.QueuePattern != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSubscribeConfiguration returns a SubscribeConfiguration for the
+// RabbitMQ broker specified by the URI and a comma-separated list of queues,
+// e.g. "orders.dlq,billing.*.dlq".
+func NewSubscribeConfiguration(amqpURL *url.URL, queues string) (*SubscribeConfiguration, error) {
+	result := SubscribeConfiguration{AMQPURL: amqpURL}
+	for _, queue := range strings.Split(queues, ",") {
+		if queue == "" {
+			return nil, fmt.Errorf("empty queue name in `%s`", queues)
+		}
+		selector, err := NewQueueSelector(queue)
+		if err != nil {
+			return nil, err
+		}
+		result.Queues = append(result.Queues, selector)
+	}
+	return &result, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQueueSelector(t *testing.T) {
+	testcases := []struct {
+		queue, name string
+		pattern     *regexp.Regexp
+	}{
+		{"orders", "orders", nil},
+		{"orders.*.dlq", "orders.*.dlq", regexp.MustCompile(`^orders\..*\.dlq$`)},
+		{"q?", "q?", regexp.MustCompile(`^q.$`)},
+		{"re:^orders\\..*$", "^orders\\..*$", regexp.MustCompile(`^orders\..*$`)},
+	}
+	for _, tc := range testcases {
+		selector, err := NewQueueSelector(tc.queue)
+		require.NoError(t, err, tc.queue)
+		assert.Equal(t, QueueSelector{Queue: tc.name, QueuePattern: tc.pattern}, selector, tc.queue)
+	}
+}
+
+func TestNewQueueSelectorFailsOnInvalidRegexp(t *testing.T) {
+	_, err := NewQueueSelector("re:(")
+	assert.ErrorContains(t, err, "invalid queue regexp")
+}
+
+func TestNewSubscribeConfigurationParsesListOfQueues(t *testing.T) {
+	amqpURL, _ := url.Parse("amqp://localhost")
+
+	config, err := NewSubscribeConfiguration(amqpURL, "q1,dlq.*")
+
+	require.NoError(t, err)
+	assert.Equal(t, amqpURL, config.AMQPURL)
+	assert.Equal(t, []QueueSelector{
+		{Queue: "q1"},
+		{Queue: "dlq.*", QueuePattern: regexp.MustCompile(`^dlq\..*$`)},
+	}, config.Queues)
+	assert.True(t, config.NeedsDiscovery())
+}
+
+func TestNewSubscribeConfigurationWithoutPatternNeedsNoDiscovery(t *testing.T) {
+	config, err := NewSubscribeConfiguration(nil, "q1,q2")
+
+	require.NoError(t, err)
+	assert.False(t, config.NeedsDiscovery())
+}
+
+func TestNewSubscribeConfigurationFailsOnEmptyQueueName(t *testing.T) {
+	_, err := NewSubscribeConfiguration(nil, "q1,,q2")
+	assert.Error(t, err)
+}
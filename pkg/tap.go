@@ -30,12 +30,21 @@ func NewAmqpTap(url *url.URL, tlsConfig *tls.Config, logger *slog.Logger) *AmqpT
 	}
 }
 
+const (
+	// tapExchangePrefix is the prefix of all exchanges created by rabtap to
+	// tap other exchanges.
+	tapExchangePrefix = "__tap-exchange-for-"
+	// tapQueuePrefix is the prefix of all queues created by rabtap to
+	// receive the messages of a tap-exchange.
+	tapQueuePrefix = "__tap-queue-for-"
+)
+
 func getTapExchangeNameForExchange(exchange, postfix string) string {
-	return "__tap-exchange-for-" + exchange + "-" + postfix
+	return tapExchangePrefix + exchange + "-" + postfix
 }
 
 func getTapQueueNameForExchange(exchange, postfix string) string {
-	return "__tap-queue-for-" + exchange + "-" + postfix
+	return tapQueuePrefix + exchange + "-" + postfix
 }
 
 // EstablishTap sets up the connection to the broker and sets up
@@ -56,8 +65,13 @@ func (s *AmqpTap) createWorkerFunc(
 	errOutCh SubscribeErrorChannel,
 ) AmqpWorkerFunc {
 	return func(ctx context.Context, session Session) (ReconnectAction, error) {
+		// remove leftovers of a previous session, e.g. tap-exchanges which
+		// were not auto-deleted after the connection was lost.
+		s.removeTaps(&session)
+
 		tappedChs, err := s.setupTapsForExchanges(session, exchangeConfigList)
 		if err != nil {
+			s.removeTaps(&session)
 			return doNotReconnect, err
 		}
 
@@ -70,8 +84,53 @@ func (s *AmqpTap) createWorkerFunc(
 		}
 
 		fanin := Fanin(ctx, chans)
-		return amqpMessageLoop(ctx, outCh, errOutCh, fanin)
+		action, err := amqpMessageLoop(ctx, outCh, errOutCh, fanin, "")
+		if !action.shouldReconnect() {
+			s.removeTaps(&session)
+		}
+		return action, err
+	}
+}
+
+// removeTaps deletes all tap-exchanges and tap-queues created so far. A new
+// channel is used, since the current channel may already be closed.
+// Objects which could not be removed are kept, so removal is retried on the
+// next call.
+func (s *AmqpTap) removeTaps(session *Session) {
+	if len(s.exchanges) == 0 && len(s.queues) == 0 {
+		return
+	}
+	if err := session.NewChannel(); err != nil {
+		s.logger.Error("failed to open channel to remove taps", "error", err)
+		return
+	}
+	s.queues = s.removeAll(session, "queue", s.queues,
+		func(name string) error { return RemoveQueue(*session, name, false, false) })
+	s.exchanges = s.removeAll(session, "exchange", s.exchanges,
+		func(name string) error { return RemoveExchange(*session, name, false) })
+}
+
+// removeAll removes the named objects using the remove function and returns
+// the names of the objects that could not be removed. Since a failed removal
+// closes the channel, a new channel is opened after each error.
+func (s *AmqpTap) removeAll(session *Session, kind string, names []string,
+	remove func(name string) error,
+) []string {
+	var failed []string
+	for i, name := range names {
+		err := remove(name)
+		if err == nil {
+			s.logger.Debug("removed tap "+kind, "name", name)
+			continue
+		}
+		s.logger.Error("failed to remove tap "+kind, "name", name, "error", err)
+		failed = append(failed, name)
+		if err := session.NewChannel(); err != nil {
+			s.logger.Error("failed to open channel to remove taps", "error", err)
+			return append(failed, names[i+1:]...)
+		}
 	}
+	return failed
 }
 
 func (s *AmqpTap) setupTapsForExchanges(
@@ -81,7 +140,7 @@ func (s *AmqpTap) setupTapsForExchanges(
 	var channels []<-chan amqp.Delivery
 
 	for _, exchangeConfig := range exchangeConfigList {
-		exchange, queue, err := s.setupTap(session, exchangeConfig)
+		queue, err := s.setupTap(session, exchangeConfig)
 		if err != nil {
 			return channels, err
 		}
@@ -90,20 +149,18 @@ func (s *AmqpTap) setupTapsForExchanges(
 			return channels, err
 		}
 		channels = append(channels, msgCh)
-		// store created exchanges and queues for later cleanup
-		s.exchanges = append(s.exchanges, exchange)
-		s.queues = append(s.queues, queue)
 	}
 	return channels, nil
 }
 
 // setupTap sets up the a single tap to an exchange.  We create an
 // exchange-to-exchange binding where the bound exchange (of type fanout) will
-// receive all messages published to the original exchange. Returns
-// (tapExchangeName, tapQueueName, error)
+// receive all messages published to the original exchange. The created
+// exchange and queue are recorded for later removal. Returns
+// (tapQueueName, error)
 func (s *AmqpTap) setupTap(session Session,
 	exchangeConfig ExchangeConfiguration,
-) (string, string, error) {
+) (string, error) {
 	id := uuid.Must(uuid.NewRandom()).String()
 	tapExchange := getTapExchangeNameForExchange(exchangeConfig.Exchange, id[:12])
 	tapQueue := getTapQueueNameForExchange(exchangeConfig.Exchange, id[:12])
@@ -111,9 +168,10 @@ func (s *AmqpTap) setupTap(session Session,
 	err := s.createExchangeToExchangeBinding(session,
 		exchangeConfig.Exchange,
 		exchangeConfig.BindingKey,
+		exchangeConfig.BindingArgs,
 		tapExchange)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
 	err = CreateQueue(session, tapQueue,
@@ -122,14 +180,15 @@ func (s *AmqpTap) setupTap(session Session,
 		true,  // exclusive
 		nil)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
+	s.queues = append(s.queues, tapQueue)
 
 	if err = s.bindQueueToExchange(session, tapExchange,
 		exchangeConfig.BindingKey, tapQueue); err != nil {
-		return "", "", err
+		return "", err
 	}
-	return tapExchange, tapQueue, nil
+	return tapQueue, nil
 }
 
 // createExchangeToExchangeBinding creates a new exchange 'tapExchangeName'
@@ -140,40 +199,31 @@ func (s *AmqpTap) setupTap(session Session,
 // and must be set to
 // - '#' on topic exchanges
 // - a binding-key on direct exchanges (i.e. no wildcards)
-// - ” on fanout or headers exchanges
-// On errors delete prior created exchanges and/or queues to make sure
-// that there are no leftovers lying around on the broker.
-// TODO error handling must be improved - does not work if connection is lost
+// - ” on fanout exchanges
+// - ” on headers exchanges, with the headers to match passed in bindingArgs
+// Since headers exchanges ignore the binding key and all other exchange types
+// ignore the binding arguments, a header binding like "x-match=any,a=b" is
+// passed as both, which works without knowing the type of the exchange.
+// The tap-exchange is recorded right after creation, so it is removed by
+// removeTaps even if the binding fails.
 func (s *AmqpTap) createExchangeToExchangeBinding(session Session,
-	exchangeName, bindingKey, tapExchangeName string,
+	exchangeName, bindingKey string, bindingArgs amqp.Table, tapExchangeName string,
 ) error {
-	var err error
-
 	if err := CreateExchange(session, tapExchangeName, amqp.ExchangeFanout,
 		false /* nondurable*/, true, nil); err != nil {
 		return err
 	}
+	s.exchanges = append(s.exchanges, tapExchangeName)
 
-	// TODO when tapping to headers exchange the bindingKey must be
-	// "translated" into an amqp.Table{} struct. Right know we are always
-	// seeing all messages sent to exchanges of type headers.
-	if err = session.ExchangeBind(
+	if bindingArgs == nil {
+		bindingArgs = amqp.Table{}
+	}
+	return session.ExchangeBind(
 		tapExchangeName, // destination
 		bindingKey,
 		exchangeName, // source
 		false,        // wait for response
-		amqp.Table{}); err != nil {
-
-		// bind failed, so we must also delete our tap-exchange since it
-		// will not be auto-deleted when no binding exists.
-		// TODO handle errors
-		_ = session.NewChannel()
-		if err2 := RemoveExchange(session, tapExchangeName, false); err2 != nil {
-			s.logger.Error("failed to remove exchange", "exchange", tapExchangeName, "error", err2)
-		}
-		return err
-	}
-	return nil
+		bindingArgs)
 }
 
 func (s *AmqpTap) bindQueueToExchange(session Session,
@@ -0,0 +1,80 @@
+// Copyright (C) 2026 Jan Delgado
+// Detection of tap-exchanges and tap-queues left over by rabtap instances
+// which did not shut down properly, e.g. because they crashed.
+
+package rabtap
+
+import (
+	"strings"
+	"time"
+)
+
+// idleSinceLayouts are the formats of the idle_since attribute of queues,
+// which changed between RabbitMQ versions.
+var idleSinceLayouts = []string{
+	"2006-01-02T15:04:05.000-07:00",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+}
+
+// OrphanedTaps holds the tap-exchanges and tap-queues which are no longer
+// used by any rabtap instance.
+type OrphanedTaps struct {
+	Exchanges []RabbitExchange
+	Queues    []RabbitQueue
+}
+
+// IsTapExchange returns true if the given exchange was created by rabtap to
+// tap another exchange.
+func IsTapExchange(name string) bool {
+	return strings.HasPrefix(name, tapExchangePrefix)
+}
+
+// IsTapQueue returns true if the given queue was created by rabtap to
+// receive the messages of a tap-exchange.
+func IsTapQueue(name string) bool {
+	return strings.HasPrefix(name, tapQueuePrefix)
+}
+
+// parseIdleSince parses the idle_since attribute of a queue. ok is false if
+// the queue is not idle.
+func parseIdleSince(idleSince string) (t time.Time, ok bool) {
+	for _, layout := range idleSinceLayouts {
+		if t, err := time.Parse(layout, idleSince); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FindOrphanedTaps returns the orphaned tap-exchanges and tap-queues of the
+// broker. A tap-exchange is orphaned when it is neither the source nor the
+// destination of a binding. A tap-queue is orphaned when it has no consumers
+// and has been idle for at least minIdle. Running rabtap instances which are
+// just setting up a tap or reconnecting are thus not affected.
+func FindOrphanedTaps(brokerInfo BrokerInfo, minIdle time.Duration, now time.Time) OrphanedTaps {
+	type key struct{ vhost, name string }
+	used := map[key]bool{}
+	for _, binding := range brokerInfo.Bindings {
+		used[key{binding.Vhost, binding.Source}] = true
+		if binding.IsExchangeToExchange() {
+			used[key{binding.Vhost, binding.Destination}] = true
+		}
+	}
+
+	var orphans OrphanedTaps
+	for _, exchange := range brokerInfo.Exchanges {
+		if IsTapExchange(exchange.Name) && !used[key{exchange.Vhost, exchange.Name}] {
+			orphans.Exchanges = append(orphans.Exchanges, exchange)
+		}
+	}
+	for _, queue := range brokerInfo.Queues {
+		if !IsTapQueue(queue.Name) || queue.Consumers > 0 {
+			continue
+		}
+		if idleSince, ok := parseIdleSince(queue.IdleSince); ok && now.Sub(idleSince) >= minIdle {
+			orphans.Queues = append(orphans.Queues, queue)
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2026 Jan Delgado
+
+package rabtap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTapExchangeAndIsTapQueue(t *testing.T) {
+	assert.True(t, IsTapExchange(getTapExchangeNameForExchange("amq.topic", "123")))
+	assert.False(t, IsTapExchange(getTapQueueNameForExchange("amq.topic", "123")))
+	assert.False(t, IsTapExchange("amq.topic"))
+
+	assert.True(t, IsTapQueue(getTapQueueNameForExchange("amq.topic", "123")))
+	assert.False(t, IsTapQueue(getTapExchangeNameForExchange("amq.topic", "123")))
+	assert.False(t, IsTapQueue("queue"))
+}
+
+func TestFindOrphanedTapsReturnsUnusedTapExchangesAndQueues(t *testing.T) {
+	brokerInfo := BrokerInfo{
+		Exchanges: []RabbitExchange{
+			{Name: "amq.topic", Vhost: "/"},
+			{Name: "__tap-exchange-for-amq.topic-used", Vhost: "/"},
+			{Name: "__tap-exchange-for-amq.topic-orphan", Vhost: "/"},
+			{Name: "__tap-exchange-for-amq.topic-used", Vhost: "other"},
+			{Name: "__tap-exchange-for-amq.topic-starting", Vhost: "/"},
+		},
+		Queues: []RabbitQueue{
+			{Name: "queue", Vhost: "/", Consumers: 0, IdleSince: "2017-05-25 19:14:32"},
+			{Name: "__tap-queue-for-amq.topic-used", Vhost: "/", Consumers: 1},
+			{Name: "__tap-queue-for-amq.topic-orphan", Vhost: "/", Consumers: 0, IdleSince: "2017-05-25 19:14:32"},
+		},
+		Bindings: []RabbitBinding{
+			{Source: "amq.topic", Vhost: "/", Destination: "__tap-exchange-for-amq.topic-starting", DestinationType: "exchange"},
+			{Source: "__tap-exchange-for-amq.topic-used", Vhost: "/", Destination: "__tap-queue-for-amq.topic-used", DestinationType: "queue"},
+		},
+	}
+	now := time.Date(2017, 5, 25, 20, 0, 0, 0, time.UTC)
+
+	orphans := FindOrphanedTaps(brokerInfo, 5*time.Minute, now)
+
+	assert.Equal(t, []RabbitExchange{
+		{Name: "__tap-exchange-for-amq.topic-orphan", Vhost: "/"},
+		{Name: "__tap-exchange-for-amq.topic-used", Vhost: "other"},
+	}, orphans.Exchanges)
+	assert.Equal(t, []RabbitQueue{
+		{Name: "__tap-queue-for-amq.topic-orphan", Vhost: "/", Consumers: 0, IdleSince: "2017-05-25 19:14:32"},
+	}, orphans.Queues)
+}
+
+func TestFindOrphanedTapsIgnoresRecentlyIdleTapQueues(t *testing.T) {
+	brokerInfo := BrokerInfo{
+		Queues: []RabbitQueue{
+			{Name: "__tap-queue-for-amq.topic-new", Vhost: "/", Consumers: 0},
+			{Name: "__tap-queue-for-amq.topic-idle", Vhost: "/", Consumers: 0, IdleSince: "2017-05-25T19:58:00.000+00:00"},
+			{Name: "__tap-queue-for-amq.topic-orphan", Vhost: "/", Consumers: 0, IdleSince: "2017-05-25T19:50:00.000+00:00"},
+		},
+	}
+	now := time.Date(2017, 5, 25, 20, 0, 0, 0, time.UTC)
+
+	orphans := FindOrphanedTaps(brokerInfo, 5*time.Minute, now)
+
+	require.Len(t, orphans.Queues, 1)
+	assert.Equal(t, "__tap-queue-for-amq.topic-orphan", orphans.Queues[0].Name)
+}
+
+func TestFindOrphanedTapsReturnsNothingWithoutTaps(t *testing.T) {
+	orphans := FindOrphanedTaps(BrokerInfo{
+		Exchanges: []RabbitExchange{{Name: "amq.topic", Vhost: "/"}},
+		Queues:    []RabbitQueue{{Name: "queue", Vhost: "/"}},
+	}, 0, time.Now())
+	assert.Empty(t, orphans.Exchanges)
+	assert.Empty(t, orphans.Queues)
+}
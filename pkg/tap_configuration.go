@@ -4,8 +4,12 @@ package rabtap
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 // ExchangeConfiguration holds exchange and bindingkey for a single tap
@@ -15,6 +19,18 @@ type ExchangeConfiguration struct {
 	// BindingKey is the binding key to use. The key depends on the the type
 	// of exchange being tapped (e.g. direct, topic).
 	BindingKey string
+	// BindingArgs are the optional arguments of the binding. When the
+	// binding is of the form "key=value,...", the headers to match on
+	// exchanges of type headers are passed here. Other exchange types ignore
+	// binding arguments and route by the BindingKey.
+	BindingArgs amqp.Table
+	// DiscoverBindings is set when no binding was given. The binding keys
+	// must then be discovered using the management API (see discovery.go)
+	DiscoverBindings bool
+	// ExchangePattern is set when Exchange is a glob or regular expression
+	// selecting the exchanges to tap. The matching exchanges must then be
+	// discovered using the management API (see discovery.go)
+	ExchangePattern *regexp.Regexp
 }
 
 // unescapeStr reutrns a string with all '\' characters removed from the
@@ -33,20 +49,47 @@ func unescapeStr(s string) string {
 	return res
 }
 
-// splitExchangeAndBinding splits a string of the form "exchange:binding"
-// and return exchange and binding as string. A colon can be escaped with
-// \: if it is part of the exchange or binding string, e.g.
-// splitExchangeAndBinding("ex\\:change:binding") -> ("ex:change", "binding", nil)
-func splitExchangeAndBinding(exchangeAndBinding string) (string, string, error) {
+// indexUnescapedColon returns the index of the first colon in s, which is
+// not escaped by a '\', or -1 if there is no such colon.
+func indexUnescapedColon(s string) int {
 	inEscape := false
-	pos := -1
-	for i, c := range exchangeAndBinding {
+	for i, c := range s {
 		if c == ':' && !inEscape {
-			pos = i
-			break
+			return i
 		}
 		inEscape = (c == '\\')
 	}
+	return -1
+}
+
+// splitUnescapedComma splits s at each comma, which is not escaped by a '\'.
+// The escapes are kept.
+func splitUnescapedComma(s string) []string {
+	var items []string
+	start := 0
+	inEscape := false
+	for i, c := range s {
+		if c == ',' && !inEscape {
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+		inEscape = (c == '\\' && !inEscape)
+	}
+	return append(items, s[start:])
+}
+
+// unescapeRegexp removes the escapes of colons and commas from a regexp,
+// keeping all other escapes like "\." intact.
+func unescapeRegexp(s string) string {
+	return strings.NewReplacer("\\:", ":", "\\,", ",").Replace(s)
+}
+
+// splitExchangeAndBinding splits a string of the form "exchange:binding"
+// and return exchange and binding as string. A colon can be escaped with
+// \: if it is part of the exchange or binding string, e.g.
+// splitExchangeAndBinding("ex\\:change:binding") -> ("ex:change", "binding", nil)
+func splitExchangeAndBinding(exchangeAndBinding string) (string, string, error) {
+	pos := indexUnescapedColon(exchangeAndBinding)
 	if pos == -1 {
 		return "", "", errors.New("expected format `exchange`:`binding`, but got `" +
 			exchangeAndBinding + "`")
@@ -54,14 +97,117 @@ func splitExchangeAndBinding(exchangeAndBinding string) (string, string, error)
 	return unescapeStr(exchangeAndBinding[:pos]), unescapeStr(exchangeAndBinding[pos+1:]), nil
 }
 
+// regexpSelectorPrefix marks an exchange selector as regular expression,
+// e.g. "re:^billing\..*$:#"
+const regexpSelectorPrefix = "re:"
+
+// splitTapSpec splits a single tap specification of the form
+// "exchange[:binding]" or "re:regexp[:binding]". Other than the exchange
+// name, only escaped colons and commas are unescaped in a regexp, so that
+// e.g. "\." keeps its meaning. An exchange named "re" must be escaped as
+// "\re", e.g. "\re:#". Returns (exchange, binding, isRegexp, hasBinding).
+func splitTapSpec(spec string) (string, string, bool, bool) {
+	if rest, ok := strings.CutPrefix(spec, regexpSelectorPrefix); ok {
+		pos := indexUnescapedColon(rest)
+		if pos == -1 {
+			return unescapeRegexp(rest), "", true, false
+		}
+		return unescapeRegexp(rest[:pos]), unescapeStr(rest[pos+1:]), true, true
+	}
+	exchange, binding, err := splitExchangeAndBinding(spec)
+	if err != nil {
+		return unescapeStr(spec), "", false, false
+	}
+	return exchange, binding, false, true
+}
+
+// isGlobPattern returns true if the given exchange name contains one of the
+// glob wildcards '*' or '?'.
+func isGlobPattern(exchange string) bool {
+	return strings.ContainsAny(exchange, "*?")
+}
+
+// globToRegexp converts a glob pattern into an anchored regular expression,
+// where '*' matches any sequence of characters and '?' a single character.
+func globToRegexp(glob string) *regexp.Regexp {
+	re := regexp.QuoteMeta(glob)
+	re = strings.ReplaceAll(re, "\\*", ".*")
+	re = strings.ReplaceAll(re, "\\?", ".")
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// parseHeaderBinding parses a binding of the form "key=value,key=value" as
+// used to bind to exchanges of type headers, e.g. "x-match=any,region=eu".
+// Returns the headers as amqp.Table and true, or false if the binding is not
+// a header binding.
+func parseHeaderBinding(binding string) (amqp.Table, bool) {
+	headers := KeyValueMap{}
+	for _, kv := range strings.Split(binding, ",") {
+		key, value, found := strings.Cut(kv, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, false
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return ToAMQPTable(headers), true
+}
+
 // NewExchangeConfiguration returns a pointer to a newly created
-// ExchangeConfiguration object
+// ExchangeConfiguration object. If the binding is of the form
+// "key=value,..." it can be a header binding, whose headers are additionally
+// passed as binding arguments. The binding is always kept as binding key, so
+// that topic or direct exchanges are still bound by a key containing "=".
+// If no binding is given at all, the bindings are marked to be discovered. The exchange
+// can be a glob pattern (e.g. "orders.*") or a regular expression prefixed
+// with "re:", which select the exchanges to tap during discovery.
 func NewExchangeConfiguration(exchangeAndBindingStr string) (*ExchangeConfiguration, error) {
-	exchange, binding, err := splitExchangeAndBinding(exchangeAndBindingStr)
-	if err != nil {
-		return nil, err
+	exchange, binding, isRegexp, hasBinding := splitTapSpec(exchangeAndBindingStr)
+	config := ExchangeConfiguration{Exchange: exchange}
+
+	if isRegexp {
+		re, err := regexp.Compile(exchange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exchange regexp `%s`: %w", exchange, err)
+		}
+		config.ExchangePattern = re
+	} else if isGlobPattern(exchange) {
+		config.ExchangePattern = globToRegexp(exchange)
+	}
+
+	if !hasBinding {
+		config.DiscoverBindings = true
+		return &config, nil
+	}
+	config.BindingKey = binding
+	if headers, ok := parseHeaderBinding(binding); ok {
+		config.BindingArgs = headers
+	}
+	return &config, nil
+}
+
+// splitExchangesAndBindings splits a string of the form
+// "exchange:binding,exchange:binding" into the single "exchange:binding"
+// items. Commas escaped as "\," do not separate items. Since the headers of
+// a header binding are also separated by commas (e.g.
+// "exchange:x-match=any,a=b"), an item which is not of the form
+// "exchange:binding" but of the form "key=value" continues the header
+// binding of the preceding item.
+func splitExchangesAndBindings(exchangesAndBindings string) []string {
+	var items []string
+	for _, item := range splitUnescapedComma(exchangesAndBindings) {
+		if n := len(items); n > 0 && strings.Contains(item, "=") {
+			if _, _, _, hasBinding := splitTapSpec(item); !hasBinding {
+				_, prevBinding, _, _ := splitTapSpec(items[n-1])
+				if _, ok := parseHeaderBinding(prevBinding); ok {
+					items[n-1] += "," + item
+					continue
+				}
+			}
+		}
+		items = append(items, item)
 	}
-	return &ExchangeConfiguration{exchange, binding}, nil
+	return items
 }
 
 // TapConfiguration holds the set of ExchangeCOnfigurations to tap to for a
@@ -71,14 +217,26 @@ type TapConfiguration struct {
 	Exchanges []ExchangeConfiguration
 }
 
+// NeedsDiscovery returns true if any of the exchanges of the configuration
+// requires discovery of the binding keys or of the exchanges itself.
+func (s TapConfiguration) NeedsDiscovery() bool {
+	for _, exchange := range s.Exchanges {
+		if exchange.DiscoverBindings || exchange.ExchangePattern != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // NewTapConfiguration returns a TapConfiguration object for a an rabbitMQ
 // broker specified by an URI and a list of exchanges and bindings in the
-// form of "exchange:binding,exchange:binding). Returns configuration object
-// or an error if parsing failed.
+// form of "exchange:binding,exchange:binding). The binding can be omitted
+// ("exchange,exchange:binding"), in which case it must be discovered later.
+// Returns configuration object or an error if parsing failed.
 func NewTapConfiguration(amqpURL *url.URL, exchangesAndBindings string) (*TapConfiguration, error) {
 	result := TapConfiguration{}
 	result.AMQPURL = amqpURL
-	for _, item := range strings.Split(exchangesAndBindings, ",") {
+	for _, item := range splitExchangesAndBindings(exchangesAndBindings) {
 		exchangeConfig, err := NewExchangeConfiguration(item)
 		if err != nil {
 			return nil, err
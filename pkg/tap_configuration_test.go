@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"testing"
 
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,10 +51,164 @@ func TestNewTapConfigurationIsConstructedCorrecly(t *testing.T) {
 	assert.Equal(t, "b2", tc.Exchanges[1].BindingKey)
 }
 
-func TestFaultyTapConfiguration(t *testing.T) {
+func TestTapConfigurationWithoutBindingRequiresDiscovery(t *testing.T) {
 
 	url, _ := url.Parse("uri")
-	_, err := NewTapConfiguration(url, "exchange")
+	tc, err := NewTapConfiguration(url, "ex\\:change,e2:b2")
+
+	assert.Nil(t, err)
+	assert.True(t, tc.NeedsDiscovery())
+	assert.Equal(t, 2, len(tc.Exchanges))
+	assert.Equal(t, ExchangeConfiguration{Exchange: "ex:change", DiscoverBindings: true}, tc.Exchanges[0])
+	assert.False(t, tc.Exchanges[1].DiscoverBindings)
+}
+
+func TestTapConfigurationWithBindingsNeedsNoDiscovery(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	tc, err := NewTapConfiguration(url, "e1:,e2:b2")
+
+	assert.Nil(t, err)
+	assert.False(t, tc.NeedsDiscovery())
+}
+
+func TestParseHeaderBindingReturnsHeadersAsTable(t *testing.T) {
+	headers, ok := parseHeaderBinding("x-match=any, region=eu,tenant=42")
+
+	assert.True(t, ok)
+	assert.Equal(t, amqp.Table{"x-match": "any", "region": "eu", "tenant": 42}, headers)
+}
+
+func TestParseHeaderBindingRejectsNonHeaderBindings(t *testing.T) {
+	for _, binding := range []string{"", "#", "key", "a=b,key", "=b"} {
+		_, ok := parseHeaderBinding(binding)
+		assert.False(t, ok, binding)
+	}
+}
+
+func TestNewExchangeConfigurationWithHeaderBinding(t *testing.T) {
+	ec, err := NewExchangeConfiguration("myheaders:x-match=all,region=eu")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "myheaders", ec.Exchange)
+	assert.Equal(t, "x-match=all,region=eu", ec.BindingKey)
+	assert.Equal(t, amqp.Table{"x-match": "all", "region": "eu"}, ec.BindingArgs)
+}
+
+func TestNewExchangeConfigurationKeepsRoutingKeyContainingEqualSign(t *testing.T) {
+	ec, err := NewExchangeConfiguration("mytopic:price=100.#")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "mytopic", ec.Exchange)
+	assert.Equal(t, "price=100.#", ec.BindingKey)
+}
+
+func TestNewTapConfigurationWithHeaderBindings(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	tc, err := NewTapConfiguration(url, "e1:x-match=any,region=eu,tenant=42,e2:b2,e3:a=b")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tc.Exchanges))
+	assert.Equal(t, "e1", tc.Exchanges[0].Exchange)
+	assert.Equal(t, amqp.Table{"x-match": "any", "region": "eu", "tenant": 42},
+		tc.Exchanges[0].BindingArgs)
+	assert.Equal(t, "e2", tc.Exchanges[1].Exchange)
+	assert.Equal(t, "b2", tc.Exchanges[1].BindingKey)
+	assert.Nil(t, tc.Exchanges[1].BindingArgs)
+	assert.Equal(t, "e3", tc.Exchanges[2].Exchange)
+	assert.Equal(t, amqp.Table{"a": "b"}, tc.Exchanges[2].BindingArgs)
+}
+
+func TestNewTapConfigurationDoesNotContinueNonHeaderBinding(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	tc, err := NewTapConfiguration(url, "e1:key,a=b")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tc.Exchanges))
+	assert.Equal(t, "key", tc.Exchanges[0].BindingKey)
+	assert.Equal(t, "a=b", tc.Exchanges[1].Exchange)
+}
+
+func TestNewExchangeConfigurationAcceptsExchangeNameWithEqualSign(t *testing.T) {
+	config, err := NewExchangeConfiguration("a=b:#")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a=b", config.Exchange)
+	assert.Equal(t, "#", config.BindingKey)
+	assert.Nil(t, config.BindingArgs)
+	assert.False(t, config.DiscoverBindings)
+}
+
+func TestSplitTapSpec(t *testing.T) {
+	testcases := []struct {
+		spec       string
+		exchange   string
+		binding    string
+		isRegexp   bool
+		hasBinding bool
+	}{
+		{"ex:key", "ex", "key", false, true},
+		{"ex", "ex", "", false, false},
+		{"e\\:x", "e:x", "", false, false},
+		{"re:^billing\\..*$:#", "^billing\\..*$", "#", true, true},
+		{"re:^billing\\..*$", "^billing\\..*$", "", true, false},
+		{"re:a\\:b:c", "a:b", "c", true, true},
+		{"re:^a{1\\,2}$:c", "^a{1,2}$", "c", true, true},
+		// exchange named "re"
+		{"\\re:#", "re", "#", false, true},
+	}
+	for _, tc := range testcases {
+		exchange, binding, isRegexp, hasBinding := splitTapSpec(tc.spec)
+		assert.Equal(t, tc.exchange, exchange, tc.spec)
+		assert.Equal(t, tc.binding, binding, tc.spec)
+		assert.Equal(t, tc.isRegexp, isRegexp, tc.spec)
+		assert.Equal(t, tc.hasBinding, hasBinding, tc.spec)
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	re := globToRegexp("orders.*-?")
+
+	assert.True(t, re.MatchString("orders.eu-1"))
+	assert.True(t, re.MatchString("orders.-x"))
+	assert.False(t, re.MatchString("ordersXeu-1"))
+	assert.False(t, re.MatchString("orders.eu-12"))
+	assert.False(t, re.MatchString("my-orders.eu-1"))
+}
+
+func TestNewTapConfigurationWithExchangePatterns(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	tc, err := NewTapConfiguration(url, "orders.*:#,re:^billing\\..*$:#,plain:key")
+
+	assert.Nil(t, err)
+	assert.True(t, tc.NeedsDiscovery())
+	assert.Equal(t, 3, len(tc.Exchanges))
+	assert.Equal(t, "orders.*", tc.Exchanges[0].Exchange)
+	assert.Equal(t, "^orders\\..*$", tc.Exchanges[0].ExchangePattern.String())
+	assert.Equal(t, "#", tc.Exchanges[0].BindingKey)
+	assert.Equal(t, "^billing\\..*$", tc.Exchanges[1].ExchangePattern.String())
+	assert.Equal(t, "#", tc.Exchanges[1].BindingKey)
+	assert.Nil(t, tc.Exchanges[2].ExchangePattern)
+}
+
+func TestNewTapConfigurationWithEscapedCommaInRegexp(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	tc, err := NewTapConfiguration(url, "re:^a{1\\,2}$:#,plain:key")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tc.Exchanges))
+	assert.Equal(t, "^a{1,2}$", tc.Exchanges[0].ExchangePattern.String())
+	assert.Equal(t, "plain", tc.Exchanges[1].Exchange)
+}
+
+func TestNewTapConfigurationFailsOnInvalidRegexp(t *testing.T) {
+
+	url, _ := url.Parse("uri")
+	_, err := NewTapConfiguration(url, "re:billing(:#")
 
 	assert.NotNil(t, err)
 }
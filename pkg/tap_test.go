@@ -51,7 +51,7 @@ func verifyMessagesOnTap(t *testing.T, consumer string, numExpected int,
 	go tap.EstablishTap(
 		ctx,
 		[]ExchangeConfiguration{
-			{tapExchangeName, tapQueueName},
+			{Exchange: tapExchangeName, BindingKey: tapQueueName},
 		},
 		resultChannel,
 		resultErrChannel)
@@ -218,7 +218,7 @@ func TestIntegrationInvalidExchange(t *testing.T) {
 	err := tap.EstablishTap(
 		ctx,
 		[]ExchangeConfiguration{
-			{"nonexisting-exchange", "test"},
+			{Exchange: "nonexisting-exchange", BindingKey: "test"},
 		},
 		tapMessages,
 		errChannel)
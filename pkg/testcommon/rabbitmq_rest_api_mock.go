@@ -21,7 +21,7 @@ const (
 // NewRabbitAPIMock returns a mock server for the rabbitmq http managemet
 // API. It is used by the integration test. Only a very limited subset
 // of resources is support (GET exchanges, bindings, queues, overviews,
-// channels, connections; DELETE connections)
+// channels, connections; DELETE connections, exchanges, queues; PUT vhosts)
 // Usage:
 //
 //	mockServer := NewRabbitAPIMock(MockModeStd)
@@ -52,6 +52,8 @@ func mockStdHandler(w http.ResponseWriter, r *http.Request) {
 		mockStdGetHandler(w, r)
 	case "DELETE":
 		mockStdDeleteHandler(w, r)
+	case "PUT":
+		mockStdPutHandler(w, r)
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 	}
@@ -62,6 +64,8 @@ func mockStdGetHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.RequestURI() {
 	case "/vhosts":
 		result = vhostsResult
+	case "/vhosts/%2F":
+		result = vhostResult
 	case "/exchanges":
 		result = exchangeResult
 	case "/bindings":
@@ -86,7 +90,19 @@ func mockStdGetHandler(w http.ResponseWriter, r *http.Request) {
 
 func mockStdDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.RequestURI() {
-	case "/connections/172.17.0.1:40874%20-%3E%20172.17.0.2:5672":
+	case "/connections/172.17.0.1:40874%20-%3E%20172.17.0.2:5672",
+		"/exchanges/%2F/test-topic",
+		"/queues/%2F/topic-q1":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+	_, _ = fmt.Fprint(w, "")
+}
+
+func mockStdPutHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.RequestURI() {
+	case "/vhosts/%2F":
 		w.WriteHeader(http.StatusNoContent)
 	default:
 		w.WriteHeader(http.StatusNotFound)
@@ -95,6 +111,7 @@ func mockStdDeleteHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 const (
+	vhostResult  = `{"description":"default vhost","tags":["prod","eu"],"default_queue_type":"quorum","name":"/","tracing":false,"messages":4}`
 	vhostsResult = `
 [{"cluster_state":{"rabbit@108f57d1fe8ab":"running"},"description":"","message_stats":{"ack":13187,"ack_details":{"rate":0.0},"confirm":0,"confirm_details":{"rate":0.0},"deliver":13190,"deliver_details":{"rate":0.0},"deliver_get":13190,"deliver_get_details":{"rate":0.0},"deliver_no_ack":0,"deliver_no_ack_details":{"rate":0.0},"drop_unroutable":1,"drop_unroutable_details":{"rate":0.0},"get":0,"get_details":{"rate":0.0},"get_empty":0,"get_empty_details":{"rate":0.0},"get_no_ack":0,"get_no_ack_details":{"rate":0.0},"publish":109674,"publish_details":{"rate":0.0},"redeliver":0,"redeliver_details":{"rate":0.0},"return_unroutable":0,"return_unroutable_details":{"rate":0.0}},"messages":4,"messages_details":{"rate":0.0},"messages_ready":4,"messages_ready_details":{"rate":0.0},"messages_unacknowledged":0,"messages_unacknowledged_details":{"rate":0.0},"metadata":{"description":"","tags":[]},"name":"/","recv_oct":31769538,"recv_oct_details":{"rate":3.2},"send_oct":4738294,"send_oct_details":{"rate":3.2},"tags":[],"tracing":false}]`
 